@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,3 +20,20 @@ func Env(key string, fallback string) string {
 
 	return fallback
 }
+
+// EnvDuration reads key as a Go duration string such as "15m" and returns
+// fallback when the variable is unset or cannot be parsed.
+func EnvDuration(key string, fallback time.Duration) time.Duration {
+	value := Env(key, "")
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration for %s: %v, using %s", key, err, fallback)
+		return fallback
+	}
+
+	return duration
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transactions_pending_expiry;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS expires_at;
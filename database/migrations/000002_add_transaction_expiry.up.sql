@@ -0,0 +1,4 @@
+-- Pending transactions hold their quota until expires_at
+ALTER TABLE transactions ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_transactions_pending_expiry ON transactions(expires_at) WHERE status = 'pending';
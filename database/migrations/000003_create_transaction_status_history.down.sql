@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_transaction_status_history_transaction;
+
+DROP TABLE IF EXISTS transaction_status_history;
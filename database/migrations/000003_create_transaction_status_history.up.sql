@@ -0,0 +1,14 @@
+-- Create transaction_status_history table
+CREATE TABLE transaction_status_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    field VARCHAR(50) NOT NULL,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_field CHECK (field IN ('status', 'payment_status'))
+);
+
+CREATE INDEX idx_transaction_status_history_transaction ON transaction_status_history(transaction_id, created_at);
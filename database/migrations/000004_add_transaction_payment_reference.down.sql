@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_payment_reference;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS payment_reference;
+ALTER TABLE transactions DROP COLUMN IF EXISTS payment_provider;
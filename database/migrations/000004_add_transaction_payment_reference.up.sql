@@ -0,0 +1,5 @@
+-- Provider and provider-side reference of the charge backing a transaction
+ALTER TABLE transactions ADD COLUMN payment_provider VARCHAR(50);
+ALTER TABLE transactions ADD COLUMN payment_reference VARCHAR(255);
+
+CREATE UNIQUE INDEX idx_transactions_payment_reference ON transactions(payment_provider, payment_reference);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payment_webhook_nonces_received;
+
+DROP TABLE IF EXISTS payment_webhook_nonces;
@@ -0,0 +1,9 @@
+-- Nonces of accepted payment webhooks, used to reject replays
+CREATE TABLE payment_webhook_nonces (
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, nonce)
+);
+
+CREATE INDEX idx_payment_webhook_nonces_received ON payment_webhook_nonces(received_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_refund_items_refund;
+DROP INDEX IF EXISTS idx_refunds_transaction;
+
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE transaction_details DROP CONSTRAINT IF EXISTS check_refunded_quantity;
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS refunded_quantity;
@@ -0,0 +1,33 @@
+-- Tickets of a transaction detail that have been refunded
+ALTER TABLE transaction_details ADD COLUMN refunded_quantity INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transaction_details ADD CONSTRAINT check_refunded_quantity CHECK (refunded_quantity >= 0 AND refunded_quantity <= quantity);
+
+-- Create refunds table
+CREATE TABLE refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    amount DECIMAL(10,2) NOT NULL,
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    provider_reference VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_refund_amount CHECK (amount >= 0)
+);
+
+-- Create refund_items table
+CREATE TABLE refund_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    refund_id UUID NOT NULL REFERENCES refunds(id),
+    transaction_detail_id UUID NOT NULL REFERENCES transaction_details(id),
+    quantity INTEGER NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_refund_item_quantity CHECK (quantity > 0)
+);
+
+CREATE INDEX idx_refunds_transaction ON refunds(transaction_id);
+CREATE INDEX idx_refund_items_refund ON refund_items(refund_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_tickets_event;
+DROP INDEX IF EXISTS idx_tickets_user;
+DROP INDEX IF EXISTS idx_tickets_transaction_detail;
+DROP INDEX IF EXISTS idx_tickets_transaction;
+
+DROP TABLE IF EXISTS tickets;
@@ -0,0 +1,23 @@
+-- Create tickets table
+CREATE TABLE tickets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    transaction_detail_id UUID NOT NULL REFERENCES transaction_details(id),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    event_id UUID NOT NULL REFERENCES events(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    code VARCHAR(64) UNIQUE NOT NULL,
+    holder_name VARCHAR(255),
+    holder_email VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_ticket_status CHECK (status IN ('valid', 'used', 'void'))
+);
+
+CREATE INDEX idx_tickets_transaction ON tickets(transaction_id);
+CREATE INDEX idx_tickets_transaction_detail ON tickets(transaction_detail_id);
+CREATE INDEX idx_tickets_user ON tickets(user_id);
+CREATE INDEX idx_tickets_event ON tickets(event_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_tickets_ticket_type_status;
+
+ALTER TABLE tickets DROP COLUMN IF EXISTS used_gate;
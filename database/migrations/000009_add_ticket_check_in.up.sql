@@ -0,0 +1,4 @@
+-- Record the gate that checked a ticket in
+ALTER TABLE tickets ADD COLUMN used_gate VARCHAR(100);
+
+CREATE INDEX idx_tickets_ticket_type_status ON tickets(ticket_type_id, status);
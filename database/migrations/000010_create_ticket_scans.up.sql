@@ -0,0 +1,17 @@
+-- Create ticket_scans table
+CREATE TABLE ticket_scans (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id),
+    ticket_id UUID REFERENCES tickets(id),
+    gate VARCHAR(100) NOT NULL,
+    device_id VARCHAR(100),
+    source VARCHAR(20) NOT NULL,
+    result VARCHAR(20) NOT NULL,
+    scanned_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_ticket_scan_source CHECK (source IN ('online', 'offline')),
+    CONSTRAINT check_ticket_scan_result CHECK (result IN ('valid', 'already_used', 'wrong_event', 'voided', 'not_found', 'invalid_token'))
+);
+
+CREATE INDEX idx_ticket_scans_event_result ON ticket_scans(event_id, result);
+CREATE INDEX idx_ticket_scans_ticket ON ticket_scans(ticket_id);
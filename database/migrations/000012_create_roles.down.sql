@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_events_owner;
+
+ALTER TABLE events DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,64 @@
+-- Create roles table
+CREATE TABLE roles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create permissions table
+CREATE TABLE permissions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create role_permissions table
+CREATE TABLE role_permissions (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+-- Create user_roles table
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id),
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+-- Events are managed by the organizer who created them
+ALTER TABLE events ADD COLUMN owner_id UUID REFERENCES users(id);
+
+CREATE INDEX idx_user_roles_role ON user_roles(role_id);
+CREATE INDEX idx_events_owner ON events(owner_id);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access'),
+    ('organizer', 'Manages the events they own'),
+    ('staff', 'Checks in tickets at the gate'),
+    ('customer', 'Buys tickets and manages their own transactions');
+
+INSERT INTO permissions (name, description) VALUES
+    ('events.manage', 'Create events and manage owned events'),
+    ('events.manage_any', 'Manage every event'),
+    ('catalog.manage', 'Manage locations and schedules'),
+    ('transactions.create', 'Buy tickets'),
+    ('transactions.read_any', 'Read every transaction'),
+    ('transactions.manage', 'Change transaction and payment status and issue refunds'),
+    ('tickets.check_in', 'Check in tickets for every event'),
+    ('users.manage', 'Manage users and their roles');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE (r.name = 'admin')
+OR (r.name = 'organizer' AND p.name IN ('events.manage', 'catalog.manage', 'transactions.create'))
+OR (r.name = 'staff' AND p.name IN ('tickets.check_in'))
+OR (r.name = 'customer' AND p.name IN ('transactions.create'));
+
+-- Every existing user keeps buying tickets as a customer
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u, roles r
+WHERE r.name = 'customer';
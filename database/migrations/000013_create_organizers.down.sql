@@ -0,0 +1,25 @@
+UPDATE roles SET description = 'Manages the events they own'
+WHERE name = 'organizer';
+
+DELETE FROM permissions WHERE name = 'organizers.manage';
+
+DROP INDEX IF EXISTS idx_ticket_types_organizer;
+DROP INDEX IF EXISTS idx_locations_organizer;
+DROP INDEX IF EXISTS idx_events_organizer;
+DROP INDEX IF EXISTS idx_organizer_members_user;
+
+ALTER TABLE events ADD COLUMN owner_id UUID REFERENCES users(id);
+UPDATE events e SET owner_id = (
+    SELECT m.user_id FROM organizer_members m
+    WHERE m.organizer_id = e.organizer_id AND m.role = 'owner'
+    ORDER BY m.created_at
+    LIMIT 1
+);
+CREATE INDEX idx_events_owner ON events(owner_id);
+
+ALTER TABLE ticket_types DROP COLUMN IF EXISTS organizer_id;
+ALTER TABLE locations DROP COLUMN IF EXISTS organizer_id;
+ALTER TABLE events DROP COLUMN IF EXISTS organizer_id;
+
+DROP TABLE IF EXISTS organizer_members;
+DROP TABLE IF EXISTS organizers;
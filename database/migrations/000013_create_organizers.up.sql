@@ -0,0 +1,67 @@
+-- Create organizers table
+CREATE TABLE organizers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create organizer_members table
+CREATE TABLE organizer_members (
+    organizer_id UUID NOT NULL REFERENCES organizers(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organizer_id, user_id),
+    CONSTRAINT check_organizer_member_role CHECK (role IN ('owner', 'member'))
+);
+
+-- Every user who owns events gets an organizer of their own, reusing their
+-- user id, and rows nobody owned go to an organizer only admins manage
+INSERT INTO organizers (id, name)
+SELECT DISTINCT u.id, u.fullname FROM events e
+JOIN users u ON u.id = e.owner_id;
+
+INSERT INTO organizer_members (organizer_id, user_id, role)
+SELECT id, id, 'owner' FROM organizers;
+
+INSERT INTO organizers (id, name) VALUES
+    ('00000000-0000-0000-0000-000000000001', 'Unassigned');
+
+ALTER TABLE events ADD COLUMN organizer_id UUID REFERENCES organizers(id);
+UPDATE events SET organizer_id = COALESCE(owner_id, '00000000-0000-0000-0000-000000000001');
+ALTER TABLE events ALTER COLUMN organizer_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_events_owner;
+ALTER TABLE events DROP COLUMN owner_id;
+
+-- A location used by the events of a single organizer moves to that
+-- organizer, any other location stays unassigned
+ALTER TABLE locations ADD COLUMN organizer_id UUID REFERENCES organizers(id);
+UPDATE locations l SET organizer_id = COALESCE((
+    SELECT MIN(e.organizer_id::text)::uuid FROM events e
+    WHERE e.location_id = l.id
+    HAVING COUNT(DISTINCT e.organizer_id) = 1
+), '00000000-0000-0000-0000-000000000001');
+ALTER TABLE locations ALTER COLUMN organizer_id SET NOT NULL;
+
+ALTER TABLE ticket_types ADD COLUMN organizer_id UUID REFERENCES organizers(id);
+UPDATE ticket_types t SET organizer_id = e.organizer_id
+FROM events e WHERE e.id = t.event_id;
+ALTER TABLE ticket_types ALTER COLUMN organizer_id SET NOT NULL;
+
+CREATE INDEX idx_organizer_members_user ON organizer_members(user_id);
+CREATE INDEX idx_events_organizer ON events(organizer_id);
+CREATE INDEX idx_locations_organizer ON locations(organizer_id);
+CREATE INDEX idx_ticket_types_organizer ON ticket_types(organizer_id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('organizers.manage', 'Manage every organizer and its members');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'organizers.manage';
+
+UPDATE roles SET description = 'Manages the events of their organizers'
+WHERE name = 'organizer';
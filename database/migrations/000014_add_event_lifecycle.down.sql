@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_events_status;
+
+ALTER TABLE ticket_types DROP CONSTRAINT IF EXISTS check_sale_window;
+ALTER TABLE ticket_types DROP COLUMN IF EXISTS sale_end;
+ALTER TABLE ticket_types DROP COLUMN IF EXISTS sale_start;
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS check_event_status;
+ALTER TABLE events DROP COLUMN IF EXISTS status;
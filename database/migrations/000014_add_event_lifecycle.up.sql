@@ -0,0 +1,16 @@
+-- Events start as drafts, but those created before statuses existed were
+-- already selling tickets
+ALTER TABLE events ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft';
+UPDATE events SET status = 'on_sale';
+ALTER TABLE events ADD CONSTRAINT check_event_status CHECK (
+    status IN ('draft', 'published', 'on_sale', 'sold_out', 'cancelled', 'postponed')
+);
+
+-- Ticket types without a sales window sell while their event is on sale
+ALTER TABLE ticket_types ADD COLUMN sale_start TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ticket_types ADD COLUMN sale_end TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ticket_types ADD CONSTRAINT check_sale_window CHECK (
+    sale_start IS NULL OR sale_end IS NULL OR sale_end > sale_start
+);
+
+CREATE INDEX idx_events_status ON events(status);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS event_jobs;
+
+ALTER TABLE events DROP COLUMN IF EXISTS refund_deadline;
@@ -0,0 +1,44 @@
+-- Buyers of a postponed event may ask for a refund until this deadline
+ALTER TABLE events ADD COLUMN refund_deadline TIMESTAMP WITH TIME ZONE;
+
+-- Create event_jobs table
+CREATE TABLE event_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id),
+    kind VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    last_transaction_id UUID,
+    last_error TEXT,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_event_job_kind CHECK (kind IN ('cancel', 'postpone')),
+    CONSTRAINT check_event_job_status CHECK (status IN ('pending', 'running', 'completed'))
+);
+
+-- Create notifications table
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id),
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_notification_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+-- An event runs one job at a time
+CREATE UNIQUE INDEX idx_event_jobs_active ON event_jobs(event_id) WHERE status <> 'completed';
+CREATE INDEX idx_event_jobs_status ON event_jobs(status, created_at);
+CREATE INDEX idx_notifications_pending ON notifications(created_at) WHERE status = 'pending';
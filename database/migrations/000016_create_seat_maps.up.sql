@@ -0,0 +1,63 @@
+-- Create seat_maps table
+CREATE TABLE seat_maps (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    location_id UUID NOT NULL REFERENCES locations(id),
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    name VARCHAR(255) NOT NULL,
+    stage_x DOUBLE PRECISION NOT NULL DEFAULT 0,
+    stage_y DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create seat_sections table
+CREATE TABLE seat_sections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (seat_map_id, name)
+);
+
+-- Create seats table
+CREATE TABLE seats (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    section_id UUID NOT NULL REFERENCES seat_sections(id) ON DELETE CASCADE,
+    row_label VARCHAR(20) NOT NULL,
+    number INTEGER NOT NULL,
+    x DOUBLE PRECISION NOT NULL,
+    y DOUBLE PRECISION NOT NULL,
+    accessible BOOLEAN NOT NULL DEFAULT FALSE,
+    obstructed BOOLEAN NOT NULL DEFAULT FALSE,
+    aisle BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (section_id, row_label, number)
+);
+
+-- Create event_seats table, the seats an event sells and the ticket type
+-- each is sold under. A seat is held by the transaction detail that
+-- reserved it until the detail is paid or released.
+CREATE TABLE event_seats (
+    event_id UUID NOT NULL REFERENCES events(id),
+    seat_id UUID NOT NULL REFERENCES seats(id),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'available',
+    transaction_detail_id UUID REFERENCES transaction_details(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, seat_id),
+    CONSTRAINT check_event_seat_status CHECK (status IN ('available', 'held', 'sold')),
+    CONSTRAINT check_event_seat_holder CHECK ((status = 'available') = (transaction_detail_id IS NULL))
+);
+
+ALTER TABLE tickets ADD COLUMN seat_id UUID REFERENCES seats(id);
+
+CREATE INDEX idx_seat_maps_location ON seat_maps(location_id);
+CREATE INDEX idx_seats_seat_map ON seats(seat_map_id);
+CREATE INDEX idx_event_seats_ticket_type ON event_seats(ticket_type_id, status);
+CREATE INDEX idx_event_seats_transaction_detail ON event_seats(transaction_detail_id);
+-- Last line of defence against selling a seat twice
+CREATE UNIQUE INDEX idx_tickets_event_seat ON tickets(event_id, seat_id) WHERE seat_id IS NOT NULL AND status <> 'void';
@@ -0,0 +1,7 @@
+ALTER TABLE transaction_details DROP COLUMN IF EXISTS discount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS promo_code_id;
+
+DROP TABLE IF EXISTS promo_code_redemptions;
+DROP TABLE IF EXISTS promo_code_ticket_types;
+DROP TABLE IF EXISTS promo_code_events;
+DROP TABLE IF EXISTS promo_codes;
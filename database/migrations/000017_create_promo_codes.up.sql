@@ -0,0 +1,60 @@
+-- Create promo_codes table. A code takes either percent_off or amount_off
+-- depending on its kind, and counts its redemptions in redemption_count.
+CREATE TABLE promo_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    code VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    percent_off INTEGER,
+    amount_off DECIMAL(10,2),
+    min_quantity INTEGER NOT NULL DEFAULT 1,
+    max_redemptions INTEGER,
+    max_redemptions_per_user INTEGER,
+    redemption_count INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_promo_code_kind CHECK (
+        (kind = 'percent' AND percent_off BETWEEN 1 AND 100 AND amount_off IS NULL) OR
+        (kind = 'fixed' AND amount_off > 0 AND percent_off IS NULL)
+    ),
+    CONSTRAINT check_promo_code_min_quantity CHECK (min_quantity >= 1),
+    CONSTRAINT check_promo_code_redemptions CHECK (
+        redemption_count >= 0 AND (max_redemptions IS NULL OR redemption_count <= max_redemptions)
+    ),
+    CONSTRAINT check_promo_code_window CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at)
+);
+
+-- Create promo_code_events table, the events a code is restricted to
+CREATE TABLE promo_code_events (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id),
+    PRIMARY KEY (promo_code_id, event_id)
+);
+
+-- Create promo_code_ticket_types table, the ticket types a code is
+-- restricted to
+CREATE TABLE promo_code_ticket_types (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    PRIMARY KEY (promo_code_id, ticket_type_id)
+);
+
+-- Create promo_code_redemptions table, one row per transaction a code was
+-- used for
+CREATE TABLE promo_code_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE transactions ADD COLUMN promo_code_id UUID REFERENCES promo_codes(id);
+ALTER TABLE transaction_details ADD COLUMN discount DECIMAL(10,2) NOT NULL DEFAULT 0;
+
+-- Codes are matched case-insensitively within an organizer
+CREATE UNIQUE INDEX idx_promo_codes_code ON promo_codes(organizer_id, UPPER(code)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_promo_code_redemptions_user ON promo_code_redemptions(promo_code_id, user_id);
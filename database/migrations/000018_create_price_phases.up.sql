@@ -0,0 +1,18 @@
+-- Create price_phases table, the ordered pricing steps of a ticket type. A
+-- phase applies within its window while fewer than sold_limit tickets of
+-- the type are sold.
+CREATE TABLE price_phases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    sold_limit INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (ticket_type_id, position),
+    CONSTRAINT check_price_phase_price CHECK (price >= 0),
+    CONSTRAINT check_price_phase_window CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at),
+    CONSTRAINT check_price_phase_sold_limit CHECK (sold_limit IS NULL OR sold_limit > 0)
+);
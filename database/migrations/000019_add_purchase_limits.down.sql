@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_users_phone;
+DROP INDEX IF EXISTS idx_users_lower_email;
+
+ALTER TABLE ticket_types DROP CONSTRAINT IF EXISTS check_ticket_type_purchase_limits;
+ALTER TABLE ticket_types DROP COLUMN IF EXISTS max_per_user;
+ALTER TABLE ticket_types DROP COLUMN IF EXISTS max_per_transaction;
+
+ALTER TABLE events DROP CONSTRAINT IF EXISTS check_event_purchase_limits;
+ALTER TABLE events DROP COLUMN IF EXISTS limit_by_contact;
+ALTER TABLE events DROP COLUMN IF EXISTS max_per_user;
+ALTER TABLE events DROP COLUMN IF EXISTS max_per_transaction;
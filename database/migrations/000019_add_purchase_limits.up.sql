@@ -0,0 +1,20 @@
+-- A missing limit leaves purchases bounded by the quota alone
+ALTER TABLE events ADD COLUMN max_per_transaction INTEGER;
+ALTER TABLE events ADD COLUMN max_per_user INTEGER;
+-- Count the tickets of every account sharing the buyer's email or phone
+-- toward max_per_user, not only those of the buyer's account
+ALTER TABLE events ADD COLUMN limit_by_contact BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE events ADD CONSTRAINT check_event_purchase_limits CHECK (
+    (max_per_transaction IS NULL OR max_per_transaction > 0) AND
+    (max_per_user IS NULL OR max_per_user > 0)
+);
+
+ALTER TABLE ticket_types ADD COLUMN max_per_transaction INTEGER;
+ALTER TABLE ticket_types ADD COLUMN max_per_user INTEGER;
+ALTER TABLE ticket_types ADD CONSTRAINT check_ticket_type_purchase_limits CHECK (
+    (max_per_transaction IS NULL OR max_per_transaction > 0) AND
+    (max_per_user IS NULL OR max_per_user > 0)
+);
+
+CREATE INDEX idx_users_lower_email ON users(LOWER(email));
+CREATE INDEX idx_users_phone ON users(phone);
@@ -0,0 +1,19 @@
+ALTER TABLE refund_items ALTER COLUMN amount TYPE DECIMAL(10,2);
+
+ALTER TABLE refunds ALTER COLUMN amount TYPE DECIMAL(10,2);
+
+ALTER TABLE transaction_details ALTER COLUMN discount TYPE DECIMAL(10,2);
+ALTER TABLE transaction_details ALTER COLUMN subtotal TYPE DECIMAL(10,2);
+ALTER TABLE transaction_details ALTER COLUMN price_per_ticket TYPE DECIMAL(10,2);
+
+ALTER TABLE transactions DROP COLUMN currency;
+ALTER TABLE transactions ALTER COLUMN total_amount TYPE DECIMAL(10,2);
+
+ALTER TABLE promo_codes DROP CONSTRAINT check_promo_code_currency;
+ALTER TABLE promo_codes DROP COLUMN currency;
+ALTER TABLE promo_codes ALTER COLUMN amount_off TYPE DECIMAL(10,2);
+
+ALTER TABLE price_phases ALTER COLUMN price TYPE DECIMAL(10,2);
+
+ALTER TABLE ticket_types DROP COLUMN currency;
+ALTER TABLE ticket_types ALTER COLUMN price TYPE DECIMAL(10,2);
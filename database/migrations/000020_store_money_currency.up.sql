@@ -0,0 +1,25 @@
+-- Amounts are kept to four decimal places, enough for the minor unit of
+-- every currency, and rows keep the ISO 4217 currency of their amounts.
+-- Amounts stored before were all in IDR.
+ALTER TABLE ticket_types ALTER COLUMN price TYPE NUMERIC(19,4);
+ALTER TABLE ticket_types ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE ticket_types ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE price_phases ALTER COLUMN price TYPE NUMERIC(19,4);
+
+ALTER TABLE promo_codes ALTER COLUMN amount_off TYPE NUMERIC(19,4);
+ALTER TABLE promo_codes ADD COLUMN currency CHAR(3);
+UPDATE promo_codes SET currency = 'IDR' WHERE kind = 'fixed';
+ALTER TABLE promo_codes ADD CONSTRAINT check_promo_code_currency CHECK ((kind = 'fixed') = (currency IS NOT NULL));
+
+ALTER TABLE transactions ALTER COLUMN total_amount TYPE NUMERIC(19,4);
+ALTER TABLE transactions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE transactions ALTER COLUMN currency DROP DEFAULT;
+
+ALTER TABLE transaction_details ALTER COLUMN price_per_ticket TYPE NUMERIC(19,4);
+ALTER TABLE transaction_details ALTER COLUMN subtotal TYPE NUMERIC(19,4);
+ALTER TABLE transaction_details ALTER COLUMN discount TYPE NUMERIC(19,4);
+
+ALTER TABLE refunds ALTER COLUMN amount TYPE NUMERIC(19,4);
+
+ALTER TABLE refund_items ALTER COLUMN amount TYPE NUMERIC(19,4);
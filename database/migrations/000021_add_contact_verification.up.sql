@@ -0,0 +1,4 @@
+-- Only verified contacts tie accounts together for limit_by_contact, so an
+-- account cannot claim someone else's email or phone to share their limit
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN phone_verified_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,4 @@
+DROP INDEX idx_refunds_pending;
+ALTER TABLE refunds DROP CONSTRAINT check_refund_status;
+ALTER TABLE refunds DROP COLUMN failure_reason;
+ALTER TABLE refunds DROP COLUMN status;
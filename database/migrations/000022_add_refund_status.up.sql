@@ -0,0 +1,10 @@
+-- A refund is recorded as pending before the provider is asked for it, then
+-- settled with the provider's answer. Refunds made before this were all
+-- made by the provider.
+ALTER TABLE refunds ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'succeeded';
+ALTER TABLE refunds ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE refunds ADD COLUMN failure_reason TEXT;
+ALTER TABLE refunds ADD CONSTRAINT check_refund_status CHECK (status IN ('pending', 'succeeded', 'failed'));
+
+-- One refund of a transaction at a time is in flight
+CREATE UNIQUE INDEX idx_refunds_pending ON refunds(transaction_id) WHERE status = 'pending';
@@ -10,9 +10,30 @@ CREATE TABLE users (
     phone VARCHAR(50),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    email_verified_at TIMESTAMP WITH TIME ZONE,
+    phone_verified_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create organizers table
+CREATE TABLE organizers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE
 );
 
+-- Create organizer_members table
+CREATE TABLE organizer_members (
+    organizer_id UUID NOT NULL REFERENCES organizers(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id),
+    role VARCHAR(20) NOT NULL DEFAULT 'member',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organizer_id, user_id),
+    CONSTRAINT check_organizer_member_role CHECK (role IN ('owner', 'member'))
+);
+
 -- Create locations table
 CREATE TABLE locations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -24,9 +45,49 @@ CREATE TABLE locations (
     postal_code VARCHAR(20),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organizer_id UUID NOT NULL REFERENCES organizers(id)
+);
+
+-- Create seat_maps table
+CREATE TABLE seat_maps (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    location_id UUID NOT NULL REFERENCES locations(id),
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    name VARCHAR(255) NOT NULL,
+    stage_x DOUBLE PRECISION NOT NULL DEFAULT 0,
+    stage_y DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE
 );
 
+-- Create seat_sections table
+CREATE TABLE seat_sections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (seat_map_id, name)
+);
+
+-- Create seats table
+CREATE TABLE seats (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    seat_map_id UUID NOT NULL REFERENCES seat_maps(id) ON DELETE CASCADE,
+    section_id UUID NOT NULL REFERENCES seat_sections(id) ON DELETE CASCADE,
+    row_label VARCHAR(20) NOT NULL,
+    number INTEGER NOT NULL,
+    x DOUBLE PRECISION NOT NULL,
+    y DOUBLE PRECISION NOT NULL,
+    accessible BOOLEAN NOT NULL DEFAULT FALSE,
+    obstructed BOOLEAN NOT NULL DEFAULT FALSE,
+    aisle BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (section_id, row_label, number)
+);
+
 -- Create schedules table
 CREATE TABLE schedules (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -48,7 +109,20 @@ CREATE TABLE events (
     schedule_id UUID NOT NULL REFERENCES schedules(id),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP WITH TIME ZONE
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    refund_deadline TIMESTAMP WITH TIME ZONE,
+    max_per_transaction INTEGER,
+    max_per_user INTEGER,
+    limit_by_contact BOOLEAN NOT NULL DEFAULT false,
+    CONSTRAINT check_event_status CHECK (
+        status IN ('draft', 'published', 'on_sale', 'sold_out', 'cancelled', 'postponed')
+    ),
+    CONSTRAINT check_event_purchase_limits CHECK (
+        (max_per_transaction IS NULL OR max_per_transaction > 0) AND
+        (max_per_user IS NULL OR max_per_user > 0)
+    )
 );
 
 -- Create ticket_types table
@@ -57,14 +131,71 @@ CREATE TABLE ticket_types (
     event_id UUID NOT NULL REFERENCES events(id),
     name VARCHAR(255) NOT NULL,
     description TEXT,
-    price DECIMAL(10,2) NOT NULL,
+    price NUMERIC(19,4) NOT NULL,
     quota INTEGER NOT NULL,
     remaining_quota INTEGER NOT NULL,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE,
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    sale_start TIMESTAMP WITH TIME ZONE,
+    sale_end TIMESTAMP WITH TIME ZONE,
+    max_per_transaction INTEGER,
+    max_per_user INTEGER,
+    currency CHAR(3) NOT NULL,
     CONSTRAINT check_quota CHECK (quota >= 0),
-    CONSTRAINT check_remaining_quota CHECK (remaining_quota >= 0)
+    CONSTRAINT check_remaining_quota CHECK (remaining_quota >= 0),
+    CONSTRAINT check_sale_window CHECK (sale_start IS NULL OR sale_end IS NULL OR sale_end > sale_start),
+    CONSTRAINT check_ticket_type_purchase_limits CHECK (
+        (max_per_transaction IS NULL OR max_per_transaction > 0) AND
+        (max_per_user IS NULL OR max_per_user > 0)
+    )
+);
+
+-- Create promo_codes table. A code takes either percent_off or amount_off
+-- depending on its kind, and counts its redemptions in redemption_count.
+CREATE TABLE promo_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    organizer_id UUID NOT NULL REFERENCES organizers(id),
+    code VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    percent_off INTEGER,
+    amount_off NUMERIC(19,4),
+    min_quantity INTEGER NOT NULL DEFAULT 1,
+    max_redemptions INTEGER,
+    max_redemptions_per_user INTEGER,
+    redemption_count INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    currency CHAR(3),
+    CONSTRAINT check_promo_code_kind CHECK (
+        (kind = 'percent' AND percent_off BETWEEN 1 AND 100 AND amount_off IS NULL) OR
+        (kind = 'fixed' AND amount_off > 0 AND percent_off IS NULL)
+    ),
+    CONSTRAINT check_promo_code_currency CHECK ((kind = 'fixed') = (currency IS NOT NULL)),
+    CONSTRAINT check_promo_code_min_quantity CHECK (min_quantity >= 1),
+    CONSTRAINT check_promo_code_redemptions CHECK (
+        redemption_count >= 0 AND (max_redemptions IS NULL OR redemption_count <= max_redemptions)
+    ),
+    CONSTRAINT check_promo_code_window CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at)
+);
+
+-- Create promo_code_events table, the events a code is restricted to
+CREATE TABLE promo_code_events (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    event_id UUID NOT NULL REFERENCES events(id),
+    PRIMARY KEY (promo_code_id, event_id)
+);
+
+-- Create promo_code_ticket_types table, the ticket types a code is
+-- restricted to
+CREATE TABLE promo_code_ticket_types (
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    PRIMARY KEY (promo_code_id, ticket_type_id)
 );
 
 -- Create transactions table
@@ -72,7 +203,7 @@ CREATE TABLE transactions (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     user_id UUID NOT NULL REFERENCES users(id),
     event_id UUID NOT NULL REFERENCES events(id),
-    total_amount DECIMAL(10,2) NOT NULL,
+    total_amount NUMERIC(19,4) NOT NULL,
     status VARCHAR(50) NOT NULL,
     payment_method VARCHAR(50) NOT NULL,
     payment_status VARCHAR(50) NOT NULL,
@@ -80,7 +211,12 @@ CREATE TABLE transactions (
     payment_callback TEXT,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP WITH TIME ZONE
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    payment_provider VARCHAR(50),
+    payment_reference VARCHAR(255),
+    promo_code_id UUID REFERENCES promo_codes(id),
+    currency CHAR(3) NOT NULL
 );
 
 -- Create transaction_details table
@@ -89,12 +225,239 @@ CREATE TABLE transaction_details (
     transaction_id UUID NOT NULL REFERENCES transactions(id),
     ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
     quantity INTEGER NOT NULL,
-    price_per_ticket DECIMAL(10,2) NOT NULL,
-    subtotal DECIMAL(10,2) NOT NULL,
+    price_per_ticket NUMERIC(19,4) NOT NULL,
+    subtotal NUMERIC(19,4) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    refunded_quantity INTEGER NOT NULL DEFAULT 0,
+    discount NUMERIC(19,4) NOT NULL DEFAULT 0,
+    CONSTRAINT check_quantity CHECK (quantity > 0),
+    CONSTRAINT check_refunded_quantity CHECK (refunded_quantity >= 0 AND refunded_quantity <= quantity)
+);
+
+-- Create transaction_status_history table
+CREATE TABLE transaction_status_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    field VARCHAR(50) NOT NULL,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_field CHECK (field IN ('status', 'payment_status'))
+);
+
+-- Create payment_webhook_nonces table
+CREATE TABLE payment_webhook_nonces (
+    provider VARCHAR(50) NOT NULL,
+    nonce VARCHAR(255) NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, nonce)
+);
+
+-- Create idempotency_keys table
+CREATE TABLE idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+-- Create refunds table
+CREATE TABLE refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    amount NUMERIC(19,4) NOT NULL,
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    provider_reference VARCHAR(255),
     created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
     deleted_at TIMESTAMP WITH TIME ZONE,
-    CONSTRAINT check_quantity CHECK (quantity > 0)
+    status VARCHAR(20) NOT NULL,
+    failure_reason TEXT,
+    CONSTRAINT check_refund_amount CHECK (amount >= 0),
+    CONSTRAINT check_refund_status CHECK (status IN ('pending', 'succeeded', 'failed'))
+);
+
+-- Create refund_items table
+CREATE TABLE refund_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    refund_id UUID NOT NULL REFERENCES refunds(id),
+    transaction_detail_id UUID NOT NULL REFERENCES transaction_details(id),
+    quantity INTEGER NOT NULL,
+    amount NUMERIC(19,4) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_refund_item_quantity CHECK (quantity > 0)
+);
+
+-- Create tickets table
+CREATE TABLE tickets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES transactions(id),
+    transaction_detail_id UUID NOT NULL REFERENCES transaction_details(id),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    event_id UUID NOT NULL REFERENCES events(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    code VARCHAR(64) UNIQUE NOT NULL,
+    holder_name VARCHAR(255),
+    holder_email VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE,
+    used_gate VARCHAR(100),
+    seat_id UUID REFERENCES seats(id),
+    CONSTRAINT check_ticket_status CHECK (status IN ('valid', 'used', 'void'))
+);
+
+-- Create ticket_scans table
+CREATE TABLE ticket_scans (
+    id UUID PRIMARY KEY,
+    event_id UUID NOT NULL REFERENCES events(id),
+    ticket_id UUID REFERENCES tickets(id),
+    gate VARCHAR(100) NOT NULL,
+    device_id VARCHAR(100),
+    source VARCHAR(20) NOT NULL,
+    result VARCHAR(20) NOT NULL,
+    scanned_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_ticket_scan_source CHECK (source IN ('online', 'offline')),
+    CONSTRAINT check_ticket_scan_result CHECK (result IN ('valid', 'already_used', 'wrong_event', 'voided', 'not_found', 'invalid_token'))
+);
+
+-- Create refresh_tokens table
+CREATE TABLE refresh_tokens (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id),
+    family_id UUID NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    replaced_by UUID REFERENCES refresh_tokens(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create roles table
+CREATE TABLE roles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create permissions table
+CREATE TABLE permissions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create role_permissions table
+CREATE TABLE role_permissions (
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id UUID NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+-- Create user_roles table
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id),
+    role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role_id)
+);
+
+-- Create event_jobs table
+CREATE TABLE event_jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_id UUID NOT NULL REFERENCES events(id),
+    kind VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason TEXT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    last_transaction_id UUID,
+    last_error TEXT,
+    locked_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT check_event_job_kind CHECK (kind IN ('cancel', 'postpone')),
+    CONSTRAINT check_event_job_status CHECK (status IN ('pending', 'running', 'completed'))
+);
+
+-- Create notifications table
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id),
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_notification_status CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+-- Create event_seats table, the seats an event sells and the ticket type
+-- each is sold under. A seat is held by the transaction detail that
+-- reserved it until the detail is paid or released.
+CREATE TABLE event_seats (
+    event_id UUID NOT NULL REFERENCES events(id),
+    seat_id UUID NOT NULL REFERENCES seats(id),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'available',
+    transaction_detail_id UUID REFERENCES transaction_details(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (event_id, seat_id),
+    CONSTRAINT check_event_seat_status CHECK (status IN ('available', 'held', 'sold')),
+    CONSTRAINT check_event_seat_holder CHECK ((status = 'available') = (transaction_detail_id IS NULL))
+);
+
+-- Create promo_code_redemptions table, one row per transaction a code was
+-- used for
+CREATE TABLE promo_code_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id),
+    user_id UUID NOT NULL REFERENCES users(id),
+    transaction_id UUID NOT NULL UNIQUE REFERENCES transactions(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create price_phases table, the ordered pricing steps of a ticket type. A
+-- phase applies within its window while fewer than sold_limit tickets of
+-- the type are sold.
+CREATE TABLE price_phases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    ticket_type_id UUID NOT NULL REFERENCES ticket_types(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    price NUMERIC(19,4) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    sold_limit INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (ticket_type_id, position),
+    CONSTRAINT check_price_phase_price CHECK (price >= 0),
+    CONSTRAINT check_price_phase_window CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at),
+    CONSTRAINT check_price_phase_sold_limit CHECK (sold_limit IS NULL OR sold_limit > 0)
 );
 
 -- Create indexes for better query performance
@@ -104,5 +467,65 @@ CREATE INDEX idx_events_schedule ON events(schedule_id);
 CREATE INDEX idx_ticket_types_event ON ticket_types(event_id);
 CREATE INDEX idx_transactions_user ON transactions(user_id);
 CREATE INDEX idx_transactions_event ON transactions(event_id);
+CREATE INDEX idx_transactions_pending_expiry ON transactions(expires_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX idx_transactions_payment_reference ON transactions(payment_provider, payment_reference);
 CREATE INDEX idx_transaction_details_transaction ON transaction_details(transaction_id);
 CREATE INDEX idx_transaction_details_ticket_type ON transaction_details(ticket_type_id);
+CREATE INDEX idx_transaction_status_history_transaction ON transaction_status_history(transaction_id, created_at);
+CREATE INDEX idx_payment_webhook_nonces_received ON payment_webhook_nonces(received_at);
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+CREATE INDEX idx_refunds_transaction ON refunds(transaction_id);
+CREATE UNIQUE INDEX idx_refunds_pending ON refunds(transaction_id) WHERE status = 'pending';
+CREATE INDEX idx_refund_items_refund ON refund_items(refund_id);
+CREATE INDEX idx_tickets_transaction ON tickets(transaction_id);
+CREATE INDEX idx_tickets_transaction_detail ON tickets(transaction_detail_id);
+CREATE INDEX idx_tickets_user ON tickets(user_id);
+CREATE INDEX idx_tickets_event ON tickets(event_id);
+CREATE INDEX idx_tickets_ticket_type_status ON tickets(ticket_type_id, status);
+CREATE INDEX idx_ticket_scans_event_result ON ticket_scans(event_id, result);
+CREATE INDEX idx_ticket_scans_ticket ON ticket_scans(ticket_id);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE INDEX idx_user_roles_role ON user_roles(role_id);
+CREATE INDEX idx_organizer_members_user ON organizer_members(user_id);
+CREATE INDEX idx_events_organizer ON events(organizer_id);
+CREATE INDEX idx_locations_organizer ON locations(organizer_id);
+CREATE INDEX idx_ticket_types_organizer ON ticket_types(organizer_id);
+CREATE INDEX idx_events_status ON events(status);
+CREATE UNIQUE INDEX idx_event_jobs_active ON event_jobs(event_id) WHERE status <> 'completed';
+CREATE INDEX idx_event_jobs_status ON event_jobs(status, created_at);
+CREATE INDEX idx_notifications_pending ON notifications(created_at) WHERE status = 'pending';
+CREATE INDEX idx_seat_maps_location ON seat_maps(location_id);
+CREATE INDEX idx_seats_seat_map ON seats(seat_map_id);
+CREATE INDEX idx_event_seats_ticket_type ON event_seats(ticket_type_id, status);
+CREATE INDEX idx_event_seats_transaction_detail ON event_seats(transaction_detail_id);
+CREATE UNIQUE INDEX idx_tickets_event_seat ON tickets(event_id, seat_id) WHERE seat_id IS NOT NULL AND status <> 'void';
+CREATE UNIQUE INDEX idx_promo_codes_code ON promo_codes(organizer_id, UPPER(code)) WHERE deleted_at IS NULL;
+CREATE INDEX idx_promo_code_redemptions_user ON promo_code_redemptions(promo_code_id, user_id);
+CREATE INDEX idx_users_lower_email ON users(LOWER(email));
+CREATE INDEX idx_users_phone ON users(phone);
+
+-- Seed roles and permissions
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Full access'),
+    ('organizer', 'Manages the events of their organizers'),
+    ('staff', 'Checks in tickets at the gate'),
+    ('customer', 'Buys tickets and manages their own transactions');
+
+INSERT INTO permissions (name, description) VALUES
+    ('events.manage', 'Create events and manage owned events'),
+    ('events.manage_any', 'Manage every event'),
+    ('catalog.manage', 'Manage locations and schedules'),
+    ('transactions.create', 'Buy tickets'),
+    ('transactions.read_any', 'Read every transaction'),
+    ('transactions.manage', 'Change transaction and payment status and issue refunds'),
+    ('tickets.check_in', 'Check in tickets for every event'),
+    ('users.manage', 'Manage users and their roles'),
+    ('organizers.manage', 'Manage every organizer and its members');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE (r.name = 'admin')
+OR (r.name = 'organizer' AND p.name IN ('events.manage', 'catalog.manage', 'transactions.create'))
+OR (r.name = 'staff' AND p.name IN ('tickets.check_in'))
+OR (r.name = 'customer' AND p.name IN ('transactions.create'));
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuthHandler struct {
+	service *service.AuthService
+}
+
+func NewAuthHandler(service *service.AuthService) *AuthHandler {
+	return &AuthHandler{
+		service: service,
+	}
+}
+
+func (h *AuthHandler) RegisterRoutes(app *fiber.App) {
+	auth := app.Group("/v1/auth")
+	auth.Post("/register", h.Register)
+	auth.Post("/login", h.Login)
+	auth.Post("/refresh", h.Refresh)
+	auth.Post("/logout", h.Logout)
+	auth.Get("/me", middleware.Authenticate(h.service), h.Me)
+}
+
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var req service.RegisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	res, err := h.service.Register(&req)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "User registered successfully", res)
+	case errors.Is(err, service.ErrEmailRegistered), errors.Is(err, service.ErrPhoneRegistered):
+		return utils.SendConflictResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidPassword):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var req service.LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	res, err := h.service.Login(&req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Logged in successfully", res)
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return utils.SendUnauthorizedResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req service.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	pair, err := h.service.Refresh(&req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Token refreshed successfully", pair)
+	case errors.Is(err, service.ErrInvalidRefreshToken):
+		return utils.SendUnauthorizedResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req service.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	err := h.service.Logout(&req)
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Logged out successfully", nil)
+}
+
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	return utils.SendSuccessResponse(c, "User retrieved successfully", middleware.CurrentPrincipal(c))
+}
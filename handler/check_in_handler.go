@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CheckInHandler struct {
+	service *service.CheckInService
+	auth    *service.AuthService
+}
+
+func NewCheckInHandler(service *service.CheckInService, auth *service.AuthService) *CheckInHandler {
+	return &CheckInHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *CheckInHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	// Organizers work the gates of their own events, which the service
+	// checks per event.
+	canCheckIn := middleware.RequirePermission(
+		service.PermissionTicketsCheckIn,
+		service.PermissionEventsManage,
+		service.PermissionEventsManageAny,
+	)
+
+	events := app.Group("/v1/events")
+	events.Post("/:id/check-in", authenticate, canCheckIn, h.CheckIn)
+	events.Get("/:id/check-ins", authenticate, canCheckIn, h.GetCheckInCounts)
+	events.Get("/:id/scanner-keys", authenticate, canCheckIn, h.GetScannerKeys)
+	events.Get("/:id/scans", authenticate, canCheckIn, h.GetScans)
+	events.Post("/:id/scans/offline", authenticate, canCheckIn, h.ReconcileScans)
+}
+
+// checkInStatusCodes maps every rejected scan to an error status, while the
+// body still carries the result for the scanner to display.
+var checkInStatusCodes = map[models.ScanResult]int{
+	models.ScanResultValid:        fiber.StatusOK,
+	models.ScanResultAlreadyUsed:  fiber.StatusConflict,
+	models.ScanResultWrongEvent:   fiber.StatusUnprocessableEntity,
+	models.ScanResultVoided:       fiber.StatusGone,
+	models.ScanResultNotFound:     fiber.StatusNotFound,
+	models.ScanResultInvalidToken: fiber.StatusUnprocessableEntity,
+}
+
+var checkInMessages = map[models.ScanResult]string{
+	models.ScanResultValid:        "Ticket checked in successfully",
+	models.ScanResultAlreadyUsed:  "Ticket has already been used",
+	models.ScanResultWrongEvent:   "Ticket is for a different event",
+	models.ScanResultVoided:       "Ticket has been voided",
+	models.ScanResultNotFound:     "Ticket not found",
+	models.ScanResultInvalidToken: "Ticket token is invalid or expired",
+}
+
+func (h *CheckInHandler) CheckIn(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	var req service.CheckInRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	res, err := h.service.CheckIn(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendResponse(c, checkInStatusCodes[res.Result], checkInMessages[res.Result], res)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	case errors.Is(err, service.ErrInvalidCheckIn):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *CheckInHandler) GetCheckInCounts(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	counts, err := h.service.GetCheckInCounts(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.SendNotFoundResponse(c, "Event not found")
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			return utils.SendForbiddenResponse(c, err.Error())
+		}
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Check-in counts retrieved successfully", counts)
+}
+
+func (h *CheckInHandler) GetScannerKeys(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	keys, err := h.service.GetScannerKeys(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return utils.SendNotFoundResponse(c, "Event not found")
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			return utils.SendForbiddenResponse(c, err.Error())
+		}
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Scanner keys retrieved successfully", keys)
+}
+
+func (h *CheckInHandler) GetScans(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetScans(middleware.CurrentPrincipal(c), id, q)
+	switch {
+	case err == nil:
+		return sendPage(c, "Scans retrieved successfully", page)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	case errors.Is(err, service.ErrInvalidCheckIn), errors.Is(err, repository.ErrInvalidListQuery):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *CheckInHandler) ReconcileScans(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	var req service.ReconcileScansRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	scans, err := h.service.ReconcileScans(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Scans reconciled successfully", scans)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	case errors.Is(err, service.ErrInvalidCheckIn):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
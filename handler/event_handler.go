@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -10,30 +14,45 @@ import (
 
 type EventHandler struct {
 	service *service.EventService
+	auth    *service.AuthService
 }
 
-func NewEventHandler(service *service.EventService) *EventHandler {
+func NewEventHandler(service *service.EventService, auth *service.AuthService) *EventHandler {
 	return &EventHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
 func (h *EventHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	optionalAuthenticate := middleware.OptionalAuthenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionEventsManage)
+
 	events := app.Group("/v1/events")
-	events.Get("/", h.GetAllEvents)
-	events.Get("/:id", h.GetEventById)
-	events.Post("/", h.CreateEvent)
-	events.Put("/:id", h.UpdateEvent)
-	events.Delete("/:id", h.DeleteEvent)
+	events.Get("/", optionalAuthenticate, h.GetAllEvents)
+	events.Get("/:id", optionalAuthenticate, h.GetEventById)
+	events.Post("/", authenticate, canManage, h.CreateEvent)
+	events.Put("/:id", authenticate, canManage, h.UpdateEvent)
+	events.Put("/:id/status", authenticate, canManage, h.ChangeEventStatus)
+	events.Delete("/:id", authenticate, canManage, h.DeleteEvent)
 }
 
 func (h *EventHandler) GetAllEvents(c *fiber.Ctx) error {
-	events, err := h.service.GetAllEvents()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllEvents(middleware.CurrentPrincipal(c), q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Events retrieved successfully", events)
+	return sendPage(c, "Events retrieved successfully", page)
 }
 
 func (h *EventHandler) GetEventById(c *fiber.Ctx) error {
@@ -42,7 +61,7 @@ func (h *EventHandler) GetEventById(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid event ID")
 	}
 
-	event, err := h.service.GetEventById(id)
+	event, err := h.service.GetEventById(middleware.CurrentPrincipal(c), id)
 	if err != nil {
 		return utils.SendNotFoundResponse(c, "Event not found")
 	}
@@ -56,7 +75,13 @@ func (h *EventHandler) CreateEvent(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	event, err := h.service.CreateEvent(&req)
+	event, err := h.service.CreateEvent(middleware.CurrentPrincipal(c), &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, service.ErrInvalidOrganizer) || errors.Is(err, service.ErrInvalidEvent) || errors.Is(err, service.ErrInvalidPurchaseLimits) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -75,7 +100,16 @@ func (h *EventHandler) UpdateEvent(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	event, err := h.service.UpdateEvent(id, &req)
+	event, err := h.service.UpdateEvent(middleware.CurrentPrincipal(c), id, &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
+	if errors.Is(err, service.ErrInvalidEvent) || errors.Is(err, service.ErrInvalidPurchaseLimits) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -83,13 +117,47 @@ func (h *EventHandler) UpdateEvent(c *fiber.Ctx) error {
 	return utils.SendSuccessResponse(c, "Event updated successfully", event)
 }
 
+func (h *EventHandler) ChangeEventStatus(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	var req service.ChangeEventStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	event, err := h.service.ChangeEventStatus(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Event status updated successfully", event)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	case errors.Is(err, service.ErrInvalidStatus):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatusTransition):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
 func (h *EventHandler) DeleteEvent(c *fiber.Ctx) error {
 	id, err := uuid.Parse(c.Params("id"))
 	if err != nil {
 		return utils.SendBadRequestResponse(c, "Invalid event ID")
 	}
 
-	err = h.service.DeleteEvent(id)
+	err = h.service.DeleteEvent(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
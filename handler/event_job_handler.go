@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/models"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type EventJobHandler struct {
+	service *service.EventJobService
+	auth    *service.AuthService
+}
+
+func NewEventJobHandler(service *service.EventJobService, auth *service.AuthService) *EventJobHandler {
+	return &EventJobHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *EventJobHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionEventsManage)
+
+	events := app.Group("/v1/events")
+	events.Get("/:id/jobs", authenticate, canManage, h.GetJobsByEventId)
+	events.Post("/:id/cancel", authenticate, canManage, h.CancelEvent)
+	events.Post("/:id/postpone", authenticate, canManage, h.PostponeEvent)
+}
+
+func (h *EventJobHandler) GetJobsByEventId(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	jobs, err := h.service.GetJobsByEventId(middleware.CurrentPrincipal(c), id)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Event jobs retrieved successfully", jobs)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *EventJobHandler) CancelEvent(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	var req service.CancelEventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	job, err := h.service.CancelEvent(middleware.CurrentPrincipal(c), id, &req)
+	return sendEventJob(c, "Event cancelled, refunds are being processed", job, err)
+}
+
+func (h *EventJobHandler) PostponeEvent(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	var req service.PostponeEventRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	job, err := h.service.PostponeEvent(middleware.CurrentPrincipal(c), id, &req)
+	return sendEventJob(c, "Event postponed, buyers are being notified", job, err)
+}
+
+// sendEventJob answers 202 Accepted with a job that was started, as its
+// work is still ahead. Progress is polled through GET /v1/events/:id/jobs.
+func sendEventJob(c *fiber.Ctx, message string, job *models.EventJob, err error) error {
+	switch {
+	case err == nil:
+		return utils.SendResponse(c, fiber.StatusAccepted, message, job)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Event not found")
+	case errors.Is(err, service.ErrInvalidEvent):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatusTransition), errors.Is(err, service.ErrEventJobActive):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"go-ticket/repository"
+	"go-ticket/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// listParams are the query parameters that shape a page rather than
+// filter it.
+var listParams = map[string]bool{
+	"limit":         true,
+	"cursor":        true,
+	"sort":          true,
+	"include_total": true,
+}
+
+// parseListQuery reads limit, cursor, sort and include_total from the
+// query string and treats every other parameter as a filter, which the
+// repository checks against the filters the list allows.
+func parseListQuery(c *fiber.Ctx) (*repository.ListQuery, error) {
+	q := &repository.ListQuery{
+		Cursor:  c.Query("cursor"),
+		Sort:    repository.ParseSort(c.Query("sort")),
+		Filters: map[string]string{},
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%w: limit must be a positive number", repository.ErrInvalidListQuery)
+		}
+		q.Limit = n
+	}
+
+	if includeTotal := c.Query("include_total"); includeTotal != "" {
+		b, err := strconv.ParseBool(includeTotal)
+		if err != nil {
+			return nil, fmt.Errorf("%w: include_total must be a boolean", repository.ErrInvalidListQuery)
+		}
+		q.IncludeTotal = b
+	}
+
+	for key, value := range c.Queries() {
+		if !listParams[key] {
+			q.Filters[key] = value
+		}
+	}
+
+	return q, nil
+}
+
+func sendPage[T any](c *fiber.Ctx, message string, page *repository.Page[T]) error {
+	return utils.SendPageResponse(c, message, page.Items, &utils.Pagination{
+		Limit:      page.Limit,
+		NextCursor: page.NextCursor,
+		HasMore:    page.NextCursor != "",
+		Total:      page.Total,
+	})
+}
@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -10,31 +14,44 @@ import (
 
 type LocationHandler struct {
 	service *service.LocationService
+	auth    *service.AuthService
 }
 
-func NewLocationHandler(service *service.LocationService) *LocationHandler {
+func NewLocationHandler(service *service.LocationService, auth *service.AuthService) *LocationHandler {
 	return &LocationHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
 func (h *LocationHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionCatalogManage)
+
 	locations := app.Group("/v1/locations")
 	locations.Get("/", h.GetAllLocations)
 	locations.Get("/:id", h.GetLocationById)
-	locations.Post("/", h.CreateLocation)
-	locations.Put("/:id", h.UpdateLocation)
-	locations.Delete("/:id", h.DeleteLocation)
+	locations.Post("/", authenticate, canManage, h.CreateLocation)
+	locations.Put("/:id", authenticate, canManage, h.UpdateLocation)
+	locations.Delete("/:id", authenticate, canManage, h.DeleteLocation)
 	locations.Post("/search", h.SearchLocations)
 }
 
 func (h *LocationHandler) GetAllLocations(c *fiber.Ctx) error {
-	locations, err := h.service.GetAllLocations()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllLocations(q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Locations retrieved successfully", locations)
+	return sendPage(c, "Locations retrieved successfully", page)
 }
 
 func (h *LocationHandler) GetLocationById(c *fiber.Ctx) error {
@@ -57,7 +74,13 @@ func (h *LocationHandler) CreateLocation(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	location, err := h.service.CreateLocation(&req)
+	location, err := h.service.CreateLocation(middleware.CurrentPrincipal(c), &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, service.ErrInvalidOrganizer) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -76,7 +99,13 @@ func (h *LocationHandler) UpdateLocation(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	location, err := h.service.UpdateLocation(id, &req)
+	location, err := h.service.UpdateLocation(middleware.CurrentPrincipal(c), id, &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Location not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -90,7 +119,13 @@ func (h *LocationHandler) DeleteLocation(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid location ID")
 	}
 
-	err = h.service.DeleteLocation(id)
+	err = h.service.DeleteLocation(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Location not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
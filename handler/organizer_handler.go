@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type OrganizerHandler struct {
+	service *service.OrganizerService
+	auth    *service.AuthService
+}
+
+func NewOrganizerHandler(service *service.OrganizerService, auth *service.AuthService) *OrganizerHandler {
+	return &OrganizerHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *OrganizerHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+
+	organizers := app.Group("/v1/organizers")
+	organizers.Get("/", authenticate, h.GetOrganizers)
+	organizers.Get("/:id", authenticate, h.GetOrganizerById)
+	organizers.Post("/", authenticate, middleware.RequirePermission(service.PermissionOrganizersManage), h.CreateOrganizer)
+	organizers.Put("/:id", authenticate, h.UpdateOrganizer)
+	organizers.Get("/:id/members", authenticate, h.GetMembers)
+	organizers.Put("/:id/members/:userId", authenticate, h.UpdateMember)
+	organizers.Delete("/:id/members/:userId", authenticate, h.RemoveMember)
+}
+
+func (h *OrganizerHandler) GetOrganizers(c *fiber.Ctx) error {
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetOrganizers(middleware.CurrentPrincipal(c), q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return sendPage(c, "Organizers retrieved successfully", page)
+}
+
+func (h *OrganizerHandler) GetOrganizerById(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid organizer ID")
+	}
+
+	organizer, err := h.service.GetOrganizerById(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "Organizer not found")
+	}
+
+	return utils.SendSuccessResponse(c, "Organizer retrieved successfully", organizer)
+}
+
+func (h *OrganizerHandler) CreateOrganizer(c *fiber.Ctx) error {
+	var req service.CreateOrganizerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	organizer, err := h.service.CreateOrganizer(middleware.CurrentPrincipal(c), &req)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "Organizer created successfully", organizer)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Owner not found")
+	case errors.Is(err, service.ErrInvalidOrganizer):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *OrganizerHandler) UpdateOrganizer(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid organizer ID")
+	}
+
+	var req service.UpdateOrganizerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	organizer, err := h.service.UpdateOrganizer(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Organizer updated successfully", organizer)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Organizer not found")
+	case errors.Is(err, service.ErrInvalidOrganizer):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *OrganizerHandler) GetMembers(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid organizer ID")
+	}
+
+	members, err := h.service.GetMembers(middleware.CurrentPrincipal(c), id)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Organizer members retrieved successfully", members)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Organizer not found")
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *OrganizerHandler) UpdateMember(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid organizer ID")
+	}
+
+	userId, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req service.UpdateOrganizerMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	member, err := h.service.UpdateMember(middleware.CurrentPrincipal(c), id, userId, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Organizer member updated successfully", member)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Organizer or user not found")
+	case errors.Is(err, service.ErrInvalidOrganizer):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *OrganizerHandler) RemoveMember(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid organizer ID")
+	}
+
+	userId, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid user ID")
+	}
+
+	err = h.service.RemoveMember(middleware.CurrentPrincipal(c), id, userId)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Organizer member removed successfully", nil)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Organizer member not found")
+	case errors.Is(err, service.ErrInvalidOrganizer):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
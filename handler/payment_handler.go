@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/payment"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentHandler struct {
+	service *service.PaymentService
+	fake    *payment.FakeGateway
+}
+
+// NewPaymentHandler creates the payment handler. fake may be nil, in which
+// case the fake provider routes are not registered.
+func NewPaymentHandler(service *service.PaymentService, fake *payment.FakeGateway) *PaymentHandler {
+	return &PaymentHandler{
+		service: service,
+		fake:    fake,
+	}
+}
+
+func (h *PaymentHandler) RegisterRoutes(app *fiber.App) {
+	payments := app.Group("/v1/payments")
+	payments.Post("/webhook/:provider", h.HandleWebhook)
+
+	if h.fake != nil {
+		payments.Get("/fake/:reference", h.GetFakeCharge)
+		payments.Post("/fake/:reference/pay", h.SimulateFakePayment)
+		payments.Post("/fake/:reference/fail", h.SimulateFakeFailure)
+	}
+}
+
+func (h *PaymentHandler) HandleWebhook(c *fiber.Ctx) error {
+	err := h.service.HandleWebhook(&service.WebhookRequest{
+		Provider:  c.Params("provider"),
+		Signature: c.Get(payment.HeaderSignature),
+		Timestamp: c.Get(payment.HeaderTimestamp),
+		Nonce:     c.Get(payment.HeaderNonce),
+		Body:      append([]byte(nil), c.Body()...),
+	})
+
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Webhook processed successfully", nil)
+	case errors.Is(err, service.ErrUnknownPaymentProvider):
+		return utils.SendNotFoundResponse(c, "Payment provider not found")
+	case errors.Is(err, payment.ErrInvalidSignature), errors.Is(err, payment.ErrStaleWebhook):
+		return utils.SendUnauthorizedResponse(c, err.Error())
+	case errors.Is(err, service.ErrWebhookReplay):
+		return utils.SendConflictResponse(c, err.Error())
+	case errors.Is(err, payment.ErrInvalidPayload):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	case errors.Is(err, service.ErrInvalidStatusTransition):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *PaymentHandler) GetFakeCharge(c *fiber.Ctx) error {
+	reference := c.Params("reference")
+
+	status, err := h.fake.GetChargeStatus(reference)
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "Charge not found")
+	}
+
+	return utils.SendSuccessResponse(c, "Charge retrieved successfully", fiber.Map{
+		"reference": reference,
+		"status":    status,
+	})
+}
+
+func (h *PaymentHandler) SimulateFakePayment(c *fiber.Ctx) error {
+	return h.simulate(c, payment.ChargeStatusPaid, "Payment simulated successfully")
+}
+
+func (h *PaymentHandler) SimulateFakeFailure(c *fiber.Ctx) error {
+	return h.simulate(c, payment.ChargeStatusFailed, "Payment failure simulated successfully")
+}
+
+func (h *PaymentHandler) simulate(c *fiber.Ctx, status payment.ChargeStatus, message string) error {
+	err := h.fake.Simulate(c.Params("reference"), status)
+	if errors.Is(err, payment.ErrChargeNotFound) {
+		return utils.SendNotFoundResponse(c, "Charge not found")
+	}
+	if errors.Is(err, payment.ErrChargeSettled) {
+		return utils.SendConflictResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, message, nil)
+}
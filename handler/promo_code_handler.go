@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PromoCodeHandler struct {
+	service *service.PromoCodeService
+	auth    *service.AuthService
+}
+
+func NewPromoCodeHandler(service *service.PromoCodeService, auth *service.AuthService) *PromoCodeHandler {
+	return &PromoCodeHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *PromoCodeHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionEventsManage)
+
+	promoCodes := app.Group("/v1/promo-codes")
+	promoCodes.Get("/", authenticate, canManage, h.GetPromoCodes)
+	promoCodes.Get("/:id", authenticate, canManage, h.GetPromoCodeById)
+	promoCodes.Post("/", authenticate, canManage, h.CreatePromoCode)
+	promoCodes.Delete("/:id", authenticate, canManage, h.DeletePromoCode)
+}
+
+func (h *PromoCodeHandler) GetPromoCodes(c *fiber.Ctx) error {
+	promoCodes, err := h.service.GetPromoCodes(middleware.CurrentPrincipal(c))
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Promo codes retrieved successfully", promoCodes)
+}
+
+func (h *PromoCodeHandler) GetPromoCodeById(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid promo code ID")
+	}
+
+	promoCode, err := h.service.GetPromoCodeById(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Promo code not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Promo code retrieved successfully", promoCode)
+}
+
+func (h *PromoCodeHandler) CreatePromoCode(c *fiber.Ctx) error {
+	var req service.CreatePromoCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	promoCode, err := h.service.CreatePromoCode(middleware.CurrentPrincipal(c), &req)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "Promo code created successfully", promoCode)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidPromoCode), errors.Is(err, service.ErrInvalidOrganizer):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *PromoCodeHandler) DeletePromoCode(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid promo code ID")
+	}
+
+	err = h.service.DeletePromoCode(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Promo code not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Promo code deleted successfully", nil)
+}
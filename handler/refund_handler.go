@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/payment"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type RefundHandler struct {
+	service *service.RefundService
+	auth    *service.AuthService
+}
+
+func NewRefundHandler(service *service.RefundService, auth *service.AuthService) *RefundHandler {
+	return &RefundHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *RefundHandler) RegisterRoutes(app *fiber.App) {
+	transactions := app.Group("/v1/transactions")
+	transactions.Get("/:id/refunds", middleware.Authenticate(h.auth), h.GetRefundsByTransactionId)
+	transactions.Post("/:id/refund",
+		middleware.Authenticate(h.auth),
+		middleware.RequirePermission(service.PermissionTransactionsManage),
+		h.RefundTransaction,
+	)
+	transactions.Post("/:id/postponement-refund", middleware.Authenticate(h.auth), h.RefundPostponed)
+}
+
+func (h *RefundHandler) GetRefundsByTransactionId(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	refunds, err := h.service.GetRefundsByTransactionId(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	}
+
+	return utils.SendSuccessResponse(c, "Refunds retrieved successfully", refunds)
+}
+
+func (h *RefundHandler) RefundTransaction(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	var req service.RefundTransactionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	refund, err := h.service.RefundTransaction(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "Transaction refunded successfully", refund)
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidRefund):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, service.ErrRefundNotAllowed), errors.Is(err, service.ErrInvalidStatusTransition),
+		errors.Is(err, payment.ErrRefundNotAllowed), errors.Is(err, payment.ErrRefundExceedsCharge):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *RefundHandler) RefundPostponed(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	refund, err := h.service.RefundPostponed(middleware.CurrentPrincipal(c), id)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "Transaction refunded successfully", refund)
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidRefund):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, service.ErrRefundDeadlinePassed), errors.Is(err, service.ErrEventNotPostponed),
+		errors.Is(err, service.ErrRefundNotAllowed),
+		errors.Is(err, service.ErrInvalidStatusTransition),
+		errors.Is(err, payment.ErrRefundNotAllowed), errors.Is(err, payment.ErrRefundExceedsCharge):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type RoleHandler struct {
+	service *service.RoleService
+	auth    *service.AuthService
+}
+
+func NewRoleHandler(service *service.RoleService, auth *service.AuthService) *RoleHandler {
+	return &RoleHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *RoleHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionUsersManage)
+
+	app.Get("/v1/roles", authenticate, canManage, h.GetAllRoles)
+	app.Get("/v1/users/:id/roles", authenticate, h.GetUserRoles)
+	app.Put("/v1/users/:id/roles", authenticate, canManage, h.UpdateUserRoles)
+}
+
+func (h *RoleHandler) GetAllRoles(c *fiber.Ctx) error {
+	roles, err := h.service.GetAllRoles(middleware.CurrentPrincipal(c))
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Roles retrieved successfully", roles)
+}
+
+func (h *RoleHandler) GetUserRoles(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid user ID")
+	}
+
+	roles, err := h.service.GetUserRoles(middleware.CurrentPrincipal(c), id)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "User roles retrieved successfully", roles)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "User not found")
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *RoleHandler) UpdateUserRoles(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid user ID")
+	}
+
+	var req service.UpdateUserRolesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	roles, err := h.service.UpdateUserRoles(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "User roles updated successfully", roles)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "User not found")
+	case errors.Is(err, service.ErrUnknownRole):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -10,31 +13,44 @@ import (
 
 type ScheduleHandler struct {
 	service *service.ScheduleService
+	auth    *service.AuthService
 }
 
-func NewScheduleHandler(service *service.ScheduleService) *ScheduleHandler {
+func NewScheduleHandler(service *service.ScheduleService, auth *service.AuthService) *ScheduleHandler {
 	return &ScheduleHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
 func (h *ScheduleHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionCatalogManage)
+
 	schedules := app.Group("/v1/schedules")
 	schedules.Get("/", h.GetAllSchedules)
 	schedules.Get("/:id", h.GetScheduleById)
-	schedules.Post("/", h.CreateSchedule)
-	schedules.Put("/:id", h.UpdateSchedule)
-	schedules.Delete("/:id", h.DeleteSchedule)
+	schedules.Post("/", authenticate, canManage, h.CreateSchedule)
+	schedules.Put("/:id", authenticate, canManage, h.UpdateSchedule)
+	schedules.Delete("/:id", authenticate, canManage, h.DeleteSchedule)
 	schedules.Post("/search", h.SearchSchedules)
 }
 
 func (h *ScheduleHandler) GetAllSchedules(c *fiber.Ctx) error {
-	schedules, err := h.service.GetAllSchedules()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllSchedules(q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Schedules retrieved successfully", schedules)
+	return sendPage(c, "Schedules retrieved successfully", page)
 }
 
 func (h *ScheduleHandler) GetScheduleById(c *fiber.Ctx) error {
@@ -57,7 +73,10 @@ func (h *ScheduleHandler) CreateSchedule(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	schedule, err := h.service.CreateSchedule(&req)
+	schedule, err := h.service.CreateSchedule(middleware.CurrentPrincipal(c), &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -76,7 +95,10 @@ func (h *ScheduleHandler) UpdateSchedule(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	schedule, err := h.service.UpdateSchedule(id, &req)
+	schedule, err := h.service.UpdateSchedule(middleware.CurrentPrincipal(c), id, &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -90,7 +112,10 @@ func (h *ScheduleHandler) DeleteSchedule(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid schedule ID")
 	}
 
-	err = h.service.DeleteSchedule(id)
+	err = h.service.DeleteSchedule(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
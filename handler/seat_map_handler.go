@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/models"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SeatMapHandler struct {
+	service *service.SeatMapService
+	auth    *service.AuthService
+}
+
+func NewSeatMapHandler(service *service.SeatMapService, auth *service.AuthService) *SeatMapHandler {
+	return &SeatMapHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *SeatMapHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	optionalAuthenticate := middleware.OptionalAuthenticate(h.auth)
+	canManageCatalog := middleware.RequirePermission(service.PermissionCatalogManage)
+	canManageEvents := middleware.RequirePermission(service.PermissionEventsManage)
+
+	locations := app.Group("/v1/locations")
+	locations.Get("/:id/seat-maps", h.GetSeatMapsByLocationId)
+	locations.Post("/:id/seat-maps", authenticate, canManageCatalog, h.CreateSeatMap)
+
+	seatMaps := app.Group("/v1/seat-maps")
+	seatMaps.Get("/:id", h.GetSeatMapById)
+	seatMaps.Delete("/:id", authenticate, canManageCatalog, h.DeleteSeatMap)
+
+	ticketTypes := app.Group("/v1/ticket-types")
+	ticketTypes.Post("/:id/seats", authenticate, canManageEvents, h.BindSeats)
+	ticketTypes.Delete("/:id/seats", authenticate, canManageEvents, h.UnbindSeats)
+
+	events := app.Group("/v1/events")
+	events.Get("/:id/seats", optionalAuthenticate, h.GetEventSeats)
+}
+
+func (h *SeatMapHandler) GetSeatMapsByLocationId(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid location ID")
+	}
+
+	seatMaps, err := h.service.GetSeatMapsByLocationId(id)
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Seat maps retrieved successfully", seatMaps)
+}
+
+func (h *SeatMapHandler) GetSeatMapById(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid seat map ID")
+	}
+
+	seatMap, err := h.service.GetSeatMapById(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Seat map not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Seat map retrieved successfully", seatMap)
+}
+
+func (h *SeatMapHandler) CreateSeatMap(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid location ID")
+	}
+
+	var req service.CreateSeatMapRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	seatMap, err := h.service.CreateSeatMap(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendCreatedResponse(c, "Seat map created successfully", seatMap)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Location not found")
+	case errors.Is(err, service.ErrInvalidSeatMap):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *SeatMapHandler) DeleteSeatMap(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid seat map ID")
+	}
+
+	err = h.service.DeleteSeatMap(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Seat map not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Seat map deleted successfully", nil)
+}
+
+func (h *SeatMapHandler) GetEventSeats(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid event ID")
+	}
+
+	seats, err := h.service.GetEventSeats(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Event seats retrieved successfully", seats)
+}
+
+func (h *SeatMapHandler) BindSeats(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
+	}
+
+	var req service.SeatSelectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	seats, err := h.service.BindSeats(middleware.CurrentPrincipal(c), id, &req)
+	return sendSeatBinding(c, "Seats bound successfully", seats, err)
+}
+
+func (h *SeatMapHandler) UnbindSeats(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
+	}
+
+	var req service.SeatSelectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	seats, err := h.service.UnbindSeats(middleware.CurrentPrincipal(c), id, &req)
+	return sendSeatBinding(c, "Seats unbound successfully", seats, err)
+}
+
+func sendSeatBinding(c *fiber.Ctx, message string, seats []models.EventSeat, err error) error {
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, message, seats)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Ticket type not found")
+	case errors.Is(err, service.ErrInvalidSeats):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
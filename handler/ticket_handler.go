@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
+	"go-ticket/service"
+	"go-ticket/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type TicketHandler struct {
+	service *service.TicketService
+	auth    *service.AuthService
+}
+
+func NewTicketHandler(service *service.TicketService, auth *service.AuthService) *TicketHandler {
+	return &TicketHandler{
+		service: service,
+		auth:    auth,
+	}
+}
+
+func (h *TicketHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+
+	app.Get("/v1/transactions/:id/tickets", authenticate, h.GetTicketsByTransactionId)
+	app.Get("/v1/transactions/:id/tickets.pdf", authenticate, h.GetTicketsPDF)
+	app.Get("/v1/users/:id/tickets", authenticate, h.GetTicketsByUserId)
+
+	tickets := app.Group("/v1/tickets")
+	tickets.Get("/:code/qr", authenticate, h.GetTicketQRCode)
+	tickets.Put("/:id/holder", authenticate, h.UpdateTicketHolder)
+}
+
+func (h *TicketHandler) GetTicketsByTransactionId(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	tickets, err := h.service.GetTicketsByTransactionId(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	}
+
+	return utils.SendSuccessResponse(c, "Tickets retrieved successfully", tickets)
+}
+
+func (h *TicketHandler) GetTicketsByUserId(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid user ID")
+	}
+
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetTicketsByUserId(middleware.CurrentPrincipal(c), id, q)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "User not found")
+	}
+
+	return sendPage(c, "Tickets retrieved successfully", page)
+}
+
+func (h *TicketHandler) GetTicketQRCode(c *fiber.Ctx) error {
+	png, err := h.service.GetTicketQRCode(middleware.CurrentPrincipal(c), c.Params("code"))
+	switch {
+	case err == nil:
+		c.Set(fiber.HeaderContentType, "image/png")
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+		return c.Send(png)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Ticket not found")
+	case errors.Is(err, service.ErrTicketVoid):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *TicketHandler) GetTicketsPDF(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	pdf, err := h.service.RenderTransactionTickets(middleware.CurrentPrincipal(c), id)
+	switch {
+	case err == nil:
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `inline; filename="tickets-`+id.String()+`.pdf"`)
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+		return c.Send(pdf)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	case errors.Is(err, service.ErrTicketsNotIssued):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
+
+func (h *TicketHandler) UpdateTicketHolder(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid ticket ID")
+	}
+
+	var req service.UpdateTicketHolderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	ticket, err := h.service.UpdateTicketHolder(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Ticket holder updated successfully", ticket)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Ticket not found")
+	case errors.Is(err, service.ErrTicketVoid):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
@@ -1,6 +1,11 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/models"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -10,32 +15,48 @@ import (
 
 type TicketTypeHandler struct {
 	service *service.TicketTypeService
+	auth    *service.AuthService
 }
 
-func NewTicketTypeHandler(service *service.TicketTypeService) *TicketTypeHandler {
+func NewTicketTypeHandler(service *service.TicketTypeService, auth *service.AuthService) *TicketTypeHandler {
 	return &TicketTypeHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
 func (h *TicketTypeHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	optionalAuthenticate := middleware.OptionalAuthenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionEventsManage)
+
 	ticketTypes := app.Group("/v1/ticket-types")
-	ticketTypes.Get("/", h.GetAllTicketTypes)
-	ticketTypes.Get("/:id", h.GetTicketTypeById)
-	ticketTypes.Get("/event/:eventId", h.GetTicketTypesByEventId)
-	ticketTypes.Get("/event/:eventId/available", h.GetAvailableTicketTypes)
-	ticketTypes.Post("/", h.CreateTicketType)
-	ticketTypes.Put("/:id", h.UpdateTicketType)
-	ticketTypes.Delete("/:id", h.DeleteTicketType)
+	ticketTypes.Get("/", optionalAuthenticate, h.GetAllTicketTypes)
+	ticketTypes.Get("/:id", optionalAuthenticate, h.GetTicketTypeById)
+	ticketTypes.Get("/event/:eventId", optionalAuthenticate, h.GetTicketTypesByEventId)
+	ticketTypes.Get("/event/:eventId/available", optionalAuthenticate, h.GetAvailableTicketTypes)
+	ticketTypes.Post("/", authenticate, canManage, h.CreateTicketType)
+	ticketTypes.Put("/:id", authenticate, canManage, h.UpdateTicketType)
+	ticketTypes.Delete("/:id", authenticate, canManage, h.DeleteTicketType)
+	ticketTypes.Get("/:id/price-phases", optionalAuthenticate, h.GetPricePhases)
+	ticketTypes.Put("/:id/price-phases", authenticate, canManage, h.ReplacePricePhases)
 }
 
 func (h *TicketTypeHandler) GetAllTicketTypes(c *fiber.Ctx) error {
-	ticketTypes, err := h.service.GetAllTicketTypes()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllTicketTypes(middleware.CurrentPrincipal(c), q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Ticket types retrieved successfully", ticketTypes)
+	return sendPage(c, "Ticket types retrieved successfully", page)
 }
 
 func (h *TicketTypeHandler) GetTicketTypeById(c *fiber.Ctx) error {
@@ -44,7 +65,7 @@ func (h *TicketTypeHandler) GetTicketTypeById(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
 	}
 
-	ticketType, err := h.service.GetTicketTypeById(id)
+	ticketType, err := h.service.GetTicketTypeById(middleware.CurrentPrincipal(c), id)
 	if err != nil {
 		return utils.SendNotFoundResponse(c, "Ticket type not found")
 	}
@@ -58,12 +79,23 @@ func (h *TicketTypeHandler) GetTicketTypesByEventId(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid event ID")
 	}
 
-	ticketTypes, err := h.service.GetTicketTypesByEventId(eventId)
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetTicketTypesByEventId(middleware.CurrentPrincipal(c), eventId, q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Ticket types retrieved successfully", ticketTypes)
+	return sendPage(c, "Ticket types retrieved successfully", page)
 }
 
 func (h *TicketTypeHandler) GetAvailableTicketTypes(c *fiber.Ctx) error {
@@ -72,12 +104,23 @@ func (h *TicketTypeHandler) GetAvailableTicketTypes(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid event ID")
 	}
 
-	ticketTypes, err := h.service.GetAvailableTicketTypes(eventId)
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAvailableTicketTypes(middleware.CurrentPrincipal(c), eventId, q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Available ticket types retrieved successfully", ticketTypes)
+	return sendPage(c, "Available ticket types retrieved successfully", page)
 }
 
 func (h *TicketTypeHandler) CreateTicketType(c *fiber.Ctx) error {
@@ -86,7 +129,16 @@ func (h *TicketTypeHandler) CreateTicketType(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	ticketType, err := h.service.CreateTicketType(&req)
+	ticketType, err := h.service.CreateTicketType(middleware.CurrentPrincipal(c), &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event not found")
+	}
+	if errors.Is(err, service.ErrInvalidPurchaseLimits) || errors.Is(err, models.ErrInvalidMoney) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -105,7 +157,16 @@ func (h *TicketTypeHandler) UpdateTicketType(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	ticketType, err := h.service.UpdateTicketType(id, &req)
+	ticketType, err := h.service.UpdateTicketType(middleware.CurrentPrincipal(c), id, &req)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Ticket type not found")
+	}
+	if errors.Is(err, service.ErrInvalidPurchaseLimits) || errors.Is(err, service.ErrInvalidPricePhases) || errors.Is(err, models.ErrInvalidMoney) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
@@ -119,10 +180,59 @@ func (h *TicketTypeHandler) DeleteTicketType(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
 	}
 
-	err = h.service.DeleteTicketType(id)
+	err = h.service.DeleteTicketType(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Ticket type not found")
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
 	return utils.SendSuccessResponse(c, "Ticket type deleted successfully", nil)
 }
+
+func (h *TicketTypeHandler) GetPricePhases(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
+	}
+
+	phases, err := h.service.GetPricePhases(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Ticket type not found")
+	}
+	if err != nil {
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Price phases retrieved successfully", phases)
+}
+
+func (h *TicketTypeHandler) ReplacePricePhases(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid ticket type ID")
+	}
+
+	var req service.ReplacePricePhasesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid request body")
+	}
+
+	phases, err := h.service.ReplacePricePhases(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case err == nil:
+		return utils.SendSuccessResponse(c, "Price phases updated successfully", phases)
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Ticket type not found")
+	case errors.Is(err, service.ErrInvalidPricePhases):
+		return utils.SendBadRequestResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
@@ -1,6 +1,12 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/models"
+	"go-ticket/payment"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -8,33 +14,57 @@ import (
 	"github.com/google/uuid"
 )
 
+const transactionCreatedMessage = "Transaction created successfully"
+
 type TransactionHandler struct {
-	service *service.TransactionService
+	service     *service.TransactionService
+	idempotency *service.IdempotencyService
+	auth        *service.AuthService
 }
 
-func NewTransactionHandler(service *service.TransactionService) *TransactionHandler {
+func NewTransactionHandler(service *service.TransactionService, idempotency *service.IdempotencyService, auth *service.AuthService) *TransactionHandler {
 	return &TransactionHandler{
-		service: service,
+		service:     service,
+		idempotency: idempotency,
+		auth:        auth,
 	}
 }
 
 func (h *TransactionHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionTransactionsManage)
+
 	transactions := app.Group("/v1/transactions")
-	transactions.Get("/", h.GetAllTransactions)
-	transactions.Get("/:id", h.GetTransactionById)
-	transactions.Get("/user/:userId", h.GetTransactionsByUserId)
-	transactions.Post("/", h.CreateTransaction)
-	transactions.Put("/:id/status", h.UpdateTransactionStatus)
-	transactions.Put("/:id/payment-status", h.UpdatePaymentStatus)
+	transactions.Get("/", authenticate, middleware.RequirePermission(service.PermissionTransactionsReadAny), h.GetAllTransactions)
+	transactions.Get("/:id", authenticate, h.GetTransactionById)
+	transactions.Get("/:id/history", authenticate, h.GetTransactionHistory)
+	transactions.Get("/user/:userId", authenticate, h.GetTransactionsByUserId)
+	transactions.Post("/",
+		authenticate,
+		middleware.RequirePermission(service.PermissionTransactionsCreate),
+		middleware.Idempotency(h.idempotency, "transactions.create"),
+		h.CreateTransaction,
+	)
+	transactions.Put("/:id/status", authenticate, canManage, h.UpdateTransactionStatus)
+	transactions.Put("/:id/payment-status", authenticate, canManage, h.UpdatePaymentStatus)
+	transactions.Post("/:id/payment-status/sync", authenticate, h.SyncPaymentStatus)
 }
 
 func (h *TransactionHandler) GetAllTransactions(c *fiber.Ctx) error {
-	transactions, err := h.service.GetAllTransactions()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllTransactions(middleware.CurrentPrincipal(c), q)
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Transactions retrieved successfully", transactions)
+	return sendPage(c, "Transactions retrieved successfully", page)
 }
 
 func (h *TransactionHandler) GetTransactionById(c *fiber.Ctx) error {
@@ -43,7 +73,10 @@ func (h *TransactionHandler) GetTransactionById(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
 	}
 
-	transaction, err := h.service.GetTransactionById(id)
+	transaction, err := h.service.GetTransactionById(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendNotFoundResponse(c, "Transaction not found")
 	}
@@ -51,18 +84,46 @@ func (h *TransactionHandler) GetTransactionById(c *fiber.Ctx) error {
 	return utils.SendSuccessResponse(c, "Transaction retrieved successfully", transaction)
 }
 
+func (h *TransactionHandler) GetTransactionHistory(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	history, err := h.service.GetTransactionHistory(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if err != nil {
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	}
+
+	return utils.SendSuccessResponse(c, "Transaction history retrieved successfully", history)
+}
+
 func (h *TransactionHandler) GetTransactionsByUserId(c *fiber.Ctx) error {
 	userId, err := uuid.Parse(c.Params("userId"))
 	if err != nil {
 		return utils.SendBadRequestResponse(c, "Invalid user ID")
 	}
 
-	transactions, err := h.service.GetTransactionsByUserId(userId)
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetTransactionsByUserId(middleware.CurrentPrincipal(c), userId, q)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Transactions retrieved successfully", transactions)
+	return sendPage(c, "Transactions retrieved successfully", page)
 }
 
 func (h *TransactionHandler) CreateTransaction(c *fiber.Ctx) error {
@@ -71,12 +132,35 @@ func (h *TransactionHandler) CreateTransaction(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	transaction, err := h.service.CreateTransaction(&req)
+	claim := middleware.CurrentIdempotencyClaim(c)
+	if claim != nil {
+		claim.Respond = func(data interface{}) (int, []byte, error) {
+			body, err := utils.ResponseBody(fiber.StatusCreated, transactionCreatedMessage, data)
+			return fiber.StatusCreated, body, err
+		}
+	}
+
+	transaction, err := h.service.CreateTransaction(middleware.CurrentPrincipal(c), &req, claim)
+	if errors.Is(err, payment.ErrUnsupportedMethod) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return utils.SendNotFoundResponse(c, "Event or ticket type not found")
+	}
+	if errors.Is(err, service.ErrInvalidSeats) || errors.Is(err, service.ErrInvalidPromoCode) || errors.Is(err, models.ErrCurrencyMismatch) || errors.Is(err, models.ErrInvalidMoney) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+	if errors.Is(err, service.ErrNotOnSale) || errors.Is(err, service.ErrSeatUnavailable) || errors.Is(err, service.ErrPromoCodeUsedUp) || errors.Is(err, service.ErrPurchaseLimit) || errors.Is(err, repository.ErrInsufficientQuota) {
+		return utils.SendConflictResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendCreatedResponse(c, "Transaction created successfully", transaction)
+	return utils.SendCreatedResponse(c, transactionCreatedMessage, transaction)
 }
 
 func (h *TransactionHandler) UpdateTransactionStatus(c *fiber.Ctx) error {
@@ -90,9 +174,9 @@ func (h *TransactionHandler) UpdateTransactionStatus(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	err = h.service.UpdateTransactionStatus(id, &req)
+	err = h.service.UpdateTransactionStatus(middleware.CurrentPrincipal(c), id, &req)
 	if err != nil {
-		return utils.SendInternalServerErrorResponse(c, err)
+		return sendStatusChangeError(c, err)
 	}
 
 	return utils.SendSuccessResponse(c, "Transaction status updated successfully", nil)
@@ -109,10 +193,39 @@ func (h *TransactionHandler) UpdatePaymentStatus(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	err = h.service.UpdatePaymentStatus(id, &req)
+	err = h.service.UpdatePaymentStatus(middleware.CurrentPrincipal(c), id, &req)
 	if err != nil {
-		return utils.SendInternalServerErrorResponse(c, err)
+		return sendStatusChangeError(c, err)
 	}
 
 	return utils.SendSuccessResponse(c, "Payment status updated successfully", nil)
 }
+
+func (h *TransactionHandler) SyncPaymentStatus(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return utils.SendBadRequestResponse(c, "Invalid transaction ID")
+	}
+
+	err = h.service.SyncPaymentStatus(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		return sendStatusChangeError(c, err)
+	}
+
+	return utils.SendSuccessResponse(c, "Payment status synchronized successfully", nil)
+}
+
+func sendStatusChangeError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return utils.SendNotFoundResponse(c, "Transaction not found")
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatus):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatusTransition), errors.Is(err, service.ErrTransactionNotPaid):
+		return utils.SendConflictResponse(c, err.Error())
+	default:
+		return utils.SendInternalServerErrorResponse(c, err)
+	}
+}
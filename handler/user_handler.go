@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"errors"
+	"go-ticket/middleware"
+	"go-ticket/repository"
 	"go-ticket/service"
 	"go-ticket/utils"
 
@@ -10,30 +13,46 @@ import (
 
 type UserHandler struct {
 	service *service.UserService
+	auth    *service.AuthService
 }
 
-func NewUserHandler(service *service.UserService) *UserHandler {
+func NewUserHandler(service *service.UserService, auth *service.AuthService) *UserHandler {
 	return &UserHandler{
 		service: service,
+		auth:    auth,
 	}
 }
 
 func (h *UserHandler) RegisterRoutes(app *fiber.App) {
+	authenticate := middleware.Authenticate(h.auth)
+	canManage := middleware.RequirePermission(service.PermissionUsersManage)
+
 	users := app.Group("/v1/users")
-	users.Get("/", h.GetAllUsers)
-	users.Get("/:id", h.GetUserById)
-	users.Post("/", h.CreateUser)
-	users.Put("/:id", h.UpdateUser)
-	users.Delete("/:id", h.DeleteUser)
+	users.Get("/", authenticate, canManage, h.GetAllUsers)
+	users.Get("/:id", authenticate, h.GetUserById)
+	users.Post("/", authenticate, canManage, h.CreateUser)
+	users.Put("/:id", authenticate, h.UpdateUser)
+	users.Delete("/:id", authenticate, canManage, h.DeleteUser)
 }
 
 func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
-	users, err := h.service.GetAllUsers()
+	q, err := parseListQuery(c)
+	if err != nil {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
+
+	page, err := h.service.GetAllUsers(middleware.CurrentPrincipal(c), q)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
+	if errors.Is(err, repository.ErrInvalidListQuery) {
+		return utils.SendBadRequestResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
-	return utils.SendSuccessResponse(c, "Users retrieved successfully", users)
+	return sendPage(c, "Users retrieved successfully", page)
 }
 
 func (h *UserHandler) GetUserById(c *fiber.Ctx) error {
@@ -42,7 +61,10 @@ func (h *UserHandler) GetUserById(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid user ID")
 	}
 
-	user, err := h.service.GetUserById(id)
+	user, err := h.service.GetUserById(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendNotFoundResponse(c, "User not found")
 	}
@@ -56,8 +78,15 @@ func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	user, err := h.service.CreateUser(&req)
-	if err != nil {
+	user, err := h.service.CreateUser(middleware.CurrentPrincipal(c), &req)
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrEmailRegistered), errors.Is(err, service.ErrPhoneRegistered):
+		return utils.SendConflictResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidPassword):
+		return utils.SendBadRequestResponse(c, err.Error())
+	case err != nil:
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
@@ -75,8 +104,13 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid request body")
 	}
 
-	user, err := h.service.UpdateUser(id, &req)
-	if err != nil {
+	user, err := h.service.UpdateUser(middleware.CurrentPrincipal(c), id, &req)
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return utils.SendForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrEmailRegistered), errors.Is(err, service.ErrPhoneRegistered):
+		return utils.SendConflictResponse(c, err.Error())
+	case err != nil:
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
 
@@ -89,7 +123,10 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		return utils.SendBadRequestResponse(c, "Invalid user ID")
 	}
 
-	err = h.service.DeleteUser(id)
+	err = h.service.DeleteUser(middleware.CurrentPrincipal(c), id)
+	if errors.Is(err, service.ErrForbidden) {
+		return utils.SendForbiddenResponse(c, err.Error())
+	}
 	if err != nil {
 		return utils.SendInternalServerErrorResponse(c, err)
 	}
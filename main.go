@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
+	"go-ticket/config"
 	"go-ticket/database"
 	"go-ticket/handler"
+	"go-ticket/notification"
+	"go-ticket/payment"
 	"go-ticket/repository"
 	"go-ticket/service"
+	"go-ticket/ticketing"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -22,6 +28,10 @@ func main() {
 	}
 	defer database.DB.Close()
 
+	if err := repository.CheckSchema(database.DB); err != nil {
+		log.Fatalf("Database schema does not match the models: %v", err)
+	}
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
@@ -37,6 +47,7 @@ func main() {
 	app.Use(cors.New())
 
 	// Initialize repositories
+	uow := repository.NewUnitOfWork(database.DB)
 	eventRepo := repository.NewEventRepository(database.DB)
 	scheduleRepo := repository.NewScheduleRepository(database.DB)
 	locationRepo := repository.NewLocationRepository(database.DB)
@@ -44,30 +55,153 @@ func main() {
 	ticketTypeRepo := repository.NewTicketTypeRepository(database.DB)
 	transactionRepo := repository.NewTransactionRepository(database.DB)
 	transactionDetailRepo := repository.NewTransactionDetailRepository(database.DB)
+	transactionStatusHistoryRepo := repository.NewTransactionStatusHistoryRepository(database.DB)
+	paymentWebhookNonceRepo := repository.NewPaymentWebhookNonceRepository(database.DB)
+	idempotencyKeyRepo := repository.NewIdempotencyKeyRepository(database.DB)
+	refundRepo := repository.NewRefundRepository(database.DB)
+	ticketRepo := repository.NewTicketRepository(database.DB)
+	ticketScanRepo := repository.NewTicketScanRepository(database.DB)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(database.DB)
+	roleRepo := repository.NewRoleRepository(database.DB)
+	organizerRepo := repository.NewOrganizerRepository(database.DB)
+	eventJobRepo := repository.NewEventJobRepository(database.DB)
+	notificationRepo := repository.NewNotificationRepository(database.DB)
+	seatMapRepo := repository.NewSeatMapRepository(database.DB)
+	eventSeatRepo := repository.NewEventSeatRepository(database.DB)
+	promoCodeRepo := repository.NewPromoCodeRepository(database.DB)
+	pricePhaseRepo := repository.NewPricePhaseRepository(database.DB)
+
+	// Initialize payment gateway. There is no default: the fake provider
+	// lets anyone settle a charge over HTTP, so it has to be chosen on purpose.
+	var gateway payment.Gateway
+	var fakeGateway *payment.FakeGateway
+	switch provider := config.Env("PAYMENT_PROVIDER", ""); provider {
+	case "":
+		log.Fatal("PAYMENT_PROVIDER is not set")
+	case payment.FakeProvider:
+		fakeGateway = payment.NewFakeGateway(config.Env("APP_URL", "http://localhost:8000"))
+		gateway = fakeGateway
+	default:
+		log.Fatalf("Unsupported payment provider: %s", provider)
+	}
+
+	// Initialize notification sender
+	var sender notification.Sender
+	switch provider := config.Env("NOTIFICATION_PROVIDER", notification.LogProvider); provider {
+	case notification.LogProvider:
+		sender = notification.NewLogSender()
+	default:
+		log.Fatalf("Unsupported notification provider: %s", provider)
+	}
 
 	// Initialize services
-	eventService := service.NewEventService(eventRepo)
+	reservationService := service.NewReservationService(
+		uow, transactionRepo, transactionDetailRepo, ticketTypeRepo, eventSeatRepo, promoCodeRepo,
+		transactionStatusHistoryRepo, config.EnvDuration("HOLD_TTL", 15*time.Minute),
+	)
+	eventService := service.NewEventService(eventRepo, locationRepo)
 	scheduleService := service.NewScheduleService(scheduleRepo)
 	locationService := service.NewLocationService(locationRepo)
-	userService := service.NewUserService(userRepo)
-	ticketTypeService := service.NewTicketTypeService(ticketTypeRepo)
-	transactionService := service.NewTransactionService(transactionRepo, transactionDetailRepo, ticketTypeRepo)
+	promoCodeService := service.NewPromoCodeService(uow, promoCodeRepo)
+	seatMapService := service.NewSeatMapService(uow, seatMapRepo, eventSeatRepo, locationRepo, eventRepo, ticketTypeRepo)
+	userService := service.NewUserService(uow, userRepo, roleRepo)
+	authService, err := service.NewAuthService(
+		uow, userRepo, refreshTokenRepo, roleRepo, organizerRepo, userService,
+		config.Env("JWT_SECRET", ""),
+		config.EnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		config.EnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+	)
+	if err != nil {
+		log.Fatalf("Invalid JWT_SECRET: %v", err)
+	}
+	roleService := service.NewRoleService(uow, roleRepo, userRepo)
+	organizerService := service.NewOrganizerService(uow, organizerRepo, userRepo, roleRepo)
+	ticketTypeService := service.NewTicketTypeService(uow, ticketTypeRepo, eventRepo, eventSeatRepo, pricePhaseRepo)
+	ticketSigner, err := ticketing.NewSigner(config.Env("TICKET_SIGNING_SECRET", ""))
+	if err != nil {
+		log.Fatalf("Invalid TICKET_SIGNING_SECRET: %v", err)
+	}
+	ticketService := service.NewTicketService(
+		ticketRepo, transactionRepo, transactionDetailRepo, userRepo, eventRepo, ticketTypeRepo, eventSeatRepo, ticketSigner,
+	)
+	checkInService := service.NewCheckInService(uow, ticketRepo, ticketScanRepo, eventRepo, ticketSigner)
+	transactionService := service.NewTransactionService(
+		uow, transactionRepo, transactionDetailRepo, eventRepo, ticketTypeRepo, eventSeatRepo, pricePhaseRepo,
+		transactionStatusHistoryRepo, refundRepo, userRepo, reservationService, ticketService, seatMapService, promoCodeService, gateway,
+	)
+	if fakeGateway != nil {
+		fakeGateway.OnEvent(transactionService.HandlePaymentEvent)
+	}
+	paymentService := service.NewPaymentService(
+		uow, paymentWebhookNonceRepo, transactionService, gateway,
+		config.Env("PAYMENT_WEBHOOK_SECRET", ""),
+		config.EnvDuration("PAYMENT_WEBHOOK_TOLERANCE", 5*time.Minute),
+	)
+	refundService := service.NewRefundService(
+		uow, refundRepo, transactionRepo, transactionDetailRepo, eventRepo, ticketTypeRepo, transactionStatusHistoryRepo,
+		ticketService, gateway,
+	)
+	notificationService := service.NewNotificationService(uow, notificationRepo, sender)
+	eventJobService := service.NewEventJobService(
+		uow, eventJobRepo, eventRepo, scheduleRepo, transactionRepo, userRepo,
+		refundService, reservationService, notificationService,
+	)
+	idempotencyService := service.NewIdempotencyService(
+		idempotencyKeyRepo,
+		config.EnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+	)
+
+	// Grant the admin role to the configured user, who must have registered
+	if email := config.Env("ADMIN_EMAIL", ""); email != "" {
+		if err := roleService.GrantRoleByEmail(email, service.RoleAdmin); err != nil {
+			log.Printf("Failed to grant admin role to %s: %v", email, err)
+		}
+	}
 
 	// Initialize handlers
-	eventHandler := handler.NewEventHandler(eventService)
-	scheduleHandler := handler.NewScheduleHandler(scheduleService)
-	locationHandler := handler.NewLocationHandler(locationService)
-	userHandler := handler.NewUserHandler(userService)
-	ticketTypeHandler := handler.NewTicketTypeHandler(ticketTypeService)
-	transactionHandler := handler.NewTransactionHandler(transactionService)
+	eventHandler := handler.NewEventHandler(eventService, authService)
+	eventJobHandler := handler.NewEventJobHandler(eventJobService, authService)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService, authService)
+	locationHandler := handler.NewLocationHandler(locationService, authService)
+	seatMapHandler := handler.NewSeatMapHandler(seatMapService, authService)
+	promoCodeHandler := handler.NewPromoCodeHandler(promoCodeService, authService)
+	userHandler := handler.NewUserHandler(userService, authService)
+	roleHandler := handler.NewRoleHandler(roleService, authService)
+	organizerHandler := handler.NewOrganizerHandler(organizerService, authService)
+	authHandler := handler.NewAuthHandler(authService)
+	ticketTypeHandler := handler.NewTicketTypeHandler(ticketTypeService, authService)
+	transactionHandler := handler.NewTransactionHandler(transactionService, idempotencyService, authService)
+	refundHandler := handler.NewRefundHandler(refundService, authService)
+	ticketHandler := handler.NewTicketHandler(ticketService, authService)
+	checkInHandler := handler.NewCheckInHandler(checkInService, authService)
+	paymentHandler := handler.NewPaymentHandler(paymentService, fakeGateway)
 
 	// Register routes
 	eventHandler.RegisterRoutes(app)
+	eventJobHandler.RegisterRoutes(app)
 	scheduleHandler.RegisterRoutes(app)
 	locationHandler.RegisterRoutes(app)
+	seatMapHandler.RegisterRoutes(app)
+	promoCodeHandler.RegisterRoutes(app)
 	userHandler.RegisterRoutes(app)
+	roleHandler.RegisterRoutes(app)
+	organizerHandler.RegisterRoutes(app)
+	authHandler.RegisterRoutes(app)
 	ticketTypeHandler.RegisterRoutes(app)
 	transactionHandler.RegisterRoutes(app)
+	refundHandler.RegisterRoutes(app)
+	ticketHandler.RegisterRoutes(app)
+	checkInHandler.RegisterRoutes(app)
+	paymentHandler.RegisterRoutes(app)
+
+	// Release expired ticket holds and idempotency keys, run event jobs and
+	// deliver notifications in the background
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reservationService.StartSweeper(ctx, config.EnvDuration("HOLD_SWEEP_INTERVAL", time.Minute))
+	go idempotencyService.StartPurger(ctx, config.EnvDuration("IDEMPOTENCY_PURGE_INTERVAL", time.Hour))
+	go eventJobService.StartWorker(ctx, config.EnvDuration("EVENT_JOB_INTERVAL", 10*time.Second))
+	go notificationService.StartDispatcher(ctx, config.EnvDuration("NOTIFICATION_DISPATCH_INTERVAL", 10*time.Second))
 
 	// Get port from environment variable or use default
 	port := os.Getenv("APP_PORT")
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"go-ticket/service"
+	"go-ticket/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// principalLocal is the fiber.Ctx local holding the authenticated
+// *service.Principal.
+const principalLocal = "principal"
+
+// Authenticate rejects requests without a valid "Authorization: Bearer"
+// access token and puts the authenticated principal on the context.
+func Authenticate(auth *service.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return utils.SendUnauthorizedResponse(c, "Missing bearer token")
+		}
+
+		principal, err := auth.Authenticate(token)
+		switch {
+		case errors.Is(err, service.ErrInvalidAccessToken):
+			return utils.SendUnauthorizedResponse(c, err.Error())
+		case err != nil:
+			return utils.SendInternalServerErrorResponse(c, err)
+		}
+
+		c.Locals(principalLocal, principal)
+		return c.Next()
+	}
+}
+
+// OptionalAuthenticate is Authenticate for routes open to anonymous
+// callers: requests without an Authorization header pass through without
+// a principal, while a bad token is still rejected.
+func OptionalAuthenticate(auth *service.AuthService) fiber.Handler {
+	authenticate := Authenticate(auth)
+	return func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) == "" {
+			return c.Next()
+		}
+		return authenticate(c)
+	}
+}
+
+// RequirePermission rejects requests whose principal holds none of
+// permissions. It must come after Authenticate. Services check access again,
+// including ownership, so this only turns away callers early.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !CurrentPrincipal(c).Can(permissions...) {
+			return utils.SendForbiddenResponse(c, "Insufficient permissions")
+		}
+		return c.Next()
+	}
+}
+
+// CurrentPrincipal returns the principal put on the context by
+// Authenticate, or nil on routes that do not require authentication.
+func CurrentPrincipal(c *fiber.Ctx) *service.Principal {
+	principal, _ := c.Locals(principalLocal).(*service.Principal)
+	return principal
+}
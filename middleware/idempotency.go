@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-ticket/service"
+	"go-ticket/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+
+	idempotencyClaimLocal = "idempotencyClaim"
+)
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key header. Keys are namespaced by scope, so the same
+// key may be used against different endpoints, and by the authenticated
+// user, so one user can never replay another's response. Requests without
+// the header pass through untouched.
+//
+// A handler that commits changes records its response along with them
+// through the claim of CurrentIdempotencyClaim. Once it has, the key is
+// never released, even when the request fails afterwards, so a retry can
+// never make the changes twice.
+func Idempotency(idempotency *service.IdempotencyService, scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scope := scope
+		if principal := CurrentPrincipal(c); principal != nil {
+			scope += ":" + principal.User.ID.String()
+		}
+
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return utils.SendBadRequestResponse(c, "Idempotency-Key is too long")
+		}
+
+		claim, stored, err := idempotency.Begin(scope, key, hashRequest(c))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyKeyReused), errors.Is(err, service.ErrIdempotencyKeyInProgress):
+			return utils.SendConflictResponse(c, err.Error())
+		case err != nil:
+			return utils.SendInternalServerErrorResponse(c, err)
+		}
+
+		if stored != nil {
+			c.Set(HeaderIdempotentReplayed, "true")
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Status(*stored.StatusCode).Send(stored.ResponseBody)
+		}
+
+		c.Locals(idempotencyClaimLocal, claim)
+		if err := c.Next(); err != nil {
+			if !claim.Committed() {
+				abandon(idempotency, scope, key)
+			}
+			return err
+		}
+
+		// Server errors before any change was committed are not stored so the
+		// client can retry with the same key
+		statusCode := c.Response().StatusCode()
+		if statusCode >= fiber.StatusInternalServerError && !claim.Committed() {
+			abandon(idempotency, scope, key)
+			return nil
+		}
+
+		body := append([]byte(nil), c.Response().Body()...)
+		if err := idempotency.Complete(scope, key, statusCode, body); err != nil {
+			if statusCode >= fiber.StatusBadRequest && !claim.Committed() {
+				abandon(idempotency, scope, key)
+				log.Printf("Failed to store response for idempotency key %s, released it: %v", key, err)
+				return nil
+			}
+			log.Printf("Failed to store response for idempotency key %s, kept it: %v", key, err)
+		}
+
+		return nil
+	}
+}
+
+// CurrentIdempotencyClaim returns the idempotency key claimed for the
+// request, or nil when the request has none.
+func CurrentIdempotencyClaim(c *fiber.Ctx) *service.IdempotencyClaim {
+	claim, _ := c.Locals(idempotencyClaimLocal).(*service.IdempotencyClaim)
+	return claim
+}
+
+func abandon(idempotency *service.IdempotencyService, scope, key string) {
+	if err := idempotency.Abandon(scope, key); err != nil {
+		log.Printf("Failed to release idempotency key %s: %v", key, err)
+	}
+}
+
+func hashRequest(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(c.Path()))
+	hash.Write([]byte{0})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -13,22 +13,145 @@ type BaseModel struct {
 	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
+// User is an account. EmailVerifiedAt and PhoneVerifiedAt are set once the
+// user proved they own that contact, and cleared when it changes.
 type User struct {
 	BaseModel
-	Fullname string `db:"fullname" json:"fullname"`
-	Email    string `db:"email" json:"email"`
-	Password string `db:"password" json:"-"`
-	Phone    string `db:"phone" json:"phone"`
+	Fullname        string     `db:"fullname" json:"fullname"`
+	Email           string     `db:"email" json:"email"`
+	Password        string     `db:"password" json:"-"`
+	Phone           string     `db:"phone" json:"phone"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `db:"phone_verified_at" json:"phone_verified_at"`
+}
+
+// HasVerifiedContact reports whether the user verified their email or
+// phone.
+func (u *User) HasVerifiedContact() bool {
+	return u.EmailVerifiedAt != nil || u.PhoneVerifiedAt != nil
+}
+
+// RefreshToken is one link in a chain of rotated refresh tokens. Tokens
+// issued from the same login share a FamilyID, so presenting a revoked
+// token can revoke the whole chain. Only the SHA-256 of a token is stored.
+type RefreshToken struct {
+	ID         uuid.UUID  `db:"id" json:"id"`
+	UserID     uuid.UUID  `db:"user_id" json:"user_id"`
+	FamilyID   uuid.UUID  `db:"family_id" json:"family_id"`
+	TokenHash  string     `db:"token_hash" json:"-"`
+	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	ReplacedBy *uuid.UUID `db:"replaced_by" json:"replaced_by,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Role groups the permissions granted to the users holding it.
+type Role struct {
+	ID          uuid.UUID `db:"id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	Description *string   `db:"description" json:"description,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	Permissions []string  `db:"-" json:"permissions"`
+}
+
+// Organizer is the workspace of a promoter we sell tickets for. Events,
+// locations and ticket types each belong to one organizer.
+type Organizer struct {
+	BaseModel
+	Name string `db:"name" json:"name"`
+}
+
+type OrganizerRole string
+
+const (
+	OrganizerRoleOwner  OrganizerRole = "owner"
+	OrganizerRoleMember OrganizerRole = "member"
+)
+
+func (r OrganizerRole) Valid() bool {
+	switch r {
+	case OrganizerRoleOwner, OrganizerRoleMember:
+		return true
+	}
+	return false
+}
+
+// OrganizerMember gives a user access to the data of an organizer. Owners
+// also manage the organizer and its members.
+type OrganizerMember struct {
+	OrganizerID uuid.UUID     `db:"organizer_id" json:"organizer_id"`
+	UserID      uuid.UUID     `db:"user_id" json:"user_id"`
+	Role        OrganizerRole `db:"role" json:"role"`
+	CreatedAt   time.Time     `db:"created_at" json:"created_at"`
 }
 
 type Location struct {
 	BaseModel
-	Name       string `db:"name" json:"name"`
-	Address    string `db:"address" json:"address"`
-	City       string `db:"city" json:"city"`
-	State      string `db:"state" json:"state"`
-	Country    string `db:"country" json:"country"`
-	PostalCode string `db:"postal_code" json:"postal_code"`
+	Name        string    `db:"name" json:"name"`
+	Address     string    `db:"address" json:"address"`
+	City        string    `db:"city" json:"city"`
+	State       string    `db:"state" json:"state"`
+	Country     string    `db:"country" json:"country"`
+	PostalCode  string    `db:"postal_code" json:"postal_code"`
+	OrganizerID uuid.UUID `db:"organizer_id" json:"organizer_id"`
+}
+
+// SeatMap is one seating layout of a location, such as the full house or
+// a configuration with the balcony closed. Seat coordinates share a plane
+// with the stage at StageX, StageY.
+type SeatMap struct {
+	BaseModel
+	LocationID  uuid.UUID     `db:"location_id" json:"location_id"`
+	OrganizerID uuid.UUID     `db:"organizer_id" json:"organizer_id"`
+	Name        string        `db:"name" json:"name"`
+	StageX      float64       `db:"stage_x" json:"stage_x"`
+	StageY      float64       `db:"stage_y" json:"stage_y"`
+	Sections    []SeatSection `db:"-" json:"sections,omitempty"`
+}
+
+type SeatSection struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	SeatMapID uuid.UUID `db:"seat_map_id" json:"seat_map_id"`
+	Name      string    `db:"name" json:"name"`
+	Position  int       `db:"position" json:"position"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	Seats     []Seat    `db:"-" json:"seats,omitempty"`
+}
+
+// Seat is a single seat of a section, numbered within its row.
+type Seat struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	SeatMapID  uuid.UUID `db:"seat_map_id" json:"seat_map_id"`
+	SectionID  uuid.UUID `db:"section_id" json:"section_id"`
+	Row        string    `db:"row_label" json:"row"`
+	Number     int       `db:"number" json:"number"`
+	X          float64   `db:"x" json:"x"`
+	Y          float64   `db:"y" json:"y"`
+	Accessible bool      `db:"accessible" json:"accessible"`
+	Obstructed bool      `db:"obstructed" json:"obstructed"`
+	Aisle      bool      `db:"aisle" json:"aisle"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type EventSeatStatus string
+
+const (
+	EventSeatStatusAvailable EventSeatStatus = "available"
+	EventSeatStatusHeld      EventSeatStatus = "held"
+	EventSeatStatusSold      EventSeatStatus = "sold"
+)
+
+// EventSeat is a seat an event sells under one of its ticket types. Held
+// and sold seats belong to the transaction detail that reserved them.
+type EventSeat struct {
+	EventID             uuid.UUID       `db:"event_id" json:"event_id"`
+	SeatID              uuid.UUID       `db:"seat_id" json:"seat_id"`
+	TicketTypeID        uuid.UUID       `db:"ticket_type_id" json:"ticket_type_id"`
+	Status              EventSeatStatus `db:"status" json:"status"`
+	TransactionDetailID *uuid.UUID      `db:"transaction_detail_id" json:"-"`
+	CreatedAt           time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt           time.Time       `db:"updated_at" json:"updated_at"`
+	Seat                *Seat           `db:"-" json:"seat,omitempty"`
 }
 
 type Schedule struct {
@@ -39,49 +162,500 @@ type Schedule struct {
 	EndDate     time.Time `db:"end_date" json:"end_date"`
 }
 
+type EventStatus string
+
+const (
+	EventStatusDraft     EventStatus = "draft"
+	EventStatusPublished EventStatus = "published"
+	EventStatusOnSale    EventStatus = "on_sale"
+	EventStatusSoldOut   EventStatus = "sold_out"
+	EventStatusCancelled EventStatus = "cancelled"
+	EventStatusPostponed EventStatus = "postponed"
+)
+
+func (s EventStatus) Valid() bool {
+	switch s {
+	case EventStatusDraft, EventStatusPublished, EventStatusOnSale, EventStatusSoldOut, EventStatusCancelled, EventStatusPostponed:
+		return true
+	}
+	return false
+}
+
+// Listed reports whether events with the status appear in public listings.
+// Drafts are still being set up and cancelled events are gone.
+func (s EventStatus) Listed() bool {
+	return s != EventStatusDraft && s != EventStatusCancelled
+}
+
+// ListedEventStatuses are the statuses for which Listed is true.
+var ListedEventStatuses = []EventStatus{
+	EventStatusPublished, EventStatusOnSale, EventStatusSoldOut, EventStatusPostponed,
+}
+
+// Event is a show we sell tickets for. RefundDeadline is set when the event
+// is postponed, and is the last moment its buyers may ask for a refund
+// instead of keeping their tickets. LimitByContact counts the tickets of
+// every account sharing the buyer's email or phone toward the per user
+// limits of the event and its ticket types.
 type Event struct {
 	BaseModel
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
-	LocationID  uuid.UUID `db:"location_id" json:"location_id"`
-	ScheduleID  uuid.UUID `db:"schedule_id" json:"schedule_id"`
-	Location    *Location `db:"-" json:"location,omitempty"`
-	Schedule    *Schedule `db:"-" json:"schedule,omitempty"`
+	PurchaseLimits
+	Name           string      `db:"name" json:"name"`
+	Description    string      `db:"description" json:"description"`
+	LocationID     uuid.UUID   `db:"location_id" json:"location_id"`
+	ScheduleID     uuid.UUID   `db:"schedule_id" json:"schedule_id"`
+	OrganizerID    uuid.UUID   `db:"organizer_id" json:"organizer_id"`
+	Status         EventStatus `db:"status" json:"status"`
+	RefundDeadline *time.Time  `db:"refund_deadline" json:"refund_deadline,omitempty"`
+	LimitByContact bool        `db:"limit_by_contact" json:"limit_by_contact"`
+	Location       *Location   `db:"-" json:"location,omitempty"`
+	Schedule       *Schedule   `db:"-" json:"schedule,omitempty"`
 }
 
+// PurchaseLimits caps the tickets of an event, or of one of its ticket
+// types, that a buyer may get in one transaction and across all their
+// transactions. A nil limit leaves that side uncapped.
+type PurchaseLimits struct {
+	MaxPerTransaction *int `db:"max_per_transaction" json:"max_per_transaction"`
+	MaxPerUser        *int `db:"max_per_user" json:"max_per_user"`
+}
+
+// TicketType sells tickets between SaleStart and SaleEnd while its event is
+// on sale. A missing bound leaves that side of the window open.
 type TicketType struct {
 	BaseModel
-	EventID        uuid.UUID `db:"event_id" json:"event_id"`
-	Name           string    `db:"name" json:"name"`
-	Description    string    `db:"description" json:"description"`
-	Price          float64   `db:"price" json:"price"`
-	Quota          int       `db:"quota" json:"quota"`
-	RemainingQuota int       `db:"remaining_quota" json:"remaining_quota"`
-	Event          *Event    `db:"-" json:"event,omitempty"`
+	PurchaseLimits
+	EventID        uuid.UUID   `db:"event_id" json:"event_id"`
+	Name           string      `db:"name" json:"name"`
+	Description    string      `db:"description" json:"description"`
+	Price          Money       `db:"price" json:"price"`
+	Currency       string      `db:"currency" json:"currency"`
+	Quota          int         `db:"quota" json:"quota"`
+	RemainingQuota int         `db:"remaining_quota" json:"remaining_quota"`
+	OrganizerID    uuid.UUID   `db:"organizer_id" json:"organizer_id"`
+	SaleStart      *time.Time  `db:"sale_start" json:"sale_start"`
+	SaleEnd        *time.Time  `db:"sale_end" json:"sale_end"`
+	Event          *Event      `db:"-" json:"event,omitempty"`
+	CurrentPhase   *PricePhase `db:"-" json:"current_phase,omitempty"`
+	NextPhase      *PricePhase `db:"-" json:"next_phase,omitempty"`
+}
+
+// Sold is the number of tickets sold or held.
+func (t *TicketType) Sold() int {
+	return t.Quota - t.RemainingQuota
+}
+
+// PriceAt is the price of a ticket at the given time, in Currency: that of
+// the current of phases, or Price when no phase applies.
+func (t *TicketType) PriceAt(phases []PricePhase, at time.Time) Money {
+	price := t.Price
+	if current, _ := CurrentPricePhase(phases, t.Sold(), at); current != nil {
+		price = current.Price
+	}
+	price.Currency = t.Currency
+	return price
+}
+
+// OnSaleAt reports whether at falls within the sales window.
+func (t *TicketType) OnSaleAt(at time.Time) bool {
+	if t.SaleStart != nil && at.Before(*t.SaleStart) {
+		return false
+	}
+	if t.SaleEnd != nil && !at.Before(*t.SaleEnd) {
+		return false
+	}
+	return true
+}
+
+// PricePhase is one step of the pricing of a ticket type, such as early
+// bird or door price. It applies from StartsAt until EndsAt while fewer
+// than SoldLimit tickets of the type are sold. Any of them may be left out.
+type PricePhase struct {
+	ID           uuid.UUID  `db:"id" json:"id"`
+	TicketTypeID uuid.UUID  `db:"ticket_type_id" json:"ticket_type_id"`
+	Position     int        `db:"position" json:"position"`
+	Name         string     `db:"name" json:"name"`
+	Price        Money      `db:"price" json:"price"`
+	StartsAt     *time.Time `db:"starts_at" json:"starts_at,omitempty"`
+	EndsAt       *time.Time `db:"ends_at" json:"ends_at,omitempty"`
+	SoldLimit    *int       `db:"sold_limit" json:"sold_limit,omitempty"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+}
+
+// over reports whether the phase ended or sold out, so it can no longer
+// apply.
+func (p *PricePhase) over(sold int, at time.Time) bool {
+	if p.EndsAt != nil && !at.Before(*p.EndsAt) {
+		return true
+	}
+	return p.SoldLimit != nil && sold >= *p.SoldLimit
+}
+
+// CurrentPricePhase returns the first of phases, in order of position, that
+// applies with sold tickets sold at the given time, and the first phase
+// after it that is not over yet. Without a current phase, next is the
+// first phase still to start. Either may be nil.
+func CurrentPricePhase(phases []PricePhase, sold int, at time.Time) (current, next *PricePhase) {
+	for i := range phases {
+		phase := &phases[i]
+		if phase.over(sold, at) {
+			continue
+		}
+		if current != nil {
+			return current, phase
+		}
+		if phase.StartsAt == nil || !at.Before(*phase.StartsAt) {
+			current, next = phase, nil
+		} else if next == nil {
+			next = phase
+		}
+	}
+	return current, next
+}
+
+type TransactionStatus string
+
+const (
+	TransactionStatusPending   TransactionStatus = "pending"
+	TransactionStatusConfirmed TransactionStatus = "confirmed"
+	TransactionStatusCancelled TransactionStatus = "cancelled"
+	TransactionStatusCompleted TransactionStatus = "completed"
+)
+
+func (s TransactionStatus) Valid() bool {
+	switch s {
+	case TransactionStatusPending, TransactionStatusConfirmed, TransactionStatusCancelled, TransactionStatusCompleted:
+		return true
+	}
+	return false
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusPaid              PaymentStatus = "paid"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+)
+
+func (s PaymentStatus) Valid() bool {
+	switch s {
+	case PaymentStatusPending, PaymentStatusPaid, PaymentStatusFailed, PaymentStatusPartiallyRefunded, PaymentStatusRefunded:
+		return true
+	}
+	return false
+}
+
+type PaymentMethod string
+
+const (
+	PaymentMethodBankTransfer PaymentMethod = "bank_transfer"
+	PaymentMethodCreditCard   PaymentMethod = "credit_card"
+	PaymentMethodEWallet      PaymentMethod = "e_wallet"
+)
+
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentMethodBankTransfer, PaymentMethodCreditCard, PaymentMethodEWallet:
+		return true
+	}
+	return false
 }
 
 type Transaction struct {
 	BaseModel
-	UserID          uuid.UUID           `db:"user_id" json:"user_id"`
-	EventID         uuid.UUID           `db:"event_id" json:"event_id"`
-	TotalAmount     float64             `db:"total_amount" json:"total_amount"`
-	Status          string              `db:"status" json:"status"`
-	PaymentMethod   string              `db:"payment_method" json:"payment_method"`
-	PaymentStatus   string              `db:"payment_status" json:"payment_status"`
-	PaymentUrl      string              `db:"payment_url" json:"payment_url"`
-	PaymentCallback *string             `db:"payment_callback" json:"payment_callback"`
-	User            *User               `db:"-" json:"user,omitempty"`
-	Event           *Event              `db:"-" json:"event,omitempty"`
-	Details         []TransactionDetail `db:"-" json:"details,omitempty"`
+	UserID           uuid.UUID           `db:"user_id" json:"user_id"`
+	EventID          uuid.UUID           `db:"event_id" json:"event_id"`
+	TotalAmount      Money               `db:"total_amount" json:"total_amount"`
+	Currency         string              `db:"currency" json:"currency"`
+	Status           TransactionStatus   `db:"status" json:"status"`
+	PaymentMethod    PaymentMethod       `db:"payment_method" json:"payment_method"`
+	PaymentStatus    PaymentStatus       `db:"payment_status" json:"payment_status"`
+	PaymentUrl       string              `db:"payment_url" json:"payment_url"`
+	PaymentCallback  *string             `db:"payment_callback" json:"payment_callback"`
+	ExpiresAt        *time.Time          `db:"expires_at" json:"expires_at,omitempty"`
+	PaymentProvider  *string             `db:"payment_provider" json:"payment_provider"`
+	PaymentReference *string             `db:"payment_reference" json:"payment_reference"`
+	PromoCodeID      *uuid.UUID          `db:"promo_code_id" json:"promo_code_id,omitempty"`
+	User             *User               `db:"-" json:"user,omitempty"`
+	Event            *Event              `db:"-" json:"event,omitempty"`
+	Details          []TransactionDetail `db:"-" json:"details,omitempty"`
 }
 
+// Total is TotalAmount in the currency of the transaction.
+func (t *Transaction) Total() Money {
+	return NewMoney(t.TotalAmount.Amount, t.Currency)
+}
+
+// TransactionDetail is Quantity tickets of one ticket type. Subtotal is
+// what was paid for them: PricePerTicket times Quantity less Discount.
 type TransactionDetail struct {
 	BaseModel
-	TransactionID  uuid.UUID    `db:"transaction_id" json:"transaction_id"`
-	TicketTypeID   uuid.UUID    `db:"ticket_type_id" json:"ticket_type_id"`
-	Quantity       int          `db:"quantity" json:"quantity"`
-	PricePerTicket float64      `db:"price_per_ticket" json:"price_per_ticket"`
-	Subtotal       float64      `db:"subtotal" json:"subtotal"`
-	Transaction    *Transaction `db:"-" json:"transaction,omitempty"`
-	TicketType     *TicketType  `db:"-" json:"ticket_type,omitempty"`
+	TransactionID    uuid.UUID    `db:"transaction_id" json:"transaction_id"`
+	TicketTypeID     uuid.UUID    `db:"ticket_type_id" json:"ticket_type_id"`
+	Quantity         int          `db:"quantity" json:"quantity"`
+	RefundedQuantity int          `db:"refunded_quantity" json:"refunded_quantity"`
+	PricePerTicket   Money        `db:"price_per_ticket" json:"price_per_ticket"`
+	Discount         Money        `db:"discount" json:"discount"`
+	Subtotal         Money        `db:"subtotal" json:"subtotal"`
+	Transaction      *Transaction `db:"-" json:"transaction,omitempty"`
+	TicketType       *TicketType  `db:"-" json:"ticket_type,omitempty"`
+}
+
+// RefundAmount is what quantity more tickets of the detail are refunded
+// for: their share of the subtotal, in whole minor units of currency, so a
+// discount is refunded pro rata. The shares add up to the subtotal once
+// every ticket is refunded.
+func (d *TransactionDetail) RefundAmount(quantity int, currency string) Money {
+	subtotal := NewMoney(d.Subtotal.Amount, currency)
+	paidFor := func(tickets int) int64 {
+		return subtotal.Share(int64(tickets), int64(d.Quantity)).Amount
+	}
+	return NewMoney(paidFor(d.RefundedQuantity+quantity)-paidFor(d.RefundedQuantity), currency)
+}
+
+type TicketStatus string
+
+const (
+	TicketStatusValid TicketStatus = "valid"
+	TicketStatusUsed  TicketStatus = "used"
+	TicketStatusVoid  TicketStatus = "void"
+)
+
+// Ticket is a single admission issued for one unit of a TransactionDetail
+// once its transaction is paid. Tickets of seated ticket types name the
+// seat they admit to.
+type Ticket struct {
+	BaseModel
+	TransactionID       uuid.UUID    `db:"transaction_id" json:"transaction_id"`
+	TransactionDetailID uuid.UUID    `db:"transaction_detail_id" json:"transaction_detail_id"`
+	TicketTypeID        uuid.UUID    `db:"ticket_type_id" json:"ticket_type_id"`
+	EventID             uuid.UUID    `db:"event_id" json:"event_id"`
+	UserID              uuid.UUID    `db:"user_id" json:"user_id"`
+	Code                string       `db:"code" json:"code"`
+	HolderName          *string      `db:"holder_name" json:"holder_name"`
+	HolderEmail         *string      `db:"holder_email" json:"holder_email"`
+	Status              TicketStatus `db:"status" json:"status"`
+	UsedAt              *time.Time   `db:"used_at" json:"used_at,omitempty"`
+	UsedGate            *string      `db:"used_gate" json:"used_gate,omitempty"`
+	SeatID              *uuid.UUID   `db:"seat_id" json:"seat_id,omitempty"`
+}
+
+// TicketTypeCheckIn is the running check-in count of a ticket type.
+type TicketTypeCheckIn struct {
+	TicketTypeID uuid.UUID `db:"ticket_type_id" json:"ticket_type_id"`
+	Name         string    `db:"name" json:"name"`
+	Issued       int       `db:"issued" json:"issued"`
+	CheckedIn    int       `db:"checked_in" json:"checked_in"`
+}
+
+type ScanResult string
+
+const (
+	ScanResultValid        ScanResult = "valid"
+	ScanResultAlreadyUsed  ScanResult = "already_used"
+	ScanResultWrongEvent   ScanResult = "wrong_event"
+	ScanResultVoided       ScanResult = "voided"
+	ScanResultNotFound     ScanResult = "not_found"
+	ScanResultInvalidToken ScanResult = "invalid_token"
+)
+
+func (r ScanResult) Valid() bool {
+	switch r {
+	case ScanResultValid, ScanResultAlreadyUsed, ScanResultWrongEvent, ScanResultVoided, ScanResultNotFound, ScanResultInvalidToken:
+		return true
+	}
+	return false
+}
+
+type ScanSource string
+
+const (
+	ScanSourceOnline  ScanSource = "online"
+	ScanSourceOffline ScanSource = "offline"
+)
+
+// TicketScan records every scan at a gate, online or uploaded later by an
+// offline scanner. Offline scans keep the ID the scanner assigned, so
+// uploading a batch twice records each scan once.
+type TicketScan struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	EventID   uuid.UUID  `db:"event_id" json:"event_id"`
+	TicketID  *uuid.UUID `db:"ticket_id" json:"ticket_id,omitempty"`
+	Gate      string     `db:"gate" json:"gate"`
+	DeviceID  *string    `db:"device_id" json:"device_id,omitempty"`
+	Source    ScanSource `db:"source" json:"source"`
+	Result    ScanResult `db:"result" json:"result"`
+	ScannedAt time.Time  `db:"scanned_at" json:"scanned_at"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
+
+// RefundStatus follows a refund through the payment provider. A refund is
+// pending from when its tickets are set aside until the provider answers.
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusSucceeded RefundStatus = "succeeded"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+type Refund struct {
+	BaseModel
+	TransactionID     uuid.UUID    `db:"transaction_id" json:"transaction_id"`
+	Amount            Money        `db:"amount" json:"amount"`
+	Reason            string       `db:"reason" json:"reason"`
+	Actor             string       `db:"actor" json:"actor"`
+	Status            RefundStatus `db:"status" json:"status"`
+	ProviderReference *string      `db:"provider_reference" json:"provider_reference"`
+	FailureReason     *string      `db:"failure_reason" json:"failure_reason,omitempty"`
+	Items             []RefundItem `db:"-" json:"items,omitempty"`
+}
+
+type RefundItem struct {
+	BaseModel
+	RefundID            uuid.UUID `db:"refund_id" json:"refund_id"`
+	TransactionDetailID uuid.UUID `db:"transaction_detail_id" json:"transaction_detail_id"`
+	Quantity            int       `db:"quantity" json:"quantity"`
+	Amount              Money     `db:"amount" json:"amount"`
+}
+
+// TransactionStatusHistory records a single change of a transaction's
+// status or payment_status. Field names which of the two changed.
+type TransactionStatusHistory struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	TransactionID uuid.UUID `db:"transaction_id" json:"transaction_id"`
+	Field         string    `db:"field" json:"field"`
+	FromStatus    *string   `db:"from_status" json:"from_status"`
+	ToStatus      string    `db:"to_status" json:"to_status"`
+	Actor         string    `db:"actor" json:"actor"`
+	Reason        string    `db:"reason" json:"reason"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// IdempotencyKey stores the response produced for a client supplied
+// Idempotency-Key. StatusCode is nil while the original request is still
+// being processed.
+type IdempotencyKey struct {
+	Scope        string    `db:"scope" json:"scope"`
+	Key          string    `db:"key" json:"key"`
+	RequestHash  string    `db:"request_hash" json:"request_hash"`
+	StatusCode   *int      `db:"status_code" json:"status_code"`
+	ResponseBody []byte    `db:"response_body" json:"-"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+}
+
+type EventJobKind string
+
+const (
+	EventJobKindCancel   EventJobKind = "cancel"
+	EventJobKindPostpone EventJobKind = "postpone"
+)
+
+type EventJobStatus string
+
+const (
+	EventJobStatusPending   EventJobStatus = "pending"
+	EventJobStatusRunning   EventJobStatus = "running"
+	EventJobStatusCompleted EventJobStatus = "completed"
+)
+
+// EventJob works through every transaction of an event in the background,
+// refunding them when the event is cancelled and notifying their buyers.
+// LastTransactionID is how far it got, so a job interrupted by a restart
+// resumes where it stopped. Transactions that could not be processed are
+// counted in Failed and left for a manual refund.
+type EventJob struct {
+	ID                uuid.UUID      `db:"id" json:"id"`
+	EventID           uuid.UUID      `db:"event_id" json:"event_id"`
+	Kind              EventJobKind   `db:"kind" json:"kind"`
+	Status            EventJobStatus `db:"status" json:"status"`
+	Reason            string         `db:"reason" json:"reason"`
+	Actor             string         `db:"actor" json:"actor"`
+	Total             int            `db:"total" json:"total"`
+	Processed         int            `db:"processed" json:"processed"`
+	Failed            int            `db:"failed" json:"failed"`
+	LastTransactionID *uuid.UUID     `db:"last_transaction_id" json:"-"`
+	LastError         *string        `db:"last_error" json:"last_error,omitempty"`
+	LockedUntil       *time.Time     `db:"locked_until" json:"-"`
+	CreatedAt         time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time      `db:"updated_at" json:"updated_at"`
+	CompletedAt       *time.Time     `db:"completed_at" json:"completed_at,omitempty"`
+}
+
+type NotificationStatus string
+
+const (
+	NotificationStatusPending NotificationStatus = "pending"
+	NotificationStatusSent    NotificationStatus = "sent"
+	NotificationStatusFailed  NotificationStatus = "failed"
+)
+
+// Notification is a message waiting in the outbox. It is written in the
+// same database transaction as the change it reports and delivered
+// afterwards, so a rolled back change never notifies anyone.
+type Notification struct {
+	ID        uuid.UUID          `db:"id" json:"id"`
+	UserID    *uuid.UUID         `db:"user_id" json:"user_id,omitempty"`
+	Recipient string             `db:"recipient" json:"recipient"`
+	Subject   string             `db:"subject" json:"subject"`
+	Body      string             `db:"body" json:"body"`
+	Status    NotificationStatus `db:"status" json:"status"`
+	Attempts  int                `db:"attempts" json:"attempts"`
+	LastError *string            `db:"last_error" json:"last_error,omitempty"`
+	SentAt    *time.Time         `db:"sent_at" json:"sent_at,omitempty"`
+	CreatedAt time.Time          `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `db:"updated_at" json:"updated_at"`
+}
+
+type DiscountKind string
+
+const (
+	DiscountKindPercent DiscountKind = "percent"
+	DiscountKindFixed   DiscountKind = "fixed"
+)
+
+// PromoCode discounts the tickets of an organizer. A percent code takes
+// PercentOff off every eligible ticket, a fixed code takes AmountOff off
+// the eligible tickets of a transaction together. Without EventIDs or
+// TicketTypeIDs it applies to every event or ticket type of the organizer.
+type PromoCode struct {
+	BaseModel
+	OrganizerID           uuid.UUID    `db:"organizer_id" json:"organizer_id"`
+	Code                  string       `db:"code" json:"code"`
+	Kind                  DiscountKind `db:"kind" json:"kind"`
+	PercentOff            *int         `db:"percent_off" json:"percent_off,omitempty"`
+	AmountOff             *Money       `db:"amount_off" json:"amount_off,omitempty"`
+	Currency              *string      `db:"currency" json:"currency,omitempty"`
+	MinQuantity           int          `db:"min_quantity" json:"min_quantity"`
+	MaxRedemptions        *int         `db:"max_redemptions" json:"max_redemptions,omitempty"`
+	MaxRedemptionsPerUser *int         `db:"max_redemptions_per_user" json:"max_redemptions_per_user,omitempty"`
+	RedemptionCount       int          `db:"redemption_count" json:"redemption_count"`
+	StartsAt              *time.Time   `db:"starts_at" json:"starts_at,omitempty"`
+	EndsAt                *time.Time   `db:"ends_at" json:"ends_at,omitempty"`
+	EventIDs              []uuid.UUID  `db:"-" json:"event_ids"`
+	TicketTypeIDs         []uuid.UUID  `db:"-" json:"ticket_type_ids"`
+}
+
+// ValidAt reports whether the code can be redeemed at the given time.
+func (c *PromoCode) ValidAt(at time.Time) bool {
+	if c.StartsAt != nil && at.Before(*c.StartsAt) {
+		return false
+	}
+	if c.EndsAt != nil && !at.Before(*c.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// PromoCodeRedemption records the use of a promo code for a transaction.
+type PromoCodeRedemption struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	PromoCodeID   uuid.UUID `db:"promo_code_id" json:"promo_code_id"`
+	UserID        uuid.UUID `db:"user_id" json:"user_id"`
+	TransactionID uuid.UUID `db:"transaction_id" json:"transaction_id"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
 }
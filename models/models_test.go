@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestRefundAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency string
+		subtotal int64
+		quantity int
+		refunds  []int
+		want     []int64
+	}{
+		{"even split", "IDR", 3000000, 3, []int{1, 1, 1}, []int64{1000000, 1000000, 1000000}},
+		{"last ticket takes the remainder", "IDR", 1000000, 3, []int{1, 1, 1}, []int64{333300, 333300, 333400}},
+		{"discounted subtotal", "IDR", 899900, 2, []int{1, 1}, []int64{449900, 450000}},
+		{"several tickets at once", "IDR", 1000000, 3, []int{2, 1}, []int64{666600, 333400}},
+		{"all at once", "IDR", 1000000, 3, []int{3}, []int64{1000000}},
+		{"free tickets", "IDR", 0, 2, []int{1, 1}, []int64{0, 0}},
+		{"whole yen", "JPY", 10000000, 3, []int{1, 1, 1}, []int64{3330000, 3330000, 3340000}},
+		{"thousandths of a dinar", "KWD", 10000, 3, []int{1, 1, 1}, []int64{3330, 3330, 3340}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail := TransactionDetail{
+				Quantity: tt.quantity,
+				Subtotal: NewMoney(tt.subtotal, ""),
+			}
+
+			var total int64
+			for i, quantity := range tt.refunds {
+				amount := detail.RefundAmount(quantity, tt.currency)
+				if amount.Amount != tt.want[i] || amount.Currency != tt.currency {
+					t.Errorf("refund %d of %d tickets = %d %s, want %d %s", i+1, quantity, amount.Amount, amount.Currency, tt.want[i], tt.currency)
+				}
+				detail.RefundedQuantity += quantity
+				total += amount.Amount
+			}
+
+			if total != tt.subtotal {
+				t.Errorf("refunds add up to %d, want the subtotal %d", total, tt.subtotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the ISO 4217 code of amounts given without a currency.
+const DefaultCurrency = "IDR"
+
+// moneyScale is the number of decimal places Money keeps, enough for the
+// minor unit of every ISO 4217 currency, and moneyFactor the number of
+// units of Money in a major unit. Money columns are NUMERIC(19,4) to match.
+const (
+	moneyScale        = 4
+	moneyFactor int64 = 10000
+)
+
+// currencyExponents lists the currencies whose minor unit is not a
+// hundredth, by the number of decimal places it takes.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+var (
+	ErrInvalidMoney = errors.New("invalid money amount")
+	// ErrCurrencyMismatch is returned when amounts of different currencies
+	// are added, subtracted or compared.
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money is an exact amount of Currency in ten-thousandths of its major
+// unit, so the minor units of any currency are held in an integer. It
+// reads and writes NUMERIC columns as decimal strings and is encoded in
+// JSON as a string such as "150000.00" so no precision is lost on the way.
+// Rows and requests keep the currency of their amounts beside them, and
+// amounts take it with In.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "1500", "-3.5" or "12.25" in
+// currency. Values finer than the minor unit of the currency are rejected
+// rather than rounded, as are values too large to be held.
+func ParseMoney(value string, currency string) (Money, error) {
+	amount, err := parseAmount(value)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount}.In(currency)
+}
+
+// parseAmount parses a decimal string into units of Money.
+func parseAmount(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+
+	// Trailing zeros beyond the scale are exact
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > moneyScale {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidMoney, value, moneyScale)
+	}
+	fraction += strings.Repeat("0", moneyScale-len(fraction))
+
+	if whole == "" {
+		whole = "0"
+	}
+	for _, digits := range []string{whole, fraction} {
+		for _, r := range digits {
+			if r < '0' || r > '9' {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+			}
+		}
+	}
+
+	major, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is too large", ErrInvalidMoney, value)
+	}
+	minor, _ := strconv.ParseInt(fraction, 10, 64)
+
+	if major > (math.MaxInt64-minor)/moneyFactor {
+		return 0, fmt.Errorf("%w: %q is too large", ErrInvalidMoney, value)
+	}
+
+	amount := major*moneyFactor + minor
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// In returns the amount in currency, which must be an ISO 4217 code whose
+// minor unit the amount is a whole number of.
+func (m Money) In(currency string) (Money, error) {
+	if !validCurrency(currency) {
+		return Money{}, fmt.Errorf("%w: currency %q is not an ISO 4217 code", ErrInvalidMoney, currency)
+	}
+	if m.Amount%CurrencyUnit(currency) != 0 {
+		return Money{}, fmt.Errorf("%w: %s has more decimal places than %s takes", ErrInvalidMoney, m, currency)
+	}
+	return Money{Amount: m.Amount, Currency: currency}, nil
+}
+
+// validCurrency reports whether currency looks like an ISO 4217 code.
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyUnit returns the Amount of one minor unit of currency, such as a
+// cent. Currencies not listed in currencyExponents take hundredths.
+func CurrencyUnit(currency string) int64 {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		exponent = 2
+	}
+
+	unit := int64(1)
+	for i := exponent; i < moneyScale; i++ {
+		unit *= 10
+	}
+	return unit
+}
+
+// String formats the amount with as many decimal places as it needs, but
+// at least two, and no currency.
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	// Negating math.MinInt64 overflows, so the digits are taken from the
+	// unsigned magnitude
+	magnitude := uint64(amount)
+	if amount < 0 {
+		magnitude = -magnitude
+	}
+	fraction := fmt.Sprintf("%04d", magnitude%uint64(moneyFactor))
+	fraction = strings.TrimRight(fraction, "0")
+	fraction += strings.Repeat("0", max(0, 2-len(fraction)))
+
+	return fmt.Sprintf("%s%d.%s", sign, magnitude/uint64(moneyFactor), fraction)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s plus %s is too large", ErrInvalidMoney, m, other)
+	}
+	return Money{Amount: sum, Currency: currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.currencyWith(other)
+	if err != nil {
+		return Money{}, err
+	}
+
+	difference := m.Amount - other.Amount
+	if (other.Amount > 0 && difference > m.Amount) || (other.Amount < 0 && difference < m.Amount) {
+		return Money{}, fmt.Errorf("%w: %s minus %s is too large", ErrInvalidMoney, m, other)
+	}
+	return Money{Amount: difference, Currency: currency}, nil
+}
+
+func (m Money) Mul(quantity int) (Money, error) {
+	product, ok := mulDiv(m.Amount, int64(quantity), 1)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s times %d is too large", ErrInvalidMoney, m, quantity)
+	}
+	return Money{Amount: product, Currency: m.Currency}, nil
+}
+
+// Share returns numerator/denominator of m, rounded toward zero to a minor
+// unit of its currency. The product is taken exactly, so shares of large
+// amounts do not overflow. A share is never larger than m when numerator
+// is at most denominator.
+func (m Money) Share(numerator, denominator int64) Money {
+	unit := CurrencyUnit(m.Currency)
+	units, _ := mulDiv(m.Amount/unit, numerator, denominator)
+	return Money{Amount: units * unit, Currency: m.Currency}
+}
+
+// mulDiv returns a*b/c rounded toward zero, and false when it does not fit
+// in an int64.
+func mulDiv(a, b, c int64) (int64, bool) {
+	result := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	result.Quo(result, big.NewInt(c))
+	if !result.IsInt64() {
+		return 0, false
+	}
+	return result.Int64(), true
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or
+// greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	_, err := m.currencyWith(other)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// currencyWith returns the currency shared by m and other. An amount
+// without a currency matches any currency, so sums may start from Money{}.
+func (m Money) currencyWith(other Money) (string, error) {
+	switch {
+	case m.Currency == "":
+		return other.Currency, nil
+	case other.Currency == "" || other.Currency == m.Currency:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+}
+
+// Value writes the amount as a decimal string, which NUMERIC columns store
+// exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC column. The amount is read without a currency,
+// which its row keeps beside it.
+func (m *Money) Scan(src interface{}) error {
+	var value string
+	switch v := src.(type) {
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	case int64:
+		amount, ok := mulDiv(v, moneyFactor, 1)
+		if !ok {
+			return fmt.Errorf("%w: %d is too large", ErrInvalidMoney, v)
+		}
+		*m = Money{Amount: amount}
+		return nil
+	case nil:
+		*m = Money{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	amount, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: amount}
+	return nil
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts the amount as a string, "12.50", or as a number,
+// 12.50, parsed from its literal text rather than through float64. The
+// currency is given beside the amount.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	value := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	amount, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: amount}
+	return nil
+}
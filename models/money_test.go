@@ -0,0 +1,202 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{value: "1500", currency: "IDR", want: 15000000},
+		{value: "-3.5", currency: "IDR", want: -35000},
+		{value: "12.25", currency: "USD", want: 122500},
+		{value: ".5", currency: "IDR", want: 5000},
+		{value: "7.500000", currency: "IDR", want: 75000},
+		{value: "1500", currency: "JPY", want: 15000000},
+		{value: "1.255", currency: "KWD", want: 12550},
+		{value: "0.0001", currency: "CLF", want: 1},
+		{value: "922337203685477.5807", currency: "CLF", want: math.MaxInt64},
+		{value: "922337203685477.5808", currency: "CLF", wantErr: ErrInvalidMoney},
+		{value: "99999999999999999999", currency: "IDR", wantErr: ErrInvalidMoney},
+		{value: "1.005", currency: "IDR", wantErr: ErrInvalidMoney},
+		{value: "1.5", currency: "JPY", wantErr: ErrInvalidMoney},
+		{value: "1.25551", currency: "KWD", wantErr: ErrInvalidMoney},
+		{value: "1e3", currency: "IDR", wantErr: ErrInvalidMoney},
+		{value: "", currency: "IDR", wantErr: ErrInvalidMoney},
+		{value: ".", currency: "IDR", wantErr: ErrInvalidMoney},
+		{value: "10", currency: "", wantErr: ErrInvalidMoney},
+		{value: "10", currency: "idr", wantErr: ErrInvalidMoney},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("ParseMoney(%q, %q) error = %v, want %v", tt.value, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (got.Amount != tt.want || got.Currency != tt.currency) {
+			t.Errorf("ParseMoney(%q, %q) = %d %s, want %d %s", tt.value, tt.currency, got.Amount, got.Currency, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	idr := NewMoney(10000, "IDR")
+	usd := NewMoney(10000, "USD")
+
+	if _, err := idr.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add() error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := idr.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub() error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := idr.Cmp(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp() error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+
+	// A sum may start from the zero value
+	sum, err := Money{}.Add(idr)
+	if err != nil || sum != idr {
+		t.Errorf("Money{}.Add(%v) = %v %s, %v, want %v IDR", idr, sum, sum.Currency, err, idr)
+	}
+}
+
+func TestMoneyOverflow(t *testing.T) {
+	large := NewMoney(math.MaxInt64-1, "IDR")
+	small := NewMoney(math.MinInt64+1, "IDR")
+
+	if _, err := large.Add(NewMoney(2, "IDR")); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("Add() error = %v, want %v", err, ErrInvalidMoney)
+	}
+	if _, err := small.Add(NewMoney(-2, "IDR")); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("Add() of a negative amount error = %v, want %v", err, ErrInvalidMoney)
+	}
+	if _, err := small.Sub(NewMoney(2, "IDR")); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("Sub() error = %v, want %v", err, ErrInvalidMoney)
+	}
+	if _, err := large.Sub(NewMoney(-2, "IDR")); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("Sub() of a negative amount error = %v, want %v", err, ErrInvalidMoney)
+	}
+	if _, err := NewMoney(math.MaxInt64/2+1, "IDR").Mul(2); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("Mul() error = %v, want %v", err, ErrInvalidMoney)
+	}
+
+	product, err := NewMoney(15000000, "IDR").Mul(3)
+	if err != nil || product != NewMoney(45000000, "IDR") {
+		t.Errorf("Mul(3) = %v %s, %v, want 4500.00 IDR", product, product.Currency, err)
+	}
+}
+
+func TestMoneyShare(t *testing.T) {
+	tests := []struct {
+		money       Money
+		numerator   int64
+		denominator int64
+		want        int64
+	}{
+		{NewMoney(1000000, "IDR"), 1, 3, 333300},
+		{NewMoney(10000000, "JPY"), 1, 3, 3330000},
+		{NewMoney(10000, "KWD"), 1, 3, 3330},
+		{NewMoney(999900, "USD"), 15, 100, 149900},
+		// The product does not fit in an int64, the share does
+		{NewMoney(1000000000000000000, "IDR"), 1000000000000000000, 2000000000000000000, 500000000000000000},
+	}
+
+	for _, tt := range tests {
+		got := tt.money.Share(tt.numerator, tt.denominator)
+		if got.Amount != tt.want || got.Currency != tt.money.Currency {
+			t.Errorf("%v %s.Share(%d, %d) = %d %s, want %d", tt.money, tt.money.Currency, tt.numerator, tt.denominator, got.Amount, got.Currency, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{15000000, "1500.00"},
+		{-35000, "-3.50"},
+		{12550, "1.255"},
+		{1, "0.0001"},
+		{math.MinInt64, "-922337203685477.5808"},
+	}
+
+	for _, tt := range tests {
+		if got := NewMoney(tt.amount, "IDR").String(); got != tt.want {
+			t.Errorf("String() of %d = %s, want %s", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyValueAndScan(t *testing.T) {
+	value, err := NewMoney(-15005000, "USD").Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "-1500.50" {
+		t.Errorf("Value() = %v, want -1500.50", value)
+	}
+
+	tests := []struct {
+		src  interface{}
+		want int64
+	}{
+		{[]byte("-1500.5000"), -15005000},
+		{"1.255", 12550},
+		{int64(1500), 15000000},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%#v) error = %v", tt.src, err)
+			continue
+		}
+		if m != NewMoney(tt.want, "") {
+			t.Errorf("Scan(%#v) = %d %s, want %d without a currency", tt.src, m.Amount, m.Currency, tt.want)
+		}
+	}
+
+	var m Money
+	for _, src := range []interface{}{"(1500.50,USD)", "1.00001", float64(1500.5), int64(math.MaxInt64)} {
+		if err := m.Scan(src); err == nil {
+			t.Errorf("Scan(%#v) succeeded, want an error", src)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(NewMoney(122500, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"12.25"` {
+		t.Errorf("Marshal() = %s, want \"12.25\"", data)
+	}
+
+	for _, data := range []string{`"12.25"`, `12.25`, `"12.2500"`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", data, err)
+			continue
+		}
+		if m != NewMoney(122500, "") {
+			t.Errorf("Unmarshal(%s) = %d %s, want 122500 without a currency", data, m.Amount, m.Currency)
+		}
+	}
+
+	for _, data := range []string{`"99999999999999999999"`, `{"amount":"12.25","currency":"USD"}`, `"12.25001"`} {
+		var m Money
+		if err := json.Unmarshal([]byte(data), &m); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("Unmarshal(%s) error = %v, want %v", data, err, ErrInvalidMoney)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package notification
+
+import "log"
+
+const LogProvider = "log"
+
+// LogSender writes notifications to the server log instead of delivering
+// them, for development and until a real provider is configured.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Name() string {
+	return LogProvider
+}
+
+func (s *LogSender) Send(msg Message) error {
+	log.Printf("Notification to %s: %s\n%s", msg.Recipient, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,16 @@
+package notification
+
+// Message is a single notification addressed to one recipient.
+type Message struct {
+	Recipient string
+	Subject   string
+	Body      string
+}
+
+// Sender is implemented by every channel notifications can be delivered
+// through. Send may be called again for a message it already delivered, so
+// recipients can see a duplicate after a crash but never miss a message.
+type Sender interface {
+	Name() string
+	Send(msg Message) error
+}
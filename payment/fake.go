@@ -0,0 +1,167 @@
+package payment
+
+import (
+	"encoding/json"
+	"go-ticket/models"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const FakeProvider = "fake"
+
+type fakeCharge struct {
+	amount   models.Money
+	refunded models.Money
+	status   ChargeStatus
+}
+
+// FakeGateway is an in-process provider for development and tests. Charges
+// live in memory and are settled by calling Simulate, which is exposed over
+// HTTP by the payment handler.
+type FakeGateway struct {
+	mu            sync.Mutex
+	baseURL       string
+	charges       map[string]*fakeCharge
+	byTransaction map[uuid.UUID]string
+	notify        func(Event) error
+}
+
+func NewFakeGateway(baseURL string) *FakeGateway {
+	return &FakeGateway{
+		baseURL:       baseURL,
+		charges:       make(map[string]*fakeCharge),
+		byTransaction: make(map[uuid.UUID]string),
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return FakeProvider
+}
+
+func (g *FakeGateway) SupportsMethod(method models.PaymentMethod) bool {
+	return method.Valid()
+}
+
+// OnEvent registers the function Simulate calls after a charge changes.
+func (g *FakeGateway) OnEvent(notify func(Event) error) {
+	g.notify = notify
+}
+
+func (g *FakeGateway) CreateCharge(req ChargeRequest) (*Charge, error) {
+	if !g.SupportsMethod(req.Method) {
+		return nil, ErrUnsupportedMethod
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reference, ok := g.byTransaction[req.TransactionID]
+	if !ok {
+		reference = "fake_" + uuid.NewString()
+		g.byTransaction[req.TransactionID] = reference
+		g.charges[reference] = &fakeCharge{
+			amount: req.Amount,
+			status: ChargeStatusPending,
+		}
+	}
+
+	return &Charge{
+		Reference:  reference,
+		PaymentURL: g.baseURL + "/v1/payments/fake/" + reference,
+		Status:     g.charges[reference].status,
+	}, nil
+}
+
+func (g *FakeGateway) GetChargeStatus(reference string) (ChargeStatus, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[reference]
+	if !ok {
+		return "", ErrChargeNotFound
+	}
+	return charge.status, nil
+}
+
+func (g *FakeGateway) Refund(req RefundRequest) (*Refund, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[req.Reference]
+	if !ok {
+		return nil, ErrChargeNotFound
+	}
+	if charge.status != ChargeStatusPaid {
+		return nil, ErrRefundNotAllowed
+	}
+	refunded, err := charge.refunded.Add(req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	cmp, err := refunded.Cmp(charge.amount)
+	if err != nil {
+		return nil, err
+	}
+	if cmp > 0 {
+		return nil, ErrRefundExceedsCharge
+	}
+
+	charge.refunded = refunded
+	if cmp == 0 {
+		charge.status = ChargeStatusRefunded
+	}
+
+	return &Refund{
+		Reference: "fake_refund_" + uuid.NewString(),
+		Amount:    req.Amount,
+	}, nil
+}
+
+// ParseEvent decodes a fake webhook body of the form
+// {"reference": "fake_...", "status": "paid"}.
+func (g *FakeGateway) ParseEvent(body []byte) (*Event, error) {
+	var payload struct {
+		Reference string       `json:"reference"`
+		Status    ChargeStatus `json:"status"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Reference == "" {
+		return nil, ErrInvalidPayload
+	}
+
+	return &Event{
+		Provider:  FakeProvider,
+		Reference: payload.Reference,
+		Status:    payload.Status,
+		Payload:   body,
+	}, nil
+}
+
+// Simulate settles a pending charge as paid or failed, the way a buyer
+// completing or abandoning the provider's checkout would, and reports the
+// change to the registered event handler. A charge is settled only once,
+// so a paid or refunded charge cannot be turned back.
+func (g *FakeGateway) Simulate(reference string, status ChargeStatus) error {
+	g.mu.Lock()
+	charge, ok := g.charges[reference]
+	if !ok {
+		g.mu.Unlock()
+		return ErrChargeNotFound
+	}
+	if charge.status != ChargeStatusPending {
+		g.mu.Unlock()
+		return ErrChargeSettled
+	}
+	charge.status = status
+	g.mu.Unlock()
+
+	if g.notify == nil {
+		return nil
+	}
+
+	return g.notify(Event{
+		Provider:  FakeProvider,
+		Reference: reference,
+		Status:    status,
+	})
+}
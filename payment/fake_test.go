@@ -0,0 +1,48 @@
+package payment
+
+import (
+	"errors"
+	"go-ticket/models"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFakeSimulateSettlesOnce(t *testing.T) {
+	g := NewFakeGateway("http://localhost:8000")
+	var events []Event
+	g.OnEvent(func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+
+	charge, err := g.CreateCharge(ChargeRequest{
+		TransactionID: uuid.New(),
+		Amount:        models.NewMoney(1000000, "IDR"),
+		Method:        models.PaymentMethodBankTransfer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.Simulate(charge.Reference, ChargeStatusPaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []ChargeStatus{ChargeStatusFailed, ChargeStatusPaid} {
+		if err := g.Simulate(charge.Reference, status); !errors.Is(err, ErrChargeSettled) {
+			t.Errorf("Simulate(%s) of a paid charge error = %v, want %v", status, err, ErrChargeSettled)
+		}
+	}
+
+	status, err := g.GetChargeStatus(charge.Reference)
+	if err != nil || status != ChargeStatusPaid {
+		t.Errorf("GetChargeStatus() = %s, %v, want %s", status, err, ChargeStatusPaid)
+	}
+	if len(events) != 1 {
+		t.Errorf("Simulate() reported %d events, want 1", len(events))
+	}
+
+	if err := g.Simulate("fake_missing", ChargeStatusPaid); !errors.Is(err, ErrChargeNotFound) {
+		t.Errorf("Simulate() of an unknown charge error = %v, want %v", err, ErrChargeNotFound)
+	}
+}
@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"errors"
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrChargeNotFound      = errors.New("charge not found")
+	ErrUnsupportedMethod   = errors.New("unsupported payment method")
+	ErrRefundNotAllowed    = errors.New("charge cannot be refunded")
+	ErrRefundExceedsCharge = errors.New("refund exceeds charged amount")
+	ErrChargeSettled       = errors.New("charge is already settled")
+)
+
+// ChargeStatus is the state of a charge as reported by the provider.
+type ChargeStatus string
+
+const (
+	ChargeStatusPending  ChargeStatus = "pending"
+	ChargeStatusPaid     ChargeStatus = "paid"
+	ChargeStatusFailed   ChargeStatus = "failed"
+	ChargeStatusRefunded ChargeStatus = "refunded"
+)
+
+type ChargeRequest struct {
+	TransactionID uuid.UUID
+	Amount        models.Money
+	Method        models.PaymentMethod
+	Description   string
+}
+
+type Charge struct {
+	Reference  string
+	PaymentURL string
+	Status     ChargeStatus
+}
+
+type RefundRequest struct {
+	Reference string
+	Amount    models.Money
+	Reason    string
+}
+
+type Refund struct {
+	Reference string
+	Amount    models.Money
+}
+
+// Event is a charge status change pushed by a provider.
+type Event struct {
+	Provider  string
+	Reference string
+	Status    ChargeStatus
+	Payload   []byte
+}
+
+// Gateway is implemented by every payment provider the server can charge
+// through. Reference is the provider's own identifier for a charge.
+type Gateway interface {
+	Name() string
+	SupportsMethod(method models.PaymentMethod) bool
+	// CreateCharge is keyed by the transaction: asking again for a
+	// transaction that already has a charge returns that charge.
+	CreateCharge(req ChargeRequest) (*Charge, error)
+	GetChargeStatus(reference string) (ChargeStatus, error)
+	Refund(req RefundRequest) (*Refund, error)
+	// ParseEvent decodes the body of a webhook request sent by the provider.
+	// The signature has already been verified by the caller.
+	ParseEvent(body []byte) (*Event, error)
+}
@@ -0,0 +1,61 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Webhook requests carry the signature of "<timestamp>.<nonce>.<body>" in
+// HeaderSignature as hex encoded HMAC-SHA256. The timestamp is in Unix
+// seconds.
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderNonce     = "X-Webhook-Nonce"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrStaleWebhook     = errors.New("webhook timestamp outside the allowed window")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// Sign returns the signature a provider sends for body.
+func Sign(secret []byte, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks that signature was produced by Sign with secret and
+// that timestamp is no further than tolerance from now.
+func VerifySignature(secret []byte, timestamp, nonce string, body []byte, signature string, now time.Time, tolerance time.Duration) error {
+	if len(secret) == 0 || nonce == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	expected := Sign(secret, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	sentAt := time.Unix(seconds, 0)
+	if sentAt.Before(now.Add(-tolerance)) || sentAt.After(now.Add(tolerance)) {
+		return ErrStaleWebhook
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"reference":"fake_1","status":"paid"}`)
+	now := time.Unix(1_700_000_000, 0)
+	tolerance := 5 * time.Minute
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(secret, timestamp, "nonce-1", body)
+
+	at := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		nonce     string
+		body      []byte
+		signature string
+		wantErr   error
+	}{
+		{"valid", secret, timestamp, "nonce-1", body, signature, nil},
+		{"wrong secret", []byte("other-secret"), timestamp, "nonce-1", body, signature, ErrInvalidSignature},
+		{"no secret", nil, timestamp, "nonce-1", body, Sign(nil, timestamp, "nonce-1", body), ErrInvalidSignature},
+		{"tampered body", secret, timestamp, "nonce-1", []byte(`{"reference":"fake_1","status":"failed"}`), signature, ErrInvalidSignature},
+		{"changed nonce", secret, timestamp, "nonce-2", body, signature, ErrInvalidSignature},
+		{"no nonce", secret, timestamp, "", body, Sign(secret, timestamp, "", body), ErrInvalidSignature},
+		{"changed timestamp", secret, at(now.Add(time.Second)), "nonce-1", body, signature, ErrInvalidSignature},
+		{"no signature", secret, timestamp, "nonce-1", body, "", ErrInvalidSignature},
+		{"malformed timestamp", secret, "yesterday", "nonce-1", body, Sign(secret, "yesterday", "nonce-1", body), ErrInvalidSignature},
+		{
+			name:      "edge of the window",
+			secret:    secret,
+			timestamp: at(now.Add(-tolerance)),
+			nonce:     "nonce-1",
+			body:      body,
+			signature: Sign(secret, at(now.Add(-tolerance)), "nonce-1", body),
+		},
+		{
+			name:      "replayed after the window",
+			secret:    secret,
+			timestamp: at(now.Add(-tolerance - time.Second)),
+			nonce:     "nonce-1",
+			body:      body,
+			signature: Sign(secret, at(now.Add(-tolerance-time.Second)), "nonce-1", body),
+			wantErr:   ErrStaleWebhook,
+		},
+		{
+			name:      "from the future",
+			secret:    secret,
+			timestamp: at(now.Add(tolerance + time.Second)),
+			nonce:     "nonce-1",
+			body:      body,
+			signature: Sign(secret, at(now.Add(tolerance+time.Second)), "nonce-1", body),
+			wantErr:   ErrStaleWebhook,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.secret, tt.timestamp, tt.nonce, tt.body, tt.signature, now, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifySignature() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
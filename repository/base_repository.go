@@ -1,8 +1,13 @@
 package repository
 
 import (
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type BaseRepository[T any] interface {
@@ -10,57 +15,136 @@ type BaseRepository[T any] interface {
 	FindById(id uuid.UUID) (*T, error)
 	Create(entity *T) error
 	Update(id uuid.UUID, entity *T) error
+	UpdateColumns(id uuid.UUID, entity *T, columns ...string) error
 	Delete(id uuid.UUID) error
 }
 
 type Repository[T any] struct {
-	db        *sqlx.DB
+	db        DBTX
 	tableName string
+	// scoped limits every query to the rows of organizerIds. Only tables
+	// with an organizer_id column can be scoped.
+	scoped       bool
+	organizerIds []uuid.UUID
 }
 
-func NewRepository[T any](db *sqlx.DB, tableName string) *Repository[T] {
+func NewRepository[T any](db DBTX, tableName string) *Repository[T] {
 	return &Repository[T]{
 		db:        db,
 		tableName: tableName,
 	}
 }
 
+// withTx returns a copy of the repository bound to tx.
+func (r *Repository[T]) withTx(tx *sqlx.Tx) *Repository[T] {
+	bound := *r
+	bound.db = tx
+	return &bound
+}
+
+// forTenant returns a copy of the repository that only sees the rows of
+// organizerIds. An empty tenant sees no rows at all.
+func (r *Repository[T]) forTenant(organizerIds []uuid.UUID) *Repository[T] {
+	scoped := *r
+	scoped.scoped = true
+	scoped.organizerIds = append([]uuid.UUID{}, organizerIds...)
+	return &scoped
+}
+
+// tenantFilter returns the condition limiting column to the tenant of a
+// scoped repository, with its argument appended to args. Unscoped
+// repositories get an empty condition.
+func (r *Repository[T]) tenantFilter(column string, args []interface{}) (string, []interface{}) {
+	if !r.scoped {
+		return "", args
+	}
+	args = append(args, pq.Array(r.organizerIds))
+	return fmt.Sprintf(" AND %s = ANY($%d)", column, len(args)), args
+}
+
+// namedTenantFilter is tenantFilter for named queries, adding its argument
+// to params.
+func (r *Repository[T]) namedTenantFilter(column string, params map[string]interface{}) string {
+	if !r.scoped {
+		return ""
+	}
+	params["tenant_organizer_ids"] = pq.Array(r.organizerIds)
+	return " AND " + column + " = ANY(:tenant_organizer_ids)"
+}
+
 func (r *Repository[T]) FindAll() ([]T, error) {
 	var entities []T
-	query := "SELECT * FROM " + r.tableName + " WHERE deleted_at IS NULL"
-	err := r.db.Select(&entities, query)
+	filter, args := r.tenantFilter("organizer_id", nil)
+	query := "SELECT * FROM " + r.tableName + " WHERE deleted_at IS NULL" + filter
+	err := r.db.Select(&entities, query, args...)
 	return entities, err
 }
 
 func (r *Repository[T]) FindById(id uuid.UUID) (*T, error) {
 	var entity T
-	query := "SELECT * FROM " + r.tableName + " WHERE id = $1 AND deleted_at IS NULL"
-	err := r.db.Get(&entity, query, id)
+	filter, args := r.tenantFilter("organizer_id", []interface{}{id})
+	query := "SELECT * FROM " + r.tableName + " WHERE id = $1 AND deleted_at IS NULL" + filter
+	err := r.db.Get(&entity, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	return &entity, nil
 }
 
+// Create inserts entity with every column its db tags name.
 func (r *Repository[T]) Create(entity *T) error {
-	query := "INSERT INTO " + r.tableName + " VALUES (:entity) RETURNING *"
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"entity": entity,
-	})
+	columns := columnsOf[T]().insert
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (:%s)",
+		r.tableName, strings.Join(columns, ", "), strings.Join(columns, ", :"))
+	_, err := r.db.NamedExec(query, columnsOf[T]().params(entity, columns))
 	return err
 }
 
+// Update writes every column of entity except the id and the creation and
+// deletion timestamps.
 func (r *Repository[T]) Update(id uuid.UUID, entity *T) error {
-	query := "UPDATE " + r.tableName + " SET entity = :entity WHERE id = :id AND deleted_at IS NULL"
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":     id,
-		"entity": entity,
-	})
+	return r.UpdateColumns(id, entity, columnsOf[T]().update...)
+}
+
+// UpdateColumns writes only the given columns of entity, along with
+// updated_at when the entity has one.
+func (r *Repository[T]) UpdateColumns(id uuid.UUID, entity *T, columns ...string) error {
+	entityColumns := columnsOf[T]()
+	if entityColumns.has("updated_at") && !slices.Contains(columns, "updated_at") {
+		columns = append(columns, "updated_at")
+	}
+
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		if !entityColumns.has(column) || immutableColumns[column] {
+			return fmt.Errorf("%s has no updatable column %s", r.tableName, column)
+		}
+		assignments[i] = column + " = :" + column
+	}
+
+	params := entityColumns.params(entity, columns)
+	params["id"] = id
+
+	query := "UPDATE " + r.tableName + " SET " + strings.Join(assignments, ", ") + " WHERE id = :id"
+	if entityColumns.has("deleted_at") {
+		query += " AND deleted_at IS NULL"
+	}
+	query += r.namedTenantFilter("organizer_id", params)
+
+	_, err := r.db.NamedExec(query, params)
 	return err
 }
 
 func (r *Repository[T]) Delete(id uuid.UUID) error {
-	query := "UPDATE " + r.tableName + " SET deleted_at = NOW() WHERE id = $1"
-	_, err := r.db.Exec(query, id)
+	filter, args := r.tenantFilter("organizer_id", []interface{}{id})
+	query := "UPDATE " + r.tableName + " SET deleted_at = NOW() WHERE id = $1" + filter
+	_, err := r.db.Exec(query, args...)
 	return err
 }
+
+// list returns a page of the rows matching where, limited to the tenant of
+// a scoped repository.
+func (r *Repository[T]) list(q *ListQuery, spec ListSpec, where string, args ...interface{}) (*Page[T], error) {
+	filter, args := r.tenantFilter("organizer_id", args)
+	return listPage[T](r.db, r.tableName, q, spec, where+filter, args)
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// entityColumns lists the columns of an entity type as named by the db tags
+// of its fields, including the fields of embedded structs such as
+// BaseModel. Fields tagged db:"-" are relations and have no column.
+type entityColumns struct {
+	all    []string
+	fields map[string][]int
+	// insert leaves out deleted_at, and update also leaves out the id and
+	// created_at, which never change once a row exists.
+	insert []string
+	update []string
+}
+
+// columnCache holds the *entityColumns of every entity type, derived once
+// on first use.
+var columnCache sync.Map
+
+func columnsOf[T any]() *entityColumns {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if cached, ok := columnCache.Load(t); ok {
+		return cached.(*entityColumns)
+	}
+
+	c := &entityColumns{fields: map[string][]int{}}
+	c.collect(t, nil)
+
+	for _, column := range c.all {
+		if column != "deleted_at" {
+			c.insert = append(c.insert, column)
+		}
+		if !immutableColumns[column] {
+			c.update = append(c.update, column)
+		}
+	}
+
+	cached, _ := columnCache.LoadOrStore(t, c)
+	return cached.(*entityColumns)
+}
+
+var immutableColumns = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"deleted_at": true,
+}
+
+func (c *entityColumns) collect(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		path := append(append([]int{}, index...), i)
+
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			c.collect(field.Type, path)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if _, ok := c.fields[name]; ok {
+			continue
+		}
+		c.all = append(c.all, name)
+		c.fields[name] = path
+	}
+}
+
+func (c *entityColumns) has(column string) bool {
+	_, ok := c.fields[column]
+	return ok
+}
+
+// params returns the values of columns in entity, keyed by column name for
+// a named query.
+func (c *entityColumns) params(entity interface{}, columns []string) map[string]interface{} {
+	v := reflect.Indirect(reflect.ValueOf(entity))
+
+	params := make(map[string]interface{}, len(columns))
+	for _, column := range columns {
+		params[column] = v.FieldByIndex(c.fields[column]).Interface()
+	}
+	return params
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type EventJobRepository struct {
+	db DBTX
+}
+
+func NewEventJobRepository(db *sqlx.DB) *EventJobRepository {
+	return &EventJobRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *EventJobRepository) WithTx(tx *sqlx.Tx) *EventJobRepository {
+	return &EventJobRepository{
+		db: tx,
+	}
+}
+
+func (r *EventJobRepository) FindById(id uuid.UUID) (*models.EventJob, error) {
+	query := `SELECT * FROM event_jobs WHERE id = $1`
+
+	var job models.EventJob
+	err := r.db.Get(&job, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// FindByEventId returns the jobs of an event, newest first.
+func (r *EventJobRepository) FindByEventId(eventId uuid.UUID) ([]models.EventJob, error) {
+	query := `
+		SELECT * FROM event_jobs
+		WHERE event_id = $1
+		ORDER BY created_at DESC
+	`
+
+	jobs := []models.EventJob{}
+	err := r.db.Select(&jobs, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// HasActive reports whether the event has a job that has not completed.
+func (r *EventJobRepository) HasActive(eventId uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM event_jobs
+			WHERE event_id = $1
+			AND status <> 'completed'
+		)
+	`
+
+	var active bool
+	err := r.db.Get(&active, query, eventId)
+	return active, err
+}
+
+func (r *EventJobRepository) Create(job *models.EventJob) error {
+	query := `
+		INSERT INTO event_jobs (
+			id, event_id, kind, status, reason, actor,
+			total, created_at, updated_at
+		) VALUES (
+			:id, :event_id, :kind, :status, :reason, :actor,
+			:total, :created_at, :updated_at
+		)
+	`
+
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":         job.ID,
+		"event_id":   job.EventID,
+		"kind":       job.Kind,
+		"status":     job.Status,
+		"reason":     job.Reason,
+		"actor":      job.Actor,
+		"total":      job.Total,
+		"created_at": job.CreatedAt,
+		"updated_at": job.UpdatedAt,
+	})
+	return err
+}
+
+// Claim leases the oldest job that has not completed and is not leased to
+// another worker, marking it running until leasedUntil. A worker that dies
+// mid-batch loses its lease, and the job is picked up again from its last
+// saved progress. It returns sql.ErrNoRows when there is nothing to run.
+func (r *EventJobRepository) Claim(now, leasedUntil time.Time) (*models.EventJob, error) {
+	query := `
+		UPDATE event_jobs
+		SET status = 'running', locked_until = $2, updated_at = $1
+		WHERE id = (
+			SELECT id FROM event_jobs
+			WHERE status <> 'completed'
+			AND (locked_until IS NULL OR locked_until <= $1)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`
+
+	var job models.EventJob
+	err := r.db.Get(&job, query, now, leasedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// SaveProgress stores the progress of a job and releases its lease.
+func (r *EventJobRepository) SaveProgress(job *models.EventJob) error {
+	query := `
+		UPDATE event_jobs
+		SET status = $1, processed = $2, failed = $3, last_transaction_id = $4,
+			last_error = $5, completed_at = $6, locked_until = NULL, updated_at = NOW()
+		WHERE id = $7
+	`
+
+	_, err := r.db.Exec(query,
+		job.Status, job.Processed, job.Failed, job.LastTransactionID,
+		job.LastError, job.CompletedAt, job.ID,
+	)
+	return err
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type EventRepository struct {
@@ -17,129 +18,149 @@ func NewEventRepository(db *sqlx.DB) *EventRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *EventRepository) WithTx(tx *sqlx.Tx) *EventRepository {
+	return &EventRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// ForTenant returns a copy of the repository that only sees the events of
+// organizerIds.
+func (r *EventRepository) ForTenant(organizerIds []uuid.UUID) *EventRepository {
+	return &EventRepository{
+		Repository: r.Repository.forTenant(organizerIds),
+	}
+}
+
 // Custom methods for EventRepository
 func (r *EventRepository) FindWithRelations(id uuid.UUID) (*models.Event, error) {
-	query := `
-		SELECT e.*, l.*, s.*
-		FROM events e
-		LEFT JOIN locations l ON e.location_id = l.id
-		LEFT JOIN schedules s ON e.schedule_id = s.id
-		WHERE e.id = $1 AND e.deleted_at IS NULL
-	`
-
-	rows, err := r.db.Queryx(query, id)
+	event, err := r.FindById(id)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var event models.Event
-	var location models.Location
-	var schedule models.Schedule
-
-	if rows.Next() {
-		err = rows.Scan(
-			&event.ID, &event.Name, &event.Description, &event.LocationID, &event.ScheduleID,
-			&event.CreatedAt, &event.UpdatedAt, &event.DeletedAt,
-			&location.ID, &location.Name, &location.Address, &location.City,
-			&location.State, &location.Country, &location.PostalCode,
-			&location.CreatedAt, &location.UpdatedAt, &location.DeletedAt,
-			&schedule.ID, &schedule.Title, &schedule.Description, &schedule.StartDate, &schedule.EndDate,
-			&schedule.CreatedAt, &schedule.UpdatedAt, &schedule.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		event.Location = &location
-		event.Schedule = &schedule
+	events := []models.Event{*event}
+	err = r.attachRelations(events)
+	if err != nil {
+		return nil, err
 	}
 
-	return &event, nil
+	return &events[0], nil
 }
 
-func (r *EventRepository) FindAllWithRelations() ([]models.Event, error) {
-	query := `
-		SELECT e.*, l.*, s.*
-		FROM events e
-		LEFT JOIN locations l ON e.location_id = l.id
-		LEFT JOIN schedules s ON e.schedule_id = s.id
-		WHERE e.deleted_at IS NULL
-	`
+func (r *EventRepository) Update(event *models.Event) error {
+	return r.UpdateColumns(event.ID, event,
+		"name", "description", "location_id", "schedule_id",
+		"max_per_transaction", "max_per_user", "limit_by_contact")
+}
+
+var eventListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"name":         {Column: "name", Op: FilterContains},
+		"location_id":  {Column: "location_id", Parse: parseUUIDFilter},
+		"schedule_id":  {Column: "schedule_id", Parse: parseUUIDFilter},
+		"organizer_id": {Column: "organizer_id", Parse: parseUUIDFilter},
+		"status":       {Column: "status"},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+		"updated_at": "updated_at",
+	},
+}
+
+// List returns a page of events with their location and schedule.
+func (r *EventRepository) List(q *ListQuery) (*Page[models.Event], error) {
+	page, err := r.list(q, eventListSpec, "deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.attachRelations(page.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
 
-	rows, err := r.db.Queryx(query)
+// ListListed is List limited to the events the public sees, along with
+// every event of organizerIds.
+func (r *EventRepository) ListListed(q *ListQuery, organizerIds []uuid.UUID) (*Page[models.Event], error) {
+	where := "deleted_at IS NULL AND (status = ANY($1) OR organizer_id = ANY($2))"
+	page, err := r.list(q, eventListSpec, where, pq.Array(models.ListedEventStatuses), pq.Array(organizerIds))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var events []models.Event
-
-	for rows.Next() {
-		var event models.Event
-		var location models.Location
-		var schedule models.Schedule
-
-		err = rows.Scan(
-			&event.ID, &event.Name, &event.Description, &event.LocationID, &event.ScheduleID,
-			&event.CreatedAt, &event.UpdatedAt, &event.DeletedAt,
-			&location.ID, &location.Name, &location.Address, &location.City,
-			&location.State, &location.Country, &location.PostalCode,
-			&location.CreatedAt, &location.UpdatedAt, &location.DeletedAt,
-			&schedule.ID, &schedule.Title, &schedule.Description, &schedule.StartDate, &schedule.EndDate,
-			&schedule.CreatedAt, &schedule.UpdatedAt, &schedule.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		event.Location = &location
-		event.Schedule = &schedule
-		events = append(events, event)
+
+	err = r.attachRelations(page.Items)
+	if err != nil {
+		return nil, err
 	}
 
-	return events, nil
+	return page, nil
 }
 
-func (r *EventRepository) Create(event *models.Event) error {
+// FindByIdForShare loads an event and keeps its status from changing until
+// the surrounding transaction ends, while other purchases read it too.
+func (r *EventRepository) FindByIdForShare(id uuid.UUID) (*models.Event, error) {
 	query := `
-		INSERT INTO events (
-			id, name, description, location_id, schedule_id,
-			created_at, updated_at
-		) VALUES (
-			:id, :name, :description, :location_id, :schedule_id,
-			:created_at, :updated_at
-		)
+		SELECT * FROM events
+		WHERE id = $1
+		AND deleted_at IS NULL
+		FOR SHARE
 	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          event.ID,
-		"name":        event.Name,
-		"description": event.Description,
-		"location_id": event.LocationID,
-		"schedule_id": event.ScheduleID,
-		"created_at":  event.CreatedAt,
-		"updated_at":  event.UpdatedAt,
-	})
-	return err
+
+	var event models.Event
+	err := r.db.Get(&event, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
 }
 
-func (r *EventRepository) Update(event *models.Event) error {
+// FindByIdForUpdate loads an event and locks its row until the surrounding
+// transaction ends.
+func (r *EventRepository) FindByIdForUpdate(id uuid.UUID) (*models.Event, error) {
 	query := `
-		UPDATE events SET
-			name = :name,
-			description = :description,
-			location_id = :location_id,
-			schedule_id = :schedule_id,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
+		SELECT * FROM events
+		WHERE id = $1
+		AND deleted_at IS NULL
+		FOR UPDATE
 	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          event.ID,
-		"name":        event.Name,
-		"description": event.Description,
-		"location_id": event.LocationID,
-		"schedule_id": event.ScheduleID,
-		"updated_at":  event.UpdatedAt,
-	})
-	return err
+
+	var event models.Event
+	err := r.db.Get(&event, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *EventRepository) UpdateStatus(event *models.Event) error {
+	return r.UpdateColumns(event.ID, event, "status")
+}
+
+// Reschedule stores the status, schedule and refund deadline of a
+// postponed event.
+func (r *EventRepository) Reschedule(event *models.Event) error {
+	return r.UpdateColumns(event.ID, event, "status", "schedule_id", "refund_deadline")
+}
+
+// attachRelations loads the locations and schedules of events with one
+// query each.
+func (r *EventRepository) attachRelations(events []models.Event) error {
+	err := belongsTo(r.db, "locations", events,
+		func(e *models.Event) uuid.UUID { return e.LocationID },
+		func(e *models.Event, l *models.Location) { e.Location = l },
+	)
+	if err != nil {
+		return err
+	}
+
+	return belongsTo(r.db, "schedules", events,
+		func(e *models.Event) uuid.UUID { return e.ScheduleID },
+		func(e *models.Event, s *models.Schedule) { e.Schedule = s },
+	)
 }
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type EventSeatRepository struct {
+	db DBTX
+}
+
+func NewEventSeatRepository(db *sqlx.DB) *EventSeatRepository {
+	return &EventSeatRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *EventSeatRepository) WithTx(tx *sqlx.Tx) *EventSeatRepository {
+	return &EventSeatRepository{
+		db: tx,
+	}
+}
+
+// FindByEventId returns the seats of an event with their seat attached,
+// optionally only those of one ticket type.
+func (r *EventSeatRepository) FindByEventId(eventId uuid.UUID, ticketTypeId *uuid.UUID) ([]models.EventSeat, error) {
+	query := `
+		SELECT * FROM event_seats
+		WHERE event_id = $1
+		AND ($2::uuid IS NULL OR ticket_type_id = $2)
+		ORDER BY seat_id
+	`
+
+	eventSeats := []models.EventSeat{}
+	err := r.db.Select(&eventSeats, query, eventId, ticketTypeId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = belongsTo(r.db, "seats", eventSeats,
+		func(s *models.EventSeat) uuid.UUID { return s.SeatID },
+		func(s *models.EventSeat, seat *models.Seat) { s.Seat = seat },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return eventSeats, nil
+}
+
+func (r *EventSeatRepository) CountByTicketTypeId(ticketTypeId uuid.UUID) (int, error) {
+	query := `SELECT COUNT(*) FROM event_seats WHERE ticket_type_id = $1`
+
+	var count int
+	err := r.db.Get(&count, query, ticketTypeId)
+	return count, err
+}
+
+// Bind puts seats on sale for an event under a ticket type. Only seats of
+// the live seat maps of locationId that the event does not sell yet are
+// bound, and it returns how many were.
+func (r *EventSeatRepository) Bind(eventId, ticketTypeId, locationId uuid.UUID, seatIds []uuid.UUID) (int, error) {
+	query := `
+		INSERT INTO event_seats (event_id, seat_id, ticket_type_id, status)
+		SELECT $1, s.id, $2, 'available' FROM seats s
+		JOIN seat_maps m ON m.id = s.seat_map_id
+		WHERE s.id = ANY($3)
+		AND m.location_id = $4
+		AND m.deleted_at IS NULL
+		ON CONFLICT (event_id, seat_id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, eventId, ticketTypeId, pq.Array(seatIds), locationId)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// Unbind takes available seats of a ticket type off sale and returns how
+// many were removed. Held and sold seats are left alone.
+func (r *EventSeatRepository) Unbind(ticketTypeId uuid.UUID, seatIds []uuid.UUID) (int, error) {
+	query := `
+		DELETE FROM event_seats
+		WHERE ticket_type_id = $1
+		AND seat_id = ANY($2)
+		AND status = 'available'
+	`
+
+	result, err := r.db.Exec(query, ticketTypeId, pq.Array(seatIds))
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// Hold reserves available seats of a ticket type for a transaction detail
+// and returns how many it got. The update only matches seats that are still
+// available, so of two checkouts racing for a seat only one gets it.
+func (r *EventSeatRepository) Hold(ticketTypeId, detailId uuid.UUID, seatIds []uuid.UUID) (int, error) {
+	query := `
+		UPDATE event_seats
+		SET status = 'held', transaction_detail_id = $1, updated_at = NOW()
+		WHERE ticket_type_id = $2
+		AND seat_id = ANY($3)
+		AND status = 'available'
+	`
+
+	result, err := r.db.Exec(query, detailId, ticketTypeId, pq.Array(seatIds))
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// ReleaseHeld puts the seats held by the details of a transaction back on
+// sale.
+func (r *EventSeatRepository) ReleaseHeld(transactionId uuid.UUID) error {
+	query := `
+		UPDATE event_seats
+		SET status = 'available', transaction_detail_id = NULL, updated_at = NOW()
+		WHERE status = 'held'
+		AND transaction_detail_id IN (
+			SELECT id FROM transaction_details WHERE transaction_id = $1
+		)
+	`
+
+	_, err := r.db.Exec(query, transactionId)
+	return err
+}
+
+// MarkSold marks the seats held by a transaction detail as sold.
+func (r *EventSeatRepository) MarkSold(detailId uuid.UUID) error {
+	query := `
+		UPDATE event_seats
+		SET status = 'sold', updated_at = NOW()
+		WHERE transaction_detail_id = $1
+		AND status = 'held'
+	`
+
+	_, err := r.db.Exec(query, detailId)
+	return err
+}
+
+// FindUnissuedSeatIds returns the seats of a transaction detail that no
+// ticket has been issued for yet, in seat order.
+func (r *EventSeatRepository) FindUnissuedSeatIds(detailId uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT es.seat_id FROM event_seats es
+		WHERE es.transaction_detail_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM tickets t
+			WHERE t.transaction_detail_id = $1
+			AND t.seat_id = es.seat_id
+			AND t.deleted_at IS NULL
+		)
+		ORDER BY es.seat_id
+	`
+
+	var seatIds []uuid.UUID
+	err := r.db.Select(&seatIds, query, detailId)
+	if err != nil {
+		return nil, err
+	}
+	return seatIds, nil
+}
+
+// ReleaseVoided puts the seats of the voided tickets of a transaction
+// detail back on sale, after those tickets were refunded.
+func (r *EventSeatRepository) ReleaseVoided(detailId uuid.UUID) error {
+	query := `
+		UPDATE event_seats
+		SET status = 'available', transaction_detail_id = NULL, updated_at = NOW()
+		WHERE transaction_detail_id = $1
+		AND seat_id IN (
+			SELECT seat_id FROM tickets
+			WHERE transaction_detail_id = $1
+			AND status = 'void'
+			AND seat_id IS NOT NULL
+		)
+	`
+
+	_, err := r.db.Exec(query, detailId)
+	return err
+}
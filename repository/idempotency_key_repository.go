@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IdempotencyKeyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyKeyRepository(db *sqlx.DB) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *IdempotencyKeyRepository) WithTx(tx *sqlx.Tx) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{
+		db: tx,
+	}
+}
+
+func (r *IdempotencyKeyRepository) Find(scope, key string) (*models.IdempotencyKey, error) {
+	query := `
+		SELECT * FROM idempotency_keys 
+		WHERE scope = $1 
+		AND key = $2
+	`
+
+	var idempotencyKey models.IdempotencyKey
+	err := r.db.Get(&idempotencyKey, query, scope, key)
+	if err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+// Claim inserts an in-progress key. It returns false when the key already
+// exists and has not expired.
+func (r *IdempotencyKeyRepository) Claim(idempotencyKey *models.IdempotencyKey) (bool, error) {
+	_, err := r.db.Exec(`
+		DELETE FROM idempotency_keys 
+		WHERE scope = $1 
+		AND key = $2 
+		AND expires_at <= $3
+	`, idempotencyKey.Scope, idempotencyKey.Key, idempotencyKey.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (
+			scope, key, request_hash, created_at, expires_at
+		) VALUES (
+			:scope, :key, :request_hash, :created_at, :expires_at
+		)
+		ON CONFLICT (scope, key) DO NOTHING
+	`
+	result, err := r.db.NamedExec(query, map[string]interface{}{
+		"scope":        idempotencyKey.Scope,
+		"key":          idempotencyKey.Key,
+		"request_hash": idempotencyKey.RequestHash,
+		"created_at":   idempotencyKey.CreatedAt,
+		"expires_at":   idempotencyKey.ExpiresAt,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (r *IdempotencyKeyRepository) Complete(scope, key string, statusCode int, responseBody []byte) error {
+	query := `
+		UPDATE idempotency_keys 
+		SET status_code = $1, response_body = $2
+		WHERE scope = $3 AND key = $4
+	`
+	_, err := r.db.Exec(query, statusCode, responseBody, scope, key)
+	return err
+}
+
+func (r *IdempotencyKeyRepository) Delete(scope, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	_, err := r.db.Exec(query, scope, key)
+	return err
+}
+
+func (r *IdempotencyKeyRepository) DeleteExpired(now time.Time) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+	result, err := r.db.Exec(query, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// ListQuery asks for one page of a list. Filters and Sort name the keys a
+// list whitelists in its ListSpec, never columns.
+type ListQuery struct {
+	Limit        int
+	Cursor       string
+	Filters      map[string]string
+	Sort         []SortField
+	IncludeTotal bool
+}
+
+type SortField struct {
+	Key  string
+	Desc bool
+}
+
+// ParseSort parses a comma separated list of sort keys, each prefixed with
+// "-" for descending order, such as "-price,name".
+func ParseSort(value string) []SortField {
+	var fields []SortField
+	for _, key := range strings.Split(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		field := SortField{Key: key}
+		if strings.HasPrefix(key, "-") {
+			field = SortField{Key: key[1:], Desc: true}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+type FilterOp int
+
+const (
+	FilterEq FilterOp = iota
+	// FilterContains matches a case-insensitive substring.
+	FilterContains
+	FilterGte
+	FilterLte
+)
+
+type Filter struct {
+	Column string
+	Op     FilterOp
+	// Parse converts the raw value before it is compared, so a malformed
+	// value is rejected as a bad query instead of failing in the database.
+	Parse func(value string) (interface{}, error)
+}
+
+func parseUUIDFilter(value string) (interface{}, error) {
+	return uuid.Parse(value)
+}
+
+func parseTimeFilter(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339, value)
+}
+
+// ListSpec whitelists the filters and sort keys of a list. Sort columns
+// must be NOT NULL for keyset pagination to work.
+type ListSpec struct {
+	Filters map[string]Filter
+	Sorts   map[string]string
+}
+
+// Page is one page of a list. NextCursor is empty on the last page, and
+// Total is only counted when the query asked for it.
+type Page[T any] struct {
+	Items      []T
+	Limit      int
+	NextCursor string
+	Total      *int
+}
+
+type orderColumn struct {
+	column string
+	desc   bool
+}
+
+// cursor holds the keyset values of the last row of a page, along with the
+// order they were taken in so a cursor cannot be replayed with another sort.
+type cursor struct {
+	Order  string   `json:"o"`
+	Values []string `json:"v"`
+}
+
+var cursorMapper = reflectx.NewMapperFunc("db", strings.ToLower)
+
+// listPage runs q against table. where and args hold the conditions the
+// caller always applies, with args numbered from $1. Pages are ordered by
+// the requested sort keys, then by created_at and id, which makes the order
+// total so that the keyset of the last row marks where the next page
+// starts.
+func listPage[T any](db DBTX, table string, q *ListQuery, spec ListSpec, where string, args []interface{}) (*Page[T], error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	var err error
+	conditions := []string{}
+	if where != "" {
+		conditions = append(conditions, where)
+	}
+
+	keys := make([]string, 0, len(q.Filters))
+	for key := range q.Filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		filter, ok := spec.Filters[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot filter on %s", ErrInvalidListQuery, key)
+		}
+		var value interface{} = q.Filters[key]
+		if filter.Parse != nil {
+			value, err = filter.Parse(q.Filters[key])
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid value for %s", ErrInvalidListQuery, key)
+			}
+		}
+		switch filter.Op {
+		case FilterContains:
+			args = append(args, "%"+escapeLike(q.Filters[key])+"%")
+			conditions = append(conditions, fmt.Sprintf("%s ILIKE $%d", filter.Column, len(args)))
+		case FilterGte:
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s >= $%d", filter.Column, len(args)))
+		case FilterLte:
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s <= $%d", filter.Column, len(args)))
+		default:
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", filter.Column, len(args)))
+		}
+	}
+
+	order, err := spec.order(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := "TRUE"
+	if len(conditions) > 0 {
+		filtered = strings.Join(conditions, " AND ")
+	}
+
+	page := &Page[T]{Items: []T{}, Limit: limit}
+
+	if q.IncludeTotal {
+		var total int
+		err = db.Get(&total, "SELECT COUNT(*) FROM "+table+" WHERE "+filtered, args...)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	if q.Cursor != "" {
+		values, err := decodeCursor(q.Cursor, order)
+		if err != nil {
+			return nil, err
+		}
+
+		var keyset string
+		keyset, args = keysetCondition(order, values, args)
+		filtered += " AND " + keyset
+	}
+
+	orderBy := make([]string, len(order))
+	for i, o := range order {
+		orderBy[i] = o.column
+		if o.desc {
+			orderBy[i] += " DESC"
+		}
+	}
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY %s LIMIT $%d",
+		table, filtered, strings.Join(orderBy, ", "), len(args))
+
+	err = db.Select(&page.Items, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor, err = encodeCursor(order, &page.Items[limit-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// order resolves sort keys to columns and appends the created_at and id
+// tiebreakers.
+func (s ListSpec) order(fields []SortField) ([]orderColumn, error) {
+	var order []orderColumn
+	seen := map[string]bool{}
+	for _, field := range fields {
+		column, ok := s.Sorts[field.Key]
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot sort by %s", ErrInvalidListQuery, field.Key)
+		}
+		if !seen[column] {
+			seen[column] = true
+			order = append(order, orderColumn{column: column, desc: field.Desc})
+		}
+	}
+
+	desc := true
+	if seen["created_at"] {
+		for _, o := range order {
+			if o.column == "created_at" {
+				desc = o.desc
+			}
+		}
+	} else {
+		order = append(order, orderColumn{column: "created_at", desc: true})
+	}
+	if !seen["id"] {
+		order = append(order, orderColumn{column: "id", desc: desc})
+	}
+
+	return order, nil
+}
+
+// keysetCondition matches the rows after values in order, as in
+// (a > $1) OR (a = $1 AND b > $2) for a and b ascending.
+func keysetCondition(order []orderColumn, values []string, args []interface{}) (string, []interface{}) {
+	var alternatives []string
+	for i, o := range order {
+		var terms []string
+		for j := 0; j < i; j++ {
+			args = append(args, values[j])
+			terms = append(terms, fmt.Sprintf("%s = $%d", order[j].column, len(args)))
+		}
+
+		op := ">"
+		if o.desc {
+			op = "<"
+		}
+		args = append(args, values[i])
+		terms = append(terms, fmt.Sprintf("%s %s $%d", o.column, op, len(args)))
+
+		alternatives = append(alternatives, "("+strings.Join(terms, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+func orderSignature(order []orderColumn) string {
+	parts := make([]string, len(order))
+	for i, o := range order {
+		parts[i] = o.column
+		if o.desc {
+			parts[i] = "-" + o.column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(order []orderColumn, row interface{}) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(row))
+
+	c := cursor{Order: orderSignature(order), Values: make([]string, len(order))}
+	for i, o := range order {
+		field := cursorMapper.FieldByName(v, o.column)
+		if !field.IsValid() {
+			return "", fmt.Errorf("cannot paginate on column %s", o.column)
+		}
+
+		value, err := cursorValue(field.Interface())
+		if err != nil {
+			return "", err
+		}
+		c.Values[i] = value
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(value string, order []orderColumn) ([]string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListQuery)
+	}
+
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListQuery)
+	}
+
+	if c.Order != orderSignature(order) || len(c.Values) != len(order) {
+		return nil, fmt.Errorf("%w: cursor was issued for another sort", ErrInvalidListQuery)
+	}
+	return c.Values, nil
+}
+
+// cursorValue renders a column value in a form PostgreSQL parses back into
+// the column type.
+func cursorValue(value interface{}) (string, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		var err error
+		value, err = valuer.Value()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		return string(v), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
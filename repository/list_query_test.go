@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type listRow struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	Price     int64     `db:"price"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// pageDB returns rows for every page query and keeps the last one it was
+// asked.
+type pageDB struct {
+	DBTX
+	rows  []listRow
+	query string
+	args  []interface{}
+}
+
+func (db *pageDB) Select(dest interface{}, query string, args ...interface{}) error {
+	db.query = query
+	db.args = args
+	*dest.(*[]listRow) = append([]listRow(nil), db.rows...)
+	return nil
+}
+
+var listRowSpec = ListSpec{
+	Sorts: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"created_at": "created_at",
+	},
+}
+
+func TestListCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 3, 1, 10, 30, 0, 123456000, time.UTC)
+	rows := []listRow{
+		{ID: uuid.New(), Name: "VIP", Price: 500000, CreatedAt: createdAt},
+		{ID: uuid.New(), Name: "Regular", Price: 100000, CreatedAt: createdAt},
+		{ID: uuid.New(), Name: "Student", Price: 100000, CreatedAt: createdAt},
+	}
+	db := &pageDB{rows: rows}
+	q := &ListQuery{Limit: 2, Sort: ParseSort("-price,name")}
+
+	first, err := listPage[listRow](db, "ticket_types", q, listRowSpec, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Items) != 2 || first.NextCursor == "" {
+		t.Fatalf("first page has %d items and cursor %q, want 2 and a cursor", len(first.Items), first.NextCursor)
+	}
+	if !strings.Contains(db.query, "ORDER BY price DESC, name, created_at DESC, id DESC") {
+		t.Errorf("query %q is not ordered by price descending, then name ascending", db.query)
+	}
+
+	q.Cursor = first.NextCursor
+	_, err = listPage[listRow](db, "ticket_types", q, listRowSpec, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows after the last one of the first page, each key compared in the
+	// direction it is sorted
+	wantKeyset := "((price < $1) OR (price = $2 AND name > $3) OR " +
+		"(price = $4 AND name = $5 AND created_at < $6) OR " +
+		"(price = $7 AND name = $8 AND created_at = $9 AND id < $10))"
+	if !strings.Contains(db.query, wantKeyset) {
+		t.Errorf("query %q does not start after the cursor, want %s", db.query, wantKeyset)
+	}
+
+	last := rows[1]
+	price, name, created, id := "100000", last.Name, createdAt.Format(time.RFC3339Nano), last.ID.String()
+	wantArgs := []interface{}{
+		price,
+		price, name,
+		price, name, created,
+		price, name, created, id,
+		3,
+	}
+	if !slices.Equal(db.args, wantArgs) {
+		t.Errorf("args = %v, want %v", db.args, wantArgs)
+	}
+}
+
+func TestListCursorRejectsAnotherSort(t *testing.T) {
+	db := &pageDB{rows: make([]listRow, 3)}
+
+	first, err := listPage[listRow](db, "ticket_types", &ListQuery{Limit: 2, Sort: ParseSort("-price,name")}, listRowSpec, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sort := range []string{"price,name", "-price,-name", "-price", "name,-price", ""} {
+		q := &ListQuery{Limit: 2, Sort: ParseSort(sort), Cursor: first.NextCursor}
+		_, err := listPage[listRow](db, "ticket_types", q, listRowSpec, "", nil)
+		if !errors.Is(err, ErrInvalidListQuery) {
+			t.Errorf("cursor of -price,name sorted by %q: error = %v, want %v", sort, err, ErrInvalidListQuery)
+		}
+	}
+
+	for _, cursor := range []string{"not a cursor", "bm90IGpzb24"} {
+		q := &ListQuery{Limit: 2, Sort: ParseSort("-price,name"), Cursor: cursor}
+		_, err := listPage[listRow](db, "ticket_types", q, listRowSpec, "", nil)
+		if !errors.Is(err, ErrInvalidListQuery) {
+			t.Errorf("cursor %q: error = %v, want %v", cursor, err, ErrInvalidListQuery)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"go-ticket/models"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -16,16 +17,32 @@ func NewLocationRepository(db *sqlx.DB) *LocationRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *LocationRepository) WithTx(tx *sqlx.Tx) *LocationRepository {
+	return &LocationRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// ForTenant returns a copy of the repository that only sees the locations
+// of organizerIds.
+func (r *LocationRepository) ForTenant(organizerIds []uuid.UUID) *LocationRepository {
+	return &LocationRepository{
+		Repository: r.Repository.forTenant(organizerIds),
+	}
+}
+
 // Custom methods for LocationRepository
 func (r *LocationRepository) FindByCity(city string) ([]models.Location, error) {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{"%" + city + "%"})
 	query := `
 		SELECT * FROM locations 
 		WHERE LOWER(city) LIKE LOWER($1) 
 		AND deleted_at IS NULL
-	`
+	` + filter
 
 	var locations []models.Location
-	err := r.db.Select(&locations, query, "%"+city+"%")
+	err := r.db.Select(&locations, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -34,14 +51,15 @@ func (r *LocationRepository) FindByCity(city string) ([]models.Location, error)
 }
 
 func (r *LocationRepository) FindByCountry(country string) ([]models.Location, error) {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{country})
 	query := `
 		SELECT * FROM locations 
 		WHERE LOWER(country) = LOWER($1) 
 		AND deleted_at IS NULL
-	`
+	` + filter
 
 	var locations []models.Location
-	err := r.db.Select(&locations, query, country)
+	err := r.db.Select(&locations, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -49,51 +67,26 @@ func (r *LocationRepository) FindByCountry(country string) ([]models.Location, e
 	return locations, nil
 }
 
-func (r *LocationRepository) Create(location *models.Location) error {
-	query := `
-		INSERT INTO locations (
-			id, name, address, city, state, country, postal_code,
-			created_at, updated_at
-		) VALUES (
-			:id, :name, :address, :city, :state, :country, :postal_code,
-			:created_at, :updated_at
-		)
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          location.ID,
-		"name":        location.Name,
-		"address":     location.Address,
-		"city":        location.City,
-		"state":       location.State,
-		"country":     location.Country,
-		"postal_code": location.PostalCode,
-		"created_at":  location.CreatedAt,
-		"updated_at":  location.UpdatedAt,
-	})
-	return err
+func (r *LocationRepository) Update(location *models.Location) error {
+	return r.UpdateColumns(location.ID, location,
+		"name", "address", "city", "state", "country", "postal_code")
 }
 
-func (r *LocationRepository) Update(location *models.Location) error {
-	query := `
-		UPDATE locations SET
-			name = :name,
-			address = :address,
-			city = :city,
-			state = :state,
-			country = :country,
-			postal_code = :postal_code,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          location.ID,
-		"name":        location.Name,
-		"address":     location.Address,
-		"city":        location.City,
-		"state":       location.State,
-		"country":     location.Country,
-		"postal_code": location.PostalCode,
-		"updated_at":  location.UpdatedAt,
-	})
-	return err
+var locationListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"name":         {Column: "name", Op: FilterContains},
+		"city":         {Column: "city", Op: FilterContains},
+		"country":      {Column: "country"},
+		"organizer_id": {Column: "organizer_id", Parse: parseUUIDFilter},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"city":       "city",
+		"country":    "country",
+		"created_at": "created_at",
+	},
+}
+
+func (r *LocationRepository) List(q *ListQuery) (*Page[models.Location], error) {
+	return r.list(q, locationListSpec, "deleted_at IS NULL")
 }
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type NotificationRepository struct {
+	db DBTX
+}
+
+func NewNotificationRepository(db *sqlx.DB) *NotificationRepository {
+	return &NotificationRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *NotificationRepository) WithTx(tx *sqlx.Tx) *NotificationRepository {
+	return &NotificationRepository{
+		db: tx,
+	}
+}
+
+func (r *NotificationRepository) Create(notification *models.Notification) error {
+	query := `
+		INSERT INTO notifications (
+			id, user_id, recipient, subject, body,
+			status, created_at, updated_at
+		) VALUES (
+			:id, :user_id, :recipient, :subject, :body,
+			:status, :created_at, :updated_at
+		)
+	`
+
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":         notification.ID,
+		"user_id":    notification.UserID,
+		"recipient":  notification.Recipient,
+		"subject":    notification.Subject,
+		"body":       notification.Body,
+		"status":     notification.Status,
+		"created_at": notification.CreatedAt,
+		"updated_at": notification.UpdatedAt,
+	})
+	return err
+}
+
+// FindPendingForUpdate locks up to limit pending notifications, oldest
+// first. Rows locked by another dispatcher are skipped.
+func (r *NotificationRepository) FindPendingForUpdate(limit int) ([]models.Notification, error) {
+	query := `
+		SELECT * FROM notifications
+		WHERE status = 'pending'
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+
+	var notifications []models.Notification
+	err := r.db.Select(&notifications, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *NotificationRepository) MarkSent(id uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE notifications
+		SET status = 'sent', attempts = attempts + 1, sent_at = $1, updated_at = NOW()
+		WHERE id = $2
+	`
+
+	_, err := r.db.Exec(query, at, id)
+	return err
+}
+
+// MarkAttemptFailed records a failed delivery, leaving the notification
+// pending for another attempt or moving it to status when out of attempts.
+func (r *NotificationRepository) MarkAttemptFailed(id uuid.UUID, status models.NotificationStatus, lastError string) error {
+	query := `
+		UPDATE notifications
+		SET status = $1, attempts = attempts + 1, last_error = $2, updated_at = NOW()
+		WHERE id = $3
+	`
+
+	_, err := r.db.Exec(query, status, lastError, id)
+	return err
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type OrganizerRepository struct {
+	*Repository[models.Organizer]
+}
+
+func NewOrganizerRepository(db *sqlx.DB) *OrganizerRepository {
+	return &OrganizerRepository{
+		Repository: NewRepository[models.Organizer](db, "organizers"),
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *OrganizerRepository) WithTx(tx *sqlx.Tx) *OrganizerRepository {
+	return &OrganizerRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// FindByUserId returns the organizers a user is a member of.
+func (r *OrganizerRepository) FindByUserId(userId uuid.UUID) ([]models.Organizer, error) {
+	query := `
+		SELECT o.* FROM organizers o
+		JOIN organizer_members m ON m.organizer_id = o.id
+		WHERE m.user_id = $1
+		AND o.deleted_at IS NULL
+		ORDER BY o.name
+	`
+
+	organizers := []models.Organizer{}
+	err := r.db.Select(&organizers, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return organizers, nil
+}
+
+// FindIdsByUserId returns the ids of the organizers a user is a member of,
+// which is the tenant the user works in.
+func (r *OrganizerRepository) FindIdsByUserId(userId uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT o.id FROM organizers o
+		JOIN organizer_members m ON m.organizer_id = o.id
+		WHERE m.user_id = $1
+		AND o.deleted_at IS NULL
+		ORDER BY o.id
+	`
+
+	organizerIds := []uuid.UUID{}
+	err := r.db.Select(&organizerIds, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return organizerIds, nil
+}
+
+// FindByIdForUpdate loads an organizer and locks its row until the
+// surrounding transaction ends, so membership changes apply one at a time.
+func (r *OrganizerRepository) FindByIdForUpdate(id uuid.UUID) (*models.Organizer, error) {
+	query := `
+		SELECT * FROM organizers
+		WHERE id = $1
+		AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var organizer models.Organizer
+	err := r.db.Get(&organizer, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &organizer, nil
+}
+
+func (r *OrganizerRepository) Update(organizer *models.Organizer) error {
+	return r.UpdateColumns(organizer.ID, organizer, "name")
+}
+
+func (r *OrganizerRepository) FindMembers(organizerId uuid.UUID) ([]models.OrganizerMember, error) {
+	query := `
+		SELECT * FROM organizer_members
+		WHERE organizer_id = $1
+		ORDER BY created_at
+	`
+
+	members := []models.OrganizerMember{}
+	err := r.db.Select(&members, query, organizerId)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *OrganizerRepository) FindMember(organizerId, userId uuid.UUID) (*models.OrganizerMember, error) {
+	query := `
+		SELECT * FROM organizer_members
+		WHERE organizer_id = $1
+		AND user_id = $2
+	`
+
+	var member models.OrganizerMember
+	err := r.db.Get(&member, query, organizerId, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// SaveMember adds a user to an organizer, or changes their role if they
+// already are a member.
+func (r *OrganizerRepository) SaveMember(member *models.OrganizerMember) error {
+	query := `
+		INSERT INTO organizer_members (
+			organizer_id, user_id, role, created_at
+		) VALUES (
+			:organizer_id, :user_id, :role, :created_at
+		)
+		ON CONFLICT (organizer_id, user_id) DO UPDATE SET role = EXCLUDED.role
+	`
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"organizer_id": member.OrganizerID,
+		"user_id":      member.UserID,
+		"role":         member.Role,
+		"created_at":   member.CreatedAt,
+	})
+	return err
+}
+
+func (r *OrganizerRepository) RemoveMember(organizerId, userId uuid.UUID) error {
+	query := `
+		DELETE FROM organizer_members
+		WHERE organizer_id = $1
+		AND user_id = $2
+	`
+	_, err := r.db.Exec(query, organizerId, userId)
+	return err
+}
+
+func (r *OrganizerRepository) CountOwners(organizerId uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM organizer_members
+		WHERE organizer_id = $1
+		AND role = $2
+	`
+
+	var count int
+	err := r.db.Get(&count, query, organizerId, models.OrganizerRoleOwner)
+	return count, err
+}
+
+var organizerListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"name": {Column: "name", Op: FilterContains},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"created_at": "created_at",
+	},
+}
+
+func (r *OrganizerRepository) List(q *ListQuery) (*Page[models.Organizer], error) {
+	return r.list(q, organizerListSpec, "deleted_at IS NULL")
+}
+
+// ListByUserId returns a page of the organizers a user is a member of.
+func (r *OrganizerRepository) ListByUserId(userId uuid.UUID, q *ListQuery) (*Page[models.Organizer], error) {
+	where := "id IN (SELECT organizer_id FROM organizer_members WHERE user_id = $1) AND deleted_at IS NULL"
+	return r.list(q, organizerListSpec, where, userId)
+}
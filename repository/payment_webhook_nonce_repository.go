@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type PaymentWebhookNonceRepository struct {
+	db DBTX
+}
+
+func NewPaymentWebhookNonceRepository(db *sqlx.DB) *PaymentWebhookNonceRepository {
+	return &PaymentWebhookNonceRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *PaymentWebhookNonceRepository) WithTx(tx *sqlx.Tx) *PaymentWebhookNonceRepository {
+	return &PaymentWebhookNonceRepository{
+		db: tx,
+	}
+}
+
+// Claim records nonce for provider. It returns false when the nonce has
+// already been claimed.
+func (r *PaymentWebhookNonceRepository) Claim(provider, nonce string, receivedAt time.Time) (bool, error) {
+	query := `
+		INSERT INTO payment_webhook_nonces (provider, nonce, received_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, nonce) DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, provider, nonce, receivedAt)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// DeleteReceivedBefore removes nonces old enough that a webhook reusing them
+// would already be rejected by its timestamp.
+func (r *PaymentWebhookNonceRepository) DeleteReceivedBefore(before time.Time) error {
+	query := `DELETE FROM payment_webhook_nonces WHERE received_at < $1`
+	_, err := r.db.Exec(query, before)
+	return err
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type PricePhaseRepository struct {
+	db DBTX
+}
+
+func NewPricePhaseRepository(db *sqlx.DB) *PricePhaseRepository {
+	return &PricePhaseRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *PricePhaseRepository) WithTx(tx *sqlx.Tx) *PricePhaseRepository {
+	return &PricePhaseRepository{
+		db: tx,
+	}
+}
+
+func (r *PricePhaseRepository) FindByTicketTypeId(ticketTypeId uuid.UUID) ([]models.PricePhase, error) {
+	phases, err := r.FindByTicketTypeIds([]uuid.UUID{ticketTypeId})
+	if err != nil {
+		return nil, err
+	}
+
+	return phases[ticketTypeId], nil
+}
+
+// FindByTicketTypeIds returns the phases of each ticket type in order. Ticket
+// types without phases are left out of the map.
+func (r *PricePhaseRepository) FindByTicketTypeIds(ticketTypeIds []uuid.UUID) (map[uuid.UUID][]models.PricePhase, error) {
+	query := `
+		SELECT * FROM price_phases
+		WHERE ticket_type_id = ANY($1)
+		ORDER BY ticket_type_id, position
+	`
+
+	var phases []models.PricePhase
+	err := r.db.Select(&phases, query, pq.Array(ticketTypeIds))
+	if err != nil {
+		return nil, err
+	}
+
+	byTicketType := make(map[uuid.UUID][]models.PricePhase)
+	for _, phase := range phases {
+		byTicketType[phase.TicketTypeID] = append(byTicketType[phase.TicketTypeID], phase)
+	}
+
+	return byTicketType, nil
+}
+
+// Replace swaps the phases of a ticket type for phases.
+func (r *PricePhaseRepository) Replace(ticketTypeId uuid.UUID, phases []models.PricePhase) error {
+	_, err := r.db.Exec(`DELETE FROM price_phases WHERE ticket_type_id = $1`, ticketTypeId)
+	if err != nil {
+		return err
+	}
+
+	if len(phases) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO price_phases (
+			id, ticket_type_id, position, name, price,
+			starts_at, ends_at, sold_limit, created_at
+		) VALUES (
+			:id, :ticket_type_id, :position, :name, :price,
+			:starts_at, :ends_at, :sold_limit, :created_at
+		)
+	`
+
+	_, err = r.db.NamedExec(query, phases)
+	return err
+}
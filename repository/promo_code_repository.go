@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type PromoCodeRepository struct {
+	*Repository[models.PromoCode]
+}
+
+func NewPromoCodeRepository(db *sqlx.DB) *PromoCodeRepository {
+	return &PromoCodeRepository{
+		Repository: NewRepository[models.PromoCode](db, "promo_codes"),
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *PromoCodeRepository) WithTx(tx *sqlx.Tx) *PromoCodeRepository {
+	return &PromoCodeRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// ForTenant returns a copy of the repository that only sees the promo codes
+// of organizerIds.
+func (r *PromoCodeRepository) ForTenant(organizerIds []uuid.UUID) *PromoCodeRepository {
+	return &PromoCodeRepository{
+		Repository: r.Repository.forTenant(organizerIds),
+	}
+}
+
+// Custom methods for PromoCodeRepository
+func (r *PromoCodeRepository) FindAllWithTargets() ([]models.PromoCode, error) {
+	filter, args := r.tenantFilter("organizer_id", nil)
+	query := `
+		SELECT * FROM promo_codes
+		WHERE deleted_at IS NULL
+	` + filter + `
+		ORDER BY created_at DESC
+	`
+
+	promoCodes := []models.PromoCode{}
+	err := r.db.Select(&promoCodes, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.loadTargets(promoCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return promoCodes, nil
+}
+
+func (r *PromoCodeRepository) FindWithTargets(id uuid.UUID) (*models.PromoCode, error) {
+	promoCode, err := r.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	promoCodes := []models.PromoCode{*promoCode}
+	err = r.loadTargets(promoCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &promoCodes[0], nil
+}
+
+// FindByCode looks up a code of an organizer regardless of case.
+func (r *PromoCodeRepository) FindByCode(organizerId uuid.UUID, code string) (*models.PromoCode, error) {
+	query := `
+		SELECT * FROM promo_codes
+		WHERE organizer_id = $1
+		AND UPPER(code) = $2
+		AND deleted_at IS NULL
+	`
+
+	var promoCode models.PromoCode
+	err := r.db.Get(&promoCode, query, organizerId, strings.ToUpper(code))
+	if err != nil {
+		return nil, err
+	}
+
+	promoCodes := []models.PromoCode{promoCode}
+	err = r.loadTargets(promoCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &promoCodes[0], nil
+}
+
+// loadTargets sets the events and ticket types each promo code is
+// restricted to.
+func (r *PromoCodeRepository) loadTargets(promoCodes []models.PromoCode) error {
+	ids := make([]uuid.UUID, len(promoCodes))
+	byId := make(map[uuid.UUID]*models.PromoCode, len(promoCodes))
+	for i := range promoCodes {
+		promoCodes[i].EventIDs = []uuid.UUID{}
+		promoCodes[i].TicketTypeIDs = []uuid.UUID{}
+		ids[i] = promoCodes[i].ID
+		byId[promoCodes[i].ID] = &promoCodes[i]
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var targets []struct {
+		PromoCodeID  uuid.UUID  `db:"promo_code_id"`
+		EventID      *uuid.UUID `db:"event_id"`
+		TicketTypeID *uuid.UUID `db:"ticket_type_id"`
+	}
+	err := r.db.Select(&targets, `
+		SELECT promo_code_id, event_id, NULL::uuid AS ticket_type_id
+		FROM promo_code_events WHERE promo_code_id = ANY($1)
+		UNION ALL
+		SELECT promo_code_id, NULL::uuid, ticket_type_id
+		FROM promo_code_ticket_types WHERE promo_code_id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		promoCode := byId[target.PromoCodeID]
+		if target.EventID != nil {
+			promoCode.EventIDs = append(promoCode.EventIDs, *target.EventID)
+		}
+		if target.TicketTypeID != nil {
+			promoCode.TicketTypeIDs = append(promoCode.TicketTypeIDs, *target.TicketTypeID)
+		}
+	}
+
+	return nil
+}
+
+// AddTargets restricts a promo code to eventIds and ticketTypeIds and
+// returns how many of them were found among the events and ticket types of
+// organizerId.
+func (r *PromoCodeRepository) AddTargets(id, organizerId uuid.UUID, eventIds, ticketTypeIds []uuid.UUID) (int, error) {
+	events, err := r.db.Exec(`
+		INSERT INTO promo_code_events (promo_code_id, event_id)
+		SELECT $1, id FROM events
+		WHERE id = ANY($2)
+		AND organizer_id = $3
+		AND deleted_at IS NULL
+	`, id, pq.Array(eventIds), organizerId)
+	if err != nil {
+		return 0, err
+	}
+
+	ticketTypes, err := r.db.Exec(`
+		INSERT INTO promo_code_ticket_types (promo_code_id, ticket_type_id)
+		SELECT $1, id FROM ticket_types
+		WHERE id = ANY($2)
+		AND organizer_id = $3
+		AND deleted_at IS NULL
+	`, id, pq.Array(ticketTypeIds), organizerId)
+	if err != nil {
+		return 0, err
+	}
+
+	eventsAdded, err := events.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	ticketTypesAdded, err := ticketTypes.RowsAffected()
+	return int(eventsAdded + ticketTypesAdded), err
+}
+
+// Redeem counts one more redemption of a promo code unless that would
+// exceed its cap, and reports whether it did. The update locks the row, so
+// concurrent redemptions of a code are counted one after the other.
+func (r *PromoCodeRepository) Redeem(id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE promo_codes
+		SET redemption_count = redemption_count + 1, updated_at = NOW()
+		WHERE id = $1
+		AND (max_redemptions IS NULL OR redemption_count < max_redemptions)
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *PromoCodeRepository) CountRedemptionsByUser(id, userId uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM promo_code_redemptions
+		WHERE promo_code_id = $1
+		AND user_id = $2
+	`
+
+	var count int
+	err := r.db.Get(&count, query, id, userId)
+	return count, err
+}
+
+func (r *PromoCodeRepository) CreateRedemption(redemption *models.PromoCodeRedemption) error {
+	query := `
+		INSERT INTO promo_code_redemptions (
+			id, promo_code_id, user_id, transaction_id, created_at
+		) VALUES (
+			:id, :promo_code_id, :user_id, :transaction_id, :created_at
+		)
+	`
+
+	_, err := r.db.NamedExec(query, redemption)
+	return err
+}
+
+// ReleaseRedemption gives back the redemption of a transaction that was
+// cancelled before it was paid, so it no longer counts against the caps.
+func (r *PromoCodeRepository) ReleaseRedemption(transactionId uuid.UUID) error {
+	query := `
+		WITH released AS (
+			DELETE FROM promo_code_redemptions
+			WHERE transaction_id = $1
+			RETURNING promo_code_id
+		)
+		UPDATE promo_codes
+		SET redemption_count = redemption_count - 1, updated_at = NOW()
+		WHERE id IN (SELECT promo_code_id FROM released)
+	`
+
+	_, err := r.db.Exec(query, transactionId)
+	return err
+}
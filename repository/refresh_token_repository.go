@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RefreshTokenRepository struct {
+	db DBTX
+}
+
+func NewRefreshTokenRepository(db *sqlx.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *RefreshTokenRepository) WithTx(tx *sqlx.Tx) *RefreshTokenRepository {
+	return &RefreshTokenRepository{
+		db: tx,
+	}
+}
+
+// FindByHashForUpdate loads a refresh token and locks its row, so two
+// concurrent refreshes with the same token cannot both rotate it.
+func (r *RefreshTokenRepository) FindByHashForUpdate(tokenHash string) (*models.RefreshToken, error) {
+	query := `
+		SELECT * FROM refresh_tokens 
+		WHERE token_hash = $1 
+		FOR UPDATE
+	`
+
+	var token models.RefreshToken
+	err := r.db.Get(&token, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (
+			id, user_id, family_id, token_hash, expires_at, created_at
+		) VALUES (
+			:id, :user_id, :family_id, :token_hash, :expires_at, :created_at
+		)
+	`
+
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":         token.ID,
+		"user_id":    token.UserID,
+		"family_id":  token.FamilyID,
+		"token_hash": token.TokenHash,
+		"expires_at": token.ExpiresAt,
+		"created_at": token.CreatedAt,
+	})
+	return err
+}
+
+// Revoke marks a token as used up, pointing at the token that replaced it
+// when it was rotated.
+func (r *RefreshTokenRepository) Revoke(id uuid.UUID, replacedBy *uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE refresh_tokens 
+		SET revoked_at = $3, replaced_by = $2
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(query, id, replacedBy, at)
+	return err
+}
+
+// RevokeFamily revokes every token still active in a rotation chain.
+func (r *RefreshTokenRepository) RevokeFamily(familyId uuid.UUID, at time.Time) error {
+	query := `
+		UPDATE refresh_tokens 
+		SET revoked_at = $2
+		WHERE family_id = $1 
+		AND revoked_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, familyId, at)
+	return err
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RefundRepository struct {
+	*Repository[models.Refund]
+}
+
+func NewRefundRepository(db *sqlx.DB) *RefundRepository {
+	return &RefundRepository{
+		Repository: NewRepository[models.Refund](db, "refunds"),
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *RefundRepository) WithTx(tx *sqlx.Tx) *RefundRepository {
+	return &RefundRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// Custom methods for RefundRepository
+func (r *RefundRepository) FindByTransactionId(transactionId uuid.UUID) ([]models.Refund, error) {
+	query := `
+		SELECT * FROM refunds 
+		WHERE transaction_id = $1 
+		AND deleted_at IS NULL
+		ORDER BY created_at
+	`
+
+	var refunds []models.Refund
+	err := r.db.Select(&refunds, query, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range refunds {
+		var items []models.RefundItem
+		err := r.db.Select(&items, `
+			SELECT * FROM refund_items 
+			WHERE refund_id = $1 
+			AND deleted_at IS NULL
+		`, refunds[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		refunds[i].Items = items
+	}
+
+	return refunds, nil
+}
+
+// Create inserts the refund together with its items.
+func (r *RefundRepository) Create(refund *models.Refund) error {
+	query := `
+		INSERT INTO refunds (
+			id, transaction_id, amount, reason, actor, status, provider_reference,
+			failure_reason, created_at, updated_at
+		) VALUES (
+			:id, :transaction_id, :amount, :reason, :actor, :status, :provider_reference,
+			:failure_reason, :created_at, :updated_at
+		)
+	`
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":                 refund.ID,
+		"transaction_id":     refund.TransactionID,
+		"amount":             refund.Amount,
+		"reason":             refund.Reason,
+		"actor":              refund.Actor,
+		"status":             refund.Status,
+		"provider_reference": refund.ProviderReference,
+		"failure_reason":     refund.FailureReason,
+		"created_at":         refund.CreatedAt,
+		"updated_at":         refund.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	itemQuery := `
+		INSERT INTO refund_items (
+			id, refund_id, transaction_detail_id, quantity, amount,
+			created_at, updated_at
+		) VALUES (
+			:id, :refund_id, :transaction_detail_id, :quantity, :amount,
+			:created_at, :updated_at
+		)
+	`
+	if len(refund.Items) == 0 {
+		return nil
+	}
+
+	_, err = r.db.NamedExec(itemQuery, refund.Items)
+	return err
+}
+
+// HasPending reports whether a refund of the transaction is still waiting
+// for the provider.
+func (r *RefundRepository) HasPending(transactionId uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM refunds
+			WHERE transaction_id = $1
+			AND status = 'pending'
+			AND deleted_at IS NULL
+		)
+	`
+
+	var pending bool
+	err := r.db.Get(&pending, query, transactionId)
+	return pending, err
+}
+
+// Settle records the provider's answer to a pending refund.
+func (r *RefundRepository) Settle(refund *models.Refund) error {
+	return r.UpdateColumns(refund.ID, refund, "status", "provider_reference", "failure_reason")
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"reflect"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// belongsTo loads the rows of table that items point to with a single
+// query, and hands each item its row through set. key returns the id an
+// item points to. Items pointing to no row are left as they are.
+//
+// Rows are loaded by name with SELECT *, so a column added to the table
+// without a matching field fails loudly instead of shifting the others.
+func belongsTo[T any, R any](db DBTX, table string, items []T, key func(*T) uuid.UUID, set func(*T, *R)) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(items))
+	seen := make(map[uuid.UUID]bool, len(items))
+	for i := range items {
+		id := key(&items[i])
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var related []R
+	err := db.Select(&related, "SELECT * FROM "+table+" WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	idField := columnsOf[R]().fields["id"]
+	byId := make(map[uuid.UUID]*R, len(related))
+	for i := range related {
+		id := reflect.ValueOf(&related[i]).Elem().FieldByIndex(idField).Interface().(uuid.UUID)
+		byId[id] = &related[i]
+	}
+
+	for i := range items {
+		if row, ok := byId[key(&items[i])]; ok {
+			set(&items[i], row)
+		}
+	}
+
+	return nil
+}
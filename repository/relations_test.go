@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// locationDB answers the single query belongsTo makes for locations.
+type locationDB struct {
+	DBTX
+	rows    []models.Location
+	queries int
+}
+
+func (db *locationDB) Select(dest interface{}, query string, args ...interface{}) error {
+	db.queries++
+	ids := args[0].(pq.GenericArray).A.([]uuid.UUID)
+
+	var found []models.Location
+	for _, row := range db.rows {
+		if slices.Contains(ids, row.ID) {
+			found = append(found, row)
+		}
+	}
+	*dest.(*[]models.Location) = found
+	return nil
+}
+
+func attachLocations(db DBTX, events []models.Event) error {
+	return belongsTo(db, "locations", events,
+		func(e *models.Event) uuid.UUID { return e.LocationID },
+		func(e *models.Event, l *models.Location) { e.Location = l },
+	)
+}
+
+func TestBelongsTo(t *testing.T) {
+	hall := models.Location{BaseModel: models.BaseModel{ID: uuid.New()}, Name: "Hall"}
+	park := models.Location{BaseModel: models.BaseModel{ID: uuid.New()}, Name: "Park"}
+	db := &locationDB{rows: []models.Location{hall, park}}
+
+	events := []models.Event{
+		{LocationID: hall.ID},
+		{LocationID: park.ID},
+		{LocationID: hall.ID},
+	}
+	err := attachLocations(db, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.queries != 1 {
+		t.Errorf("made %d queries, want 1", db.queries)
+	}
+	for i, want := range []string{"Hall", "Park", "Hall"} {
+		if events[i].Location == nil || events[i].Location.Name != want {
+			t.Errorf("event %d has location %+v, want %s", i, events[i].Location, want)
+		}
+	}
+}
+
+func TestBelongsToNoItems(t *testing.T) {
+	db := &locationDB{}
+
+	err := attachLocations(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.queries != 0 {
+		t.Errorf("made %d queries for no items, want 0", db.queries)
+	}
+}
+
+func TestBelongsToMissingRow(t *testing.T) {
+	hall := models.Location{BaseModel: models.BaseModel{ID: uuid.New()}, Name: "Hall"}
+	db := &locationDB{rows: []models.Location{hall}}
+
+	events := []models.Event{
+		{LocationID: uuid.New()},
+		{LocationID: hall.ID},
+	}
+	err := attachLocations(db, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if events[0].Location != nil {
+		t.Errorf("event pointing to a missing location got %+v, want it left nil", events[0].Location)
+	}
+	if events[1].Location == nil || events[1].Location.Name != "Hall" {
+		t.Errorf("event 1 has location %+v, want Hall", events[1].Location)
+	}
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RoleRepository struct {
+	db DBTX
+}
+
+func NewRoleRepository(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *RoleRepository) WithTx(tx *sqlx.Tx) *RoleRepository {
+	return &RoleRepository{
+		db: tx,
+	}
+}
+
+// FindAll returns every role with the names of its permissions.
+func (r *RoleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Select(&roles, `SELECT * FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT p.name FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		WHERE rp.role_id = $1
+		ORDER BY p.name
+	`
+	for i := range roles {
+		roles[i].Permissions = []string{}
+		err = r.db.Select(&roles[i].Permissions, query, roles[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return roles, nil
+}
+
+func (r *RoleRepository) FindNamesByUserId(userId uuid.UUID) ([]string, error) {
+	query := `
+		SELECT r.name FROM roles r
+		JOIN user_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY r.name
+	`
+
+	roles := []string{}
+	err := r.db.Select(&roles, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// FindPermissionsByUserId returns the names of every permission granted to
+// a user through any of their roles.
+func (r *RoleRepository) FindPermissionsByUserId(userId uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT p.name FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN user_roles ur ON ur.role_id = rp.role_id
+		WHERE ur.user_id = $1
+		ORDER BY p.name
+	`
+
+	permissions := []string{}
+	err := r.db.Select(&permissions, query, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// AddUserRole grants a role to a user. It returns false when no role has
+// that name.
+func (r *RoleRepository) AddUserRole(userId uuid.UUID, role string) (bool, error) {
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, userId, role)
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = r.db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)`, role)
+	return exists, err
+}
+
+// ReplaceUserRoles sets the roles of a user to exactly roles and returns
+// how many of them exist.
+func (r *RoleRepository) ReplaceUserRoles(userId uuid.UUID, roles []string) (int, error) {
+	_, err := r.db.Exec(`DELETE FROM user_roles WHERE user_id = $1`, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = ANY($2)
+	`
+
+	result, err := r.db.Exec(query, userId, pq.Array(roles))
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
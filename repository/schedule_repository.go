@@ -16,6 +16,13 @@ func NewScheduleRepository(db *sqlx.DB) *ScheduleRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *ScheduleRepository) WithTx(tx *sqlx.Tx) *ScheduleRepository {
+	return &ScheduleRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
 // Custom methods for ScheduleRepository
 func (r *ScheduleRepository) FindByDateRange(startDate, endDate string) ([]models.Schedule, error) {
 	query := `
@@ -34,45 +41,25 @@ func (r *ScheduleRepository) FindByDateRange(startDate, endDate string) ([]model
 	return schedules, nil
 }
 
-func (r *ScheduleRepository) Create(schedule *models.Schedule) error {
-	query := `
-		INSERT INTO schedules (
-			id, title, description, start_date, end_date,
-			created_at, updated_at
-		) VALUES (
-			:id, :title, :description, :start_date, :end_date,
-			:created_at, :updated_at
-		)
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          schedule.ID,
-		"title":       schedule.Title,
-		"description": schedule.Description,
-		"start_date":  schedule.StartDate,
-		"end_date":    schedule.EndDate,
-		"created_at":  schedule.CreatedAt,
-		"updated_at":  schedule.UpdatedAt,
-	})
-	return err
+func (r *ScheduleRepository) Update(schedule *models.Schedule) error {
+	return r.UpdateColumns(schedule.ID, schedule,
+		"title", "description", "start_date", "end_date")
 }
 
-func (r *ScheduleRepository) Update(schedule *models.Schedule) error {
-	query := `
-		UPDATE schedules SET
-			title = :title,
-			description = :description,
-			start_date = :start_date,
-			end_date = :end_date,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":          schedule.ID,
-		"title":       schedule.Title,
-		"description": schedule.Description,
-		"start_date":  schedule.StartDate,
-		"end_date":    schedule.EndDate,
-		"updated_at":  schedule.UpdatedAt,
-	})
-	return err
+var scheduleListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"title":       {Column: "title", Op: FilterContains},
+		"starts_from": {Column: "start_date", Op: FilterGte, Parse: parseTimeFilter},
+		"starts_to":   {Column: "start_date", Op: FilterLte, Parse: parseTimeFilter},
+	},
+	Sorts: map[string]string{
+		"title":      "title",
+		"start_date": "start_date",
+		"end_date":   "end_date",
+		"created_at": "created_at",
+	},
+}
+
+func (r *ScheduleRepository) List(q *ListQuery) (*Page[models.Schedule], error) {
+	return r.list(q, scheduleListSpec, "deleted_at IS NULL")
 }
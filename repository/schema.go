@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"fmt"
+	"go-ticket/models"
+	"strings"
+)
+
+// entityTables pairs every table loaded with SELECT * with the model its
+// rows are scanned into.
+var entityTables = []struct {
+	table   string
+	columns func() *entityColumns
+}{
+	{"users", columnsOf[models.User]},
+	{"refresh_tokens", columnsOf[models.RefreshToken]},
+	{"roles", columnsOf[models.Role]},
+	{"organizers", columnsOf[models.Organizer]},
+	{"organizer_members", columnsOf[models.OrganizerMember]},
+	{"locations", columnsOf[models.Location]},
+	{"schedules", columnsOf[models.Schedule]},
+	{"events", columnsOf[models.Event]},
+	{"ticket_types", columnsOf[models.TicketType]},
+	{"transactions", columnsOf[models.Transaction]},
+	{"transaction_details", columnsOf[models.TransactionDetail]},
+	{"transaction_status_history", columnsOf[models.TransactionStatusHistory]},
+	{"idempotency_keys", columnsOf[models.IdempotencyKey]},
+	{"refunds", columnsOf[models.Refund]},
+	{"refund_items", columnsOf[models.RefundItem]},
+	{"tickets", columnsOf[models.Ticket]},
+	{"ticket_scans", columnsOf[models.TicketScan]},
+	{"event_jobs", columnsOf[models.EventJob]},
+	{"notifications", columnsOf[models.Notification]},
+	{"seat_maps", columnsOf[models.SeatMap]},
+	{"seat_sections", columnsOf[models.SeatSection]},
+	{"seats", columnsOf[models.Seat]},
+	{"event_seats", columnsOf[models.EventSeat]},
+	{"promo_codes", columnsOf[models.PromoCode]},
+	{"promo_code_redemptions", columnsOf[models.PromoCodeRedemption]},
+	{"price_phases", columnsOf[models.PricePhase]},
+}
+
+// CheckSchema compares the columns of every table with the db tags of its
+// model. A column without a field makes SELECT * fail to scan, and a field
+// without a column makes inserts fail, so drift is reported at startup
+// rather than on the first request that trips over it.
+func CheckSchema(db DBTX) error {
+	var problems []string
+	for _, entity := range entityTables {
+		var tableColumns []string
+		err := db.Select(&tableColumns, `
+			SELECT column_name FROM information_schema.columns
+			WHERE table_schema = current_schema()
+			AND table_name = $1
+		`, entity.table)
+		if err != nil {
+			return err
+		}
+		if len(tableColumns) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s does not exist", entity.table))
+			continue
+		}
+
+		problems = append(problems, schemaDrift(entity.table, tableColumns, entity.columns())...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("schema drift: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func schemaDrift(table string, tableColumns []string, columns *entityColumns) []string {
+	var problems []string
+
+	inTable := make(map[string]bool, len(tableColumns))
+	for _, column := range tableColumns {
+		inTable[column] = true
+		if !columns.has(column) {
+			problems = append(problems, fmt.Sprintf("column %s.%s has no model field", table, column))
+		}
+	}
+	for _, column := range columns.all {
+		if !inTable[column] {
+			problems = append(problems, fmt.Sprintf("field for %s.%s has no column", table, column))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testBase struct {
+	ID        uuid.UUID  `db:"id"`
+	CreatedAt time.Time  `db:"created_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+type testEntity struct {
+	testBase
+	Name     string `db:"name"`
+	Quota    int    `db:"quota,omitempty"`
+	Location *struct {
+		Name string `db:"name"`
+	} `db:"-"`
+	Untagged string
+	internal string
+}
+
+func TestColumnsOf(t *testing.T) {
+	columns := columnsOf[testEntity]()
+
+	wantAll := []string{"id", "created_at", "deleted_at", "name", "quota", "untagged"}
+	if !reflect.DeepEqual(columns.all, wantAll) {
+		t.Errorf("all = %v, want %v", columns.all, wantAll)
+	}
+	wantInsert := []string{"id", "created_at", "name", "quota", "untagged"}
+	if !reflect.DeepEqual(columns.insert, wantInsert) {
+		t.Errorf("insert = %v, want %v", columns.insert, wantInsert)
+	}
+	wantUpdate := []string{"name", "quota", "untagged"}
+	if !reflect.DeepEqual(columns.update, wantUpdate) {
+		t.Errorf("update = %v, want %v", columns.update, wantUpdate)
+	}
+
+	if got := columns.fields["created_at"]; !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("created_at is at field %v, want the embedded field [0 1]", got)
+	}
+	if columns.has("internal") || columns.has("location") {
+		t.Errorf("unexported and db:\"-\" fields must not be columns, got %v", columns.all)
+	}
+	if columnsOf[testEntity]() != columns {
+		t.Error("columnsOf did not return the cached columns")
+	}
+}
+
+func TestSchemaDrift(t *testing.T) {
+	columns := columnsOf[testEntity]()
+
+	tests := []struct {
+		name         string
+		tableColumns []string
+		want         []string
+	}{
+		{
+			name:         "in sync",
+			tableColumns: []string{"id", "created_at", "deleted_at", "name", "quota", "untagged"},
+		},
+		{
+			name:         "tagged field missing from the table",
+			tableColumns: []string{"id", "created_at", "deleted_at", "name", "untagged"},
+			want:         []string{"field for things.quota has no column"},
+		},
+		{
+			name:         "column missing from the struct",
+			tableColumns: []string{"id", "created_at", "deleted_at", "name", "quota", "untagged", "price"},
+			want:         []string{"column things.price has no model field"},
+		},
+		{
+			name:         "both",
+			tableColumns: []string{"id", "created_at", "deleted_at", "name", "price", "untagged"},
+			want: []string{
+				"column things.price has no model field",
+				"field for things.quota has no column",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schemaDrift("things", tt.tableColumns, columns)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schemaDrift() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSchemaFileMatchesModels runs the check CheckSchema makes at startup
+// against database/schema.sql, so a model and the schema cannot drift
+// apart unnoticed until a server starts on a fresh database.
+func TestSchemaFileMatchesModels(t *testing.T) {
+	tables := schemaFileColumns(t, "../database/schema.sql")
+
+	for _, entity := range entityTables {
+		tableColumns, ok := tables[entity.table]
+		if !ok {
+			t.Errorf("table %s is not in schema.sql", entity.table)
+			continue
+		}
+		for _, problem := range schemaDrift(entity.table, tableColumns, entity.columns()) {
+			t.Error(problem)
+		}
+	}
+}
+
+// schemaFileColumns reads the columns of every CREATE TABLE statement of a
+// schema file, keyed by table.
+func schemaFileColumns(t *testing.T, path string) map[string][]string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tables := make(map[string][]string)
+	var table string
+	// depth counts the parentheses open in the current statement, as
+	// constraints may span several lines
+	depth := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "CREATE TABLE ") {
+			table = strings.Fields(line)[2]
+			tables[table] = nil
+			depth = 1
+			continue
+		}
+		if table == "" {
+			continue
+		}
+
+		isColumn := depth == 1 && line != "" && !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, ")") &&
+			!strings.HasPrefix(line, "CONSTRAINT ") && !strings.HasPrefix(line, "PRIMARY KEY") && !strings.HasPrefix(line, "UNIQUE")
+		if isColumn {
+			tables[table] = append(tables[table], strings.Fields(line)[0])
+		}
+
+		depth += strings.Count(line, "(") - strings.Count(line, ")")
+		if depth == 0 {
+			table = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tables
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// seatInsertBatchSize keeps a multi-row insert of seats under the 65535
+// parameters PostgreSQL accepts in a single statement.
+const seatInsertBatchSize = 1000
+
+type SeatMapRepository struct {
+	*Repository[models.SeatMap]
+}
+
+func NewSeatMapRepository(db *sqlx.DB) *SeatMapRepository {
+	return &SeatMapRepository{
+		Repository: NewRepository[models.SeatMap](db, "seat_maps"),
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *SeatMapRepository) WithTx(tx *sqlx.Tx) *SeatMapRepository {
+	return &SeatMapRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// ForTenant returns a copy of the repository that only sees the seat maps
+// of organizerIds.
+func (r *SeatMapRepository) ForTenant(organizerIds []uuid.UUID) *SeatMapRepository {
+	return &SeatMapRepository{
+		Repository: r.Repository.forTenant(organizerIds),
+	}
+}
+
+// Custom methods for SeatMapRepository
+func (r *SeatMapRepository) FindByLocationId(locationId uuid.UUID) ([]models.SeatMap, error) {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{locationId})
+	query := `
+		SELECT * FROM seat_maps
+		WHERE location_id = $1
+		AND deleted_at IS NULL
+	` + filter + `
+		ORDER BY name
+	`
+
+	seatMaps := []models.SeatMap{}
+	err := r.db.Select(&seatMaps, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return seatMaps, nil
+}
+
+// FindByEventId returns the seat maps an event sells seats of. Deleted seat
+// maps are included, as the seats bound before stay on sale.
+func (r *SeatMapRepository) FindByEventId(eventId uuid.UUID) ([]models.SeatMap, error) {
+	query := `
+		SELECT * FROM seat_maps
+		WHERE id IN (
+			SELECT s.seat_map_id FROM event_seats es
+			JOIN seats s ON s.id = es.seat_id
+			WHERE es.event_id = $1
+		)
+	`
+
+	seatMaps := []models.SeatMap{}
+	err := r.db.Select(&seatMaps, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return seatMaps, nil
+}
+
+// FindWithSeats loads a seat map with its sections in order, each with its
+// seats by row and number.
+func (r *SeatMapRepository) FindWithSeats(id uuid.UUID) (*models.SeatMap, error) {
+	seatMap, err := r.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.Select(&seatMap.Sections, `
+		SELECT * FROM seat_sections
+		WHERE seat_map_id = $1
+		ORDER BY position, name
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var seats []models.Seat
+	err = r.db.Select(&seats, `
+		SELECT * FROM seats
+		WHERE seat_map_id = $1
+		ORDER BY row_label, number
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	bySection := make(map[uuid.UUID]int, len(seatMap.Sections))
+	for i, section := range seatMap.Sections {
+		bySection[section.ID] = i
+	}
+	for _, seat := range seats {
+		i := bySection[seat.SectionID]
+		seatMap.Sections[i].Seats = append(seatMap.Sections[i].Seats, seat)
+	}
+
+	return seatMap, nil
+}
+
+func (r *SeatMapRepository) BulkCreateSections(sections []models.SeatSection) error {
+	query := `
+		INSERT INTO seat_sections (
+			id, seat_map_id, name, position, created_at
+		) VALUES (
+			:id, :seat_map_id, :name, :position, :created_at
+		)
+	`
+
+	return bulkInsert(r.db, query, sections)
+}
+
+func (r *SeatMapRepository) BulkCreateSeats(seats []models.Seat) error {
+	query := `
+		INSERT INTO seats (
+			id, seat_map_id, section_id, row_label, number,
+			x, y, accessible, obstructed, aisle, created_at
+		) VALUES (
+			:id, :seat_map_id, :section_id, :row_label, :number,
+			:x, :y, :accessible, :obstructed, :aisle, :created_at
+		)
+	`
+
+	return bulkInsert(r.db, query, seats)
+}
+
+// bulkInsert runs a multi-row named insert over rows in batches.
+func bulkInsert[T any](db DBTX, query string, rows []T) error {
+	for start := 0; start < len(rows); start += seatInsertBatchSize {
+		end := min(start+seatInsertBatchSize, len(rows))
+
+		_, err := db.NamedExec(query, rows[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"go-ticket/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TicketRepository struct {
+	*Repository[models.Ticket]
+}
+
+func NewTicketRepository(db *sqlx.DB) *TicketRepository {
+	return &TicketRepository{
+		Repository: NewRepository[models.Ticket](db, "tickets"),
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TicketRepository) WithTx(tx *sqlx.Tx) *TicketRepository {
+	return &TicketRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// Custom methods for TicketRepository
+func (r *TicketRepository) FindByTransactionId(transactionId uuid.UUID) ([]models.Ticket, error) {
+	query := `
+		SELECT * FROM tickets 
+		WHERE transaction_id = $1 
+		AND deleted_at IS NULL
+		ORDER BY created_at, code
+	`
+
+	var tickets []models.Ticket
+	err := r.db.Select(&tickets, query, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+func (r *TicketRepository) CountByTransactionDetailId(detailId uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM tickets 
+		WHERE transaction_detail_id = $1 
+		AND deleted_at IS NULL
+	`
+
+	var count int
+	err := r.db.Get(&count, query, detailId)
+	return count, err
+}
+
+// VoidValid voids up to limit valid tickets of a transaction detail,
+// newest first, and returns how many were voided.
+func (r *TicketRepository) VoidValid(detailId uuid.UUID, limit int) (int, error) {
+	query := `
+		UPDATE tickets 
+		SET status = 'void', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM tickets 
+			WHERE transaction_detail_id = $1 
+			AND status = 'valid' 
+			AND deleted_at IS NULL
+			ORDER BY created_at DESC, code
+			LIMIT $2
+			FOR UPDATE
+		)
+	`
+
+	result, err := r.db.Exec(query, detailId, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+// RestoreVoided makes the limit tickets of a transaction detail voided last
+// valid again, and returns how many it restored.
+func (r *TicketRepository) RestoreVoided(detailId uuid.UUID, limit int) (int, error) {
+	query := `
+		UPDATE tickets 
+		SET status = 'valid', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM tickets 
+			WHERE transaction_detail_id = $1 
+			AND status = 'void' 
+			AND deleted_at IS NULL
+			ORDER BY updated_at DESC, code
+			LIMIT $2
+			FOR UPDATE
+		)
+	`
+
+	result, err := r.db.Exec(query, detailId, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return int(rowsAffected), err
+}
+
+func (r *TicketRepository) FindByCode(code string) (*models.Ticket, error) {
+	query := `
+		SELECT * FROM tickets 
+		WHERE code = $1 
+		AND deleted_at IS NULL
+	`
+
+	var ticket models.Ticket
+	err := r.db.Get(&ticket, query, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// FindByIdForUpdate loads a ticket and locks its row until the surrounding
+// transaction ends.
+func (r *TicketRepository) FindByIdForUpdate(id uuid.UUID) (*models.Ticket, error) {
+	query := `
+		SELECT * FROM tickets 
+		WHERE id = $1 
+		AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var ticket models.Ticket
+	err := r.db.Get(&ticket, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// CheckIn marks a valid ticket of the event as used in a single statement,
+// so two gates scanning the same code at once cannot both accept it. It
+// returns sql.ErrNoRows when no valid ticket with that code exists for the
+// event.
+func (r *TicketRepository) CheckIn(eventId uuid.UUID, code, gate string, at time.Time) (*models.Ticket, error) {
+	query := `
+		UPDATE tickets 
+		SET status = 'used', used_at = $4, used_gate = $3, updated_at = $4
+		WHERE event_id = $1 
+		AND code = $2 
+		AND status = 'valid' 
+		AND deleted_at IS NULL
+		RETURNING *
+	`
+
+	var ticket models.Ticket
+	err := r.db.Get(&ticket, query, eventId, code, gate, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ticket, nil
+}
+
+// MarkUsed records the first admission of a ticket. Offline scans use it to
+// move used_at back when they turn out to predate the recorded check-in.
+func (r *TicketRepository) MarkUsed(id uuid.UUID, gate string, at time.Time) error {
+	query := `
+		UPDATE tickets 
+		SET status = 'used', used_at = $3, used_gate = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(query, id, gate, at)
+	return err
+}
+
+func (r *TicketRepository) CountCheckInsByEventId(eventId uuid.UUID) ([]models.TicketTypeCheckIn, error) {
+	query := `
+		SELECT 
+			tt.id AS ticket_type_id, 
+			tt.name,
+			COUNT(t.id) FILTER (WHERE t.status IN ('valid', 'used')) AS issued,
+			COUNT(t.id) FILTER (WHERE t.status = 'used') AS checked_in
+		FROM ticket_types tt
+		LEFT JOIN tickets t ON t.ticket_type_id = tt.id AND t.deleted_at IS NULL
+		WHERE tt.event_id = $1 
+		AND tt.deleted_at IS NULL
+		GROUP BY tt.id, tt.name
+		ORDER BY tt.name
+	`
+
+	var counts []models.TicketTypeCheckIn
+	err := r.db.Select(&counts, query, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func (r *TicketRepository) UpdateHolder(ticket *models.Ticket) error {
+	query := `
+		UPDATE tickets SET
+			holder_name = :holder_name,
+			holder_email = :holder_email,
+			updated_at = :updated_at
+		WHERE id = :id AND deleted_at IS NULL
+	`
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":           ticket.ID,
+		"holder_name":  ticket.HolderName,
+		"holder_email": ticket.HolderEmail,
+		"updated_at":   ticket.UpdatedAt,
+	})
+	return err
+}
+
+func (r *TicketRepository) BulkCreate(tickets []models.Ticket) error {
+	query := `
+		INSERT INTO tickets (
+			id, transaction_id, transaction_detail_id, ticket_type_id,
+			event_id, user_id, code, holder_name, holder_email, status,
+			seat_id, created_at, updated_at
+		) VALUES (
+			:id, :transaction_id, :transaction_detail_id, :ticket_type_id,
+			:event_id, :user_id, :code, :holder_name, :holder_email, :status,
+			:seat_id, :created_at, :updated_at
+		)
+	`
+
+	_, err := r.db.NamedExec(query, tickets)
+	return err
+}
+
+var ticketListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"status":         {Column: "status"},
+		"event_id":       {Column: "event_id", Parse: parseUUIDFilter},
+		"ticket_type_id": {Column: "ticket_type_id", Parse: parseUUIDFilter},
+	},
+	Sorts: map[string]string{
+		"created_at": "created_at",
+	},
+}
+
+func (r *TicketRepository) ListByUserId(userId uuid.UUID, q *ListQuery) (*Page[models.Ticket], error) {
+	return r.list(q, ticketListSpec, "user_id = $1 AND deleted_at IS NULL", userId)
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TicketScanRepository struct {
+	db DBTX
+}
+
+func NewTicketScanRepository(db *sqlx.DB) *TicketScanRepository {
+	return &TicketScanRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TicketScanRepository) WithTx(tx *sqlx.Tx) *TicketScanRepository {
+	return &TicketScanRepository{
+		db: tx,
+	}
+}
+
+func (r *TicketScanRepository) FindById(id uuid.UUID) (*models.TicketScan, error) {
+	query := `SELECT * FROM ticket_scans WHERE id = $1`
+
+	var scan models.TicketScan
+	err := r.db.Get(&scan, query, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scan, nil
+}
+
+// FindByEventId returns the scans of an event, newest first, optionally
+// only those with result.
+func (r *TicketScanRepository) FindByEventId(eventId uuid.UUID, result models.ScanResult) ([]models.TicketScan, error) {
+	query := `
+		SELECT * FROM ticket_scans 
+		WHERE event_id = $1 
+		AND ($2 = '' OR result = $2)
+		ORDER BY scanned_at DESC
+	`
+
+	var scans []models.TicketScan
+	err := r.db.Select(&scans, query, eventId, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return scans, nil
+}
+
+// Create records scan. It returns false when a scan with the same ID has
+// already been recorded.
+func (r *TicketScanRepository) Create(scan *models.TicketScan) (bool, error) {
+	query := `
+		INSERT INTO ticket_scans (
+			id, event_id, ticket_id, gate, device_id,
+			source, result, scanned_at, created_at
+		) VALUES (
+			:id, :event_id, :ticket_id, :gate, :device_id,
+			:source, :result, :scanned_at, :created_at
+		)
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	result, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":         scan.ID,
+		"event_id":   scan.EventID,
+		"ticket_id":  scan.TicketID,
+		"gate":       scan.Gate,
+		"device_id":  scan.DeviceID,
+		"source":     scan.Source,
+		"result":     scan.Result,
+		"scanned_at": scan.ScannedAt,
+		"created_at": scan.CreatedAt,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// FlagAdmissions marks the accepted scans of a ticket as duplicates, for
+// when an earlier scan of the same ticket arrives late.
+func (r *TicketScanRepository) FlagAdmissions(ticketId uuid.UUID) error {
+	query := `
+		UPDATE ticket_scans 
+		SET result = 'already_used'
+		WHERE ticket_id = $1 
+		AND result = 'valid'
+	`
+
+	_, err := r.db.Exec(query, ticketId)
+	return err
+}
+
+var ticketScanListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"result":    {Column: "result"},
+		"gate":      {Column: "gate"},
+		"source":    {Column: "source"},
+		"device_id": {Column: "device_id"},
+	},
+	Sorts: map[string]string{
+		"scanned_at": "scanned_at",
+		"created_at": "created_at",
+	},
+}
+
+// ListByEventId returns a page of the scans of an event, newest first
+// unless q sorts otherwise.
+func (r *TicketScanRepository) ListByEventId(eventId uuid.UUID, q *ListQuery) (*Page[models.TicketScan], error) {
+	if len(q.Sort) == 0 {
+		sorted := *q
+		sorted.Sort = []SortField{{Key: "scanned_at", Desc: true}}
+		q = &sorted
+	}
+	return listPage[models.TicketScan](r.db, "ticket_scans", q, ticketScanListSpec, "event_id = $1", []interface{}{eventId})
+}
@@ -6,8 +6,13 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// ErrInsufficientQuota is returned when a ticket type has fewer tickets
+// left than a checkout asks for.
+var ErrInsufficientQuota = errors.New("insufficient ticket quota")
+
 type TicketTypeRepository struct {
 	*Repository[models.TicketType]
 }
@@ -18,92 +23,80 @@ func NewTicketTypeRepository(db *sqlx.DB) *TicketTypeRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TicketTypeRepository) WithTx(tx *sqlx.Tx) *TicketTypeRepository {
+	return &TicketTypeRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
+// ForTenant returns a copy of the repository that only sees the ticket
+// types of organizerIds.
+func (r *TicketTypeRepository) ForTenant(organizerIds []uuid.UUID) *TicketTypeRepository {
+	return &TicketTypeRepository{
+		Repository: r.Repository.forTenant(organizerIds),
+	}
+}
+
 // Custom methods for TicketTypeRepository
 func (r *TicketTypeRepository) FindByEventId(eventId uuid.UUID) ([]models.TicketType, error) {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{eventId})
 	query := `
-		SELECT t.*, e.* FROM ticket_types t
-		LEFT JOIN events e ON t.event_id = e.id
-		WHERE t.event_id = $1 
-		AND t.deleted_at IS NULL
-	`
+		SELECT * FROM ticket_types
+		WHERE event_id = $1
+		AND deleted_at IS NULL
+	` + filter
 
-	rows, err := r.db.Queryx(query, eventId)
+	return r.findWithEvents(query, args...)
+}
+
+func (r *TicketTypeRepository) findWithEvents(query string, args ...interface{}) ([]models.TicketType, error) {
+	var ticketTypes []models.TicketType
+	err := r.db.Select(&ticketTypes, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var ticketTypes []models.TicketType
-	for rows.Next() {
-		var ticketType models.TicketType
-		var event models.Event
-		err := rows.Scan(
-			&ticketType.ID, &ticketType.EventID, &ticketType.Name,
-			&ticketType.Description, &ticketType.Price, &ticketType.Quota,
-			&ticketType.RemainingQuota, &ticketType.CreatedAt,
-			&ticketType.UpdatedAt, &ticketType.DeletedAt,
-			&event.ID, &event.Name, &event.Description,
-			&event.LocationID, &event.ScheduleID,
-			&event.CreatedAt, &event.UpdatedAt, &event.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		ticketType.Event = &event
-		ticketTypes = append(ticketTypes, ticketType)
+	err = attachEvents(r.db, ticketTypes)
+	if err != nil {
+		return nil, err
 	}
 
 	return ticketTypes, nil
 }
 
-func (r *TicketTypeRepository) FindAvailable(eventId uuid.UUID) ([]models.TicketType, error) {
+// FindByIdForUpdate loads a ticket type and locks its row until the
+// surrounding transaction ends. It must be called on a repository bound
+// to a transaction with WithTx.
+func (r *TicketTypeRepository) FindByIdForUpdate(id uuid.UUID) (*models.TicketType, error) {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{id})
 	query := `
-		SELECT t.*, e.* FROM ticket_types t
-		LEFT JOIN events e ON t.event_id = e.id
-		WHERE t.event_id = $1 
-		AND t.remaining_quota > 0
-		AND t.deleted_at IS NULL
+		SELECT * FROM ticket_types 
+		WHERE id = $1 
+		AND deleted_at IS NULL
+	` + filter + `
+		FOR UPDATE
 	`
 
-	rows, err := r.db.Queryx(query, eventId)
+	var ticketType models.TicketType
+	err := r.db.Get(&ticketType, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var ticketTypes []models.TicketType
-	for rows.Next() {
-		var ticketType models.TicketType
-		var event models.Event
-		err := rows.Scan(
-			&ticketType.ID, &ticketType.EventID, &ticketType.Name,
-			&ticketType.Description, &ticketType.Price, &ticketType.Quota,
-			&ticketType.RemainingQuota, &ticketType.CreatedAt,
-			&ticketType.UpdatedAt, &ticketType.DeletedAt,
-			&event.ID, &event.Name, &event.Description,
-			&event.LocationID, &event.ScheduleID,
-			&event.CreatedAt, &event.UpdatedAt, &event.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		ticketType.Event = &event
-		ticketTypes = append(ticketTypes, ticketType)
-	}
-
-	return ticketTypes, nil
+	return &ticketType, nil
 }
 
 func (r *TicketTypeRepository) UpdateQuota(id uuid.UUID, quantity int) error {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{quantity, id})
 	query := `
 		UPDATE ticket_types 
 		SET remaining_quota = remaining_quota - $1
 		WHERE id = $2 
 		AND deleted_at IS NULL
 		AND remaining_quota >= $1
-	`
+	` + filter
 
-	result, err := r.db.Exec(query, quantity, id)
+	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -114,51 +107,125 @@ func (r *TicketTypeRepository) UpdateQuota(id uuid.UUID, quantity int) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("insufficient ticket quota")
+		return ErrInsufficientQuota
 	}
 
 	return nil
 }
 
-func (r *TicketTypeRepository) Create(ticketType *models.TicketType) error {
+// RestoreQuota gives quantity tickets back to the remaining quota, for
+// example when a hold expires. It never raises remaining_quota above quota.
+func (r *TicketTypeRepository) RestoreQuota(id uuid.UUID, quantity int) error {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{quantity, id})
 	query := `
-		INSERT INTO ticket_types (
-			event_id, name, description, price, quota, remaining_quota
-		) VALUES (
-			:event_id, :name, :description, :price, :quota, :remaining_quota
-		)
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"event_id":        ticketType.EventID,
-		"name":            ticketType.Name,
-		"description":     ticketType.Description,
-		"price":           ticketType.Price,
-		"quota":           ticketType.Quota,
-		"remaining_quota": ticketType.RemainingQuota,
-	})
-	return err
+		UPDATE ticket_types 
+		SET remaining_quota = remaining_quota + $1
+		WHERE id = $2 
+		AND deleted_at IS NULL
+		AND remaining_quota + $1 <= quota
+	` + filter
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("restored quota exceeds ticket quota")
+	}
+
+	return nil
 }
 
-func (r *TicketTypeRepository) Update(ticketType *models.TicketType) error {
+// AdjustQuota adds delta to both the quota and the remaining quota, as
+// when seats are bound to or unbound from a seated ticket type. It fails
+// rather than take away tickets that are already sold or held.
+func (r *TicketTypeRepository) AdjustQuota(id uuid.UUID, delta int) error {
+	filter, args := r.tenantFilter("organizer_id", []interface{}{delta, id})
 	query := `
-		UPDATE ticket_types SET
-			name = :name,
-			description = :description,
-			price = :price,
-			quota = :quota,
-			remaining_quota = :remaining_quota,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":              ticketType.ID,
-		"event_id":        ticketType.EventID,
-		"name":            ticketType.Name,
-		"description":     ticketType.Description,
-		"price":           ticketType.Price,
-		"quota":           ticketType.Quota,
-		"remaining_quota": ticketType.RemainingQuota,
-		"updated_at":      ticketType.UpdatedAt,
-	})
-	return err
+		UPDATE ticket_types 
+		SET quota = quota + $1, remaining_quota = remaining_quota + $1, updated_at = NOW()
+		WHERE id = $2 
+		AND deleted_at IS NULL
+		AND remaining_quota + $1 >= 0
+	` + filter
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("adjusted quota is less than sold tickets")
+	}
+
+	return nil
+}
+
+func (r *TicketTypeRepository) Update(ticketType *models.TicketType) error {
+	return r.UpdateColumns(ticketType.ID, ticketType,
+		"name", "description", "price", "currency", "quota", "remaining_quota",
+		"sale_start", "sale_end", "max_per_transaction", "max_per_user")
+}
+
+var ticketTypeListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"event_id":     {Column: "event_id", Parse: parseUUIDFilter},
+		"name":         {Column: "name", Op: FilterContains},
+		"organizer_id": {Column: "organizer_id", Parse: parseUUIDFilter},
+	},
+	Sorts: map[string]string{
+		"name":       "name",
+		"price":      "price",
+		"created_at": "created_at",
+	},
+}
+
+func (r *TicketTypeRepository) List(q *ListQuery) (*Page[models.TicketType], error) {
+	return r.list(q, ticketTypeListSpec, "deleted_at IS NULL")
+}
+
+// ListListed is List limited to the ticket types of events the public
+// sees, along with every ticket type of organizerIds.
+func (r *TicketTypeRepository) ListListed(q *ListQuery, organizerIds []uuid.UUID) (*Page[models.TicketType], error) {
+	where := `deleted_at IS NULL AND (organizer_id = ANY($2) OR event_id IN (
+		SELECT id FROM events WHERE status = ANY($1) AND deleted_at IS NULL
+	))`
+	return r.list(q, ticketTypeListSpec, where, pq.Array(models.ListedEventStatuses), pq.Array(organizerIds))
+}
+
+// ListByEventId returns a page of the ticket types of an event, each with
+// the event attached.
+func (r *TicketTypeRepository) ListByEventId(eventId uuid.UUID, q *ListQuery) (*Page[models.TicketType], error) {
+	page, err := r.list(q, ticketTypeListSpec, "event_id = $1 AND deleted_at IS NULL", eventId)
+	if err != nil {
+		return nil, err
+	}
+	return page, attachEvents(r.db, page.Items)
+}
+
+// ListAvailable is ListByEventId limited to ticket types with quota left.
+func (r *TicketTypeRepository) ListAvailable(eventId uuid.UUID, q *ListQuery) (*Page[models.TicketType], error) {
+	page, err := r.list(q, ticketTypeListSpec, "event_id = $1 AND remaining_quota > 0 AND deleted_at IS NULL", eventId)
+	if err != nil {
+		return nil, err
+	}
+	return page, attachEvents(r.db, page.Items)
+}
+
+func attachEvents(db DBTX, ticketTypes []models.TicketType) error {
+	return belongsTo(db, "events", ticketTypes,
+		func(t *models.TicketType) uuid.UUID { return t.EventID },
+		func(t *models.TicketType, e *models.Event) { t.Event = e },
+	)
 }
@@ -1,10 +1,12 @@
 package repository
 
 import (
+	"errors"
 	"go-ticket/models"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type TransactionDetailRepository struct {
@@ -17,53 +19,163 @@ func NewTransactionDetailRepository(db *sqlx.DB) *TransactionDetailRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TransactionDetailRepository) WithTx(tx *sqlx.Tx) *TransactionDetailRepository {
+	return &TransactionDetailRepository{
+		Repository: r.Repository.withTx(tx),
+	}
+}
+
 // Custom methods for TransactionDetailRepository
 func (r *TransactionDetailRepository) FindByTransactionId(transactionId uuid.UUID) ([]models.TransactionDetail, error) {
 	query := `
-		SELECT td.*, tt.* FROM transaction_details td
-		LEFT JOIN ticket_types tt ON td.ticket_type_id = tt.id
-		WHERE td.transaction_id = $1 
-		AND td.deleted_at IS NULL
+		SELECT * FROM transaction_details
+		WHERE transaction_id = $1
+		AND deleted_at IS NULL
 	`
 
-	rows, err := r.db.Queryx(query, transactionId)
+	var details []models.TransactionDetail
+	err := r.db.Select(&details, query, transactionId)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+
+	err = attachTicketTypes(r.db, details)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
+
+func attachTicketTypes(db DBTX, details []models.TransactionDetail) error {
+	return belongsTo(db, "ticket_types", details,
+		func(d *models.TransactionDetail) uuid.UUID { return d.TicketTypeID },
+		func(d *models.TransactionDetail, t *models.TicketType) { d.TicketType = t },
+	)
+}
+
+// FindByTransactionIdForUpdate loads the details of a transaction without
+// their ticket types and locks their rows.
+func (r *TransactionDetailRepository) FindByTransactionIdForUpdate(transactionId uuid.UUID) ([]models.TransactionDetail, error) {
+	query := `
+		SELECT * FROM transaction_details 
+		WHERE transaction_id = $1 
+		AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE
+	`
 
 	var details []models.TransactionDetail
-	for rows.Next() {
-		var detail models.TransactionDetail
-		var ticketType models.TicketType
-		err := rows.Scan(
-			&detail.ID, &detail.TransactionID, &detail.TicketTypeID,
-			&detail.Quantity, &detail.PricePerTicket, &detail.Subtotal,
-			&detail.CreatedAt, &detail.UpdatedAt, &detail.DeletedAt,
-			&ticketType.ID, &ticketType.EventID, &ticketType.Name,
-			&ticketType.Description, &ticketType.Price, &ticketType.Quota,
-			&ticketType.RemainingQuota, &ticketType.CreatedAt,
-			&ticketType.UpdatedAt, &ticketType.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		detail.TicketType = &ticketType
-		details = append(details, detail)
+	err := r.db.Select(&details, query, transactionId)
+	if err != nil {
+		return nil, err
 	}
 
 	return details, nil
 }
 
+// AddRefundedQuantity marks quantity more tickets of a detail as refunded.
+// It fails rather than refund more tickets than were bought.
+func (r *TransactionDetailRepository) AddRefundedQuantity(id uuid.UUID, quantity int) error {
+	query := `
+		UPDATE transaction_details 
+		SET refunded_quantity = refunded_quantity + $1, updated_at = NOW()
+		WHERE id = $2 
+		AND deleted_at IS NULL
+		AND refunded_quantity + $1 <= quantity
+	`
+
+	result, err := r.db.Exec(query, quantity, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("refund exceeds purchased quantity")
+	}
+
+	return nil
+}
+
+// RemoveRefundedQuantity takes back quantity tickets of a detail marked as
+// refunded, when the provider did not refund them after all.
+func (r *TransactionDetailRepository) RemoveRefundedQuantity(id uuid.UUID, quantity int) error {
+	query := `
+		UPDATE transaction_details 
+		SET refunded_quantity = refunded_quantity - $1, updated_at = NOW()
+		WHERE id = $2 
+		AND deleted_at IS NULL
+		AND refunded_quantity >= $1
+	`
+
+	result, err := r.db.Exec(query, quantity, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("removed quantity exceeds refunded quantity")
+	}
+
+	return nil
+}
+
+// CountHeldByUsers returns how many tickets of each ticket type of an event
+// userIds hold, in transactions that are not cancelled and less those that
+// were refunded. Pending transactions whose payment failed still count: a
+// failed payment may be retried and paid until the hold sweeper cancels the
+// transaction, so leaving them out would let a buyer hold twice the cap and
+// pay for both.
+func (r *TransactionDetailRepository) CountHeldByUsers(eventId uuid.UUID, userIds []uuid.UUID) (map[uuid.UUID]int, error) {
+	query := `
+		SELECT d.ticket_type_id, SUM(d.quantity - d.refunded_quantity) AS quantity
+		FROM transaction_details d
+		JOIN transactions t ON t.id = d.transaction_id
+		WHERE t.event_id = $1
+		AND t.user_id = ANY($2)
+		AND t.status <> $3
+		AND t.deleted_at IS NULL
+		AND d.deleted_at IS NULL
+		GROUP BY d.ticket_type_id
+	`
+
+	var rows []struct {
+		TicketTypeID uuid.UUID `db:"ticket_type_id"`
+		Quantity     int       `db:"quantity"`
+	}
+	err := r.db.Select(&rows, query, eventId, pq.Array(userIds), models.TransactionStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		held[row.TicketTypeID] = row.Quantity
+	}
+
+	return held, nil
+}
+
 func (r *TransactionDetailRepository) BulkCreate(details []models.TransactionDetail) error {
 	query := `
 		INSERT INTO transaction_details (
 			id, transaction_id, ticket_type_id,
-			quantity, price_per_ticket, subtotal,
+			quantity, price_per_ticket, discount, subtotal,
 			created_at, updated_at
 		) VALUES (
 			:id, :transaction_id, :ticket_type_id,
-			:quantity, :price_per_ticket, :subtotal,
+			:quantity, :price_per_ticket, :discount, :subtotal,
 			:created_at, :updated_at
 		)
 	`
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"go-ticket/models"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -9,7 +10,7 @@ import (
 
 type TransactionRepository struct {
 	*Repository[models.Transaction]
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewTransactionRepository(db *sqlx.DB) *TransactionRepository {
@@ -19,101 +20,168 @@ func NewTransactionRepository(db *sqlx.DB) *TransactionRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TransactionRepository) WithTx(tx *sqlx.Tx) *TransactionRepository {
+	return &TransactionRepository{
+		Repository: r.Repository.withTx(tx),
+		db:         tx,
+	}
+}
+
 // Custom methods for TransactionRepository
-func (r *TransactionRepository) FindByUserId(userId uuid.UUID) ([]models.Transaction, error) {
+// FindWithDetails loads a transaction with its user and its details, each
+// with its ticket type.
+func (r *TransactionRepository) FindWithDetails(id uuid.UUID) (*models.Transaction, error) {
+	transaction, err := r.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []models.Transaction{*transaction}
+	err = attachUsers(r.db, transactions)
+	if err != nil {
+		return nil, err
+	}
+	transaction = &transactions[0]
+
 	query := `
-		SELECT t.*, u.* FROM transactions t
-		LEFT JOIN users u ON t.user_id = u.id
-		WHERE t.user_id = $1 
-		AND t.deleted_at IS NULL
-		ORDER BY t.created_at DESC
+		SELECT * FROM transaction_details
+		WHERE transaction_id = $1
+		AND deleted_at IS NULL
 	`
-
-	rows, err := r.db.Queryx(query, userId)
+	err = r.db.Select(&transaction.Details, query, transaction.ID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transactions []models.Transaction
-	for rows.Next() {
-		var transaction models.Transaction
-		var user models.User
-		err := rows.Scan(
-			&transaction.ID, &transaction.UserID, &transaction.EventID, &transaction.TotalAmount, &transaction.Status,
-			&transaction.PaymentMethod, &transaction.PaymentStatus, &transaction.PaymentUrl, &transaction.PaymentCallback,
-			&transaction.CreatedAt, &transaction.UpdatedAt, &transaction.DeletedAt,
-			&user.ID, &user.Fullname, &user.Email, &user.Phone, &user.Password,
-			&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		transaction.User = &user
-		transactions = append(transactions, transaction)
+
+	err = attachTicketTypes(r.db, transaction.Details)
+	if err != nil {
+		return nil, err
 	}
 
-	return transactions, nil
+	return transaction, nil
 }
 
-func (r *TransactionRepository) FindWithDetails(id uuid.UUID) (*models.Transaction, error) {
+func attachUsers(db DBTX, transactions []models.Transaction) error {
+	return belongsTo(db, "users", transactions,
+		func(t *models.Transaction) uuid.UUID { return t.UserID },
+		func(t *models.Transaction, u *models.User) { t.User = u },
+	)
+}
+
+// FindByIdForUpdate loads a transaction and locks its row until the
+// surrounding transaction ends.
+func (r *TransactionRepository) FindByIdForUpdate(id uuid.UUID) (*models.Transaction, error) {
 	query := `
-		SELECT t.*, u.*, td.*, tt.* FROM transactions t
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN transaction_details td ON td.transaction_id = t.id
-		LEFT JOIN ticket_types tt ON td.ticket_type_id = tt.id
-		WHERE t.id = $1 
-		AND t.deleted_at IS NULL
+		SELECT * FROM transactions 
+		WHERE id = $1 
+		AND deleted_at IS NULL
+		FOR UPDATE
 	`
 
-	rows, err := r.db.Queryx(query, id)
+	var transaction models.Transaction
+	err := r.db.Get(&transaction, query, id)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var transaction *models.Transaction
-	var details []models.TransactionDetail
-
-	for rows.Next() {
-		var user models.User
-		var detail models.TransactionDetail
-		var ticketType models.TicketType
-
-		if transaction == nil {
-			transaction = &models.Transaction{}
-			err := rows.Scan(
-				&transaction.ID, &transaction.UserID, &transaction.EventID, &transaction.TotalAmount, &transaction.Status,
-				&transaction.PaymentMethod, &transaction.PaymentStatus, &transaction.PaymentUrl, &transaction.PaymentCallback,
-				&transaction.CreatedAt, &transaction.UpdatedAt, &transaction.DeletedAt,
-				&user.ID, &user.Fullname, &user.Email, &user.Phone, &user.Password,
-				&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt,
-				&detail.ID, &detail.TransactionID, &detail.TicketTypeID,
-				&detail.Quantity, &detail.PricePerTicket, &detail.Subtotal,
-				&detail.CreatedAt, &detail.UpdatedAt, &detail.DeletedAt,
-				&ticketType.ID, &ticketType.EventID, &ticketType.Name,
-				&ticketType.Description, &ticketType.Price, &ticketType.Quota,
-				&ticketType.RemainingQuota, &ticketType.CreatedAt,
-				&ticketType.UpdatedAt, &ticketType.DeletedAt,
-			)
-			if err != nil {
-				return nil, err
-			}
-			transaction.User = &user
-		}
-
-		detail.TicketType = &ticketType
-		details = append(details, detail)
+	return &transaction, nil
+}
+
+// FindByPaymentReferenceForUpdate loads the transaction charged through
+// provider under reference and locks its row.
+func (r *TransactionRepository) FindByPaymentReferenceForUpdate(provider, reference string) (*models.Transaction, error) {
+	query := `
+		SELECT * FROM transactions 
+		WHERE payment_provider = $1 
+		AND payment_reference = $2 
+		AND deleted_at IS NULL
+		FOR UPDATE
+	`
+
+	var transaction models.Transaction
+	err := r.db.Get(&transaction, query, provider, reference)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// FindExpiredPendingIds returns the IDs of pending transactions whose hold
+// expired at or before now, oldest first.
+func (r *TransactionRepository) FindExpiredPendingIds(now time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM transactions 
+		WHERE status = 'pending' 
+		AND payment_status <> 'paid'
+		AND expires_at <= $1 
+		AND deleted_at IS NULL
+		ORDER BY expires_at
+		LIMIT $2
+	`
+
+	var ids []uuid.UUID
+	err := r.db.Select(&ids, query, now, limit)
+	if err != nil {
+		return nil, err
 	}
+	return ids, nil
+}
+
+// FindExpiredPendingForUpdate locks a single expired pending transaction.
+// Rows already locked by another worker are skipped, in which case it
+// returns sql.ErrNoRows.
+func (r *TransactionRepository) FindExpiredPendingForUpdate(id uuid.UUID, now time.Time) (*models.Transaction, error) {
+	query := `
+		SELECT * FROM transactions 
+		WHERE id = $1 
+		AND status = 'pending' 
+		AND payment_status <> 'paid'
+		AND expires_at <= $2 
+		AND deleted_at IS NULL
+		FOR UPDATE SKIP LOCKED
+	`
 
-	if transaction != nil {
-		transaction.Details = details
+	var transaction models.Transaction
+	err := r.db.Get(&transaction, query, id, now)
+	if err != nil {
+		return nil, err
 	}
+	return &transaction, nil
+}
 
-	return transaction, nil
+func (r *TransactionRepository) CountByEventId(eventId uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM transactions
+		WHERE event_id = $1
+		AND deleted_at IS NULL
+	`
+
+	var count int
+	err := r.db.Get(&count, query, eventId)
+	return count, err
 }
 
-func (r *TransactionRepository) UpdateStatus(id uuid.UUID, status string) error {
+// FindIdsByEventIdAfter returns up to limit IDs of the transactions of an
+// event in ID order, starting after the given ID when it is not nil.
+func (r *TransactionRepository) FindIdsByEventIdAfter(eventId uuid.UUID, after *uuid.UUID, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM transactions
+		WHERE event_id = $1
+		AND ($2::uuid IS NULL OR id > $2)
+		AND deleted_at IS NULL
+		ORDER BY id
+		LIMIT $3
+	`
+
+	var ids []uuid.UUID
+	err := r.db.Select(&ids, query, eventId, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *TransactionRepository) UpdateStatus(id uuid.UUID, status models.TransactionStatus) error {
 	query := `
 		UPDATE transactions 
 		SET status = $1, updated_at = NOW()
@@ -123,7 +191,7 @@ func (r *TransactionRepository) UpdateStatus(id uuid.UUID, status string) error
 	return err
 }
 
-func (r *TransactionRepository) UpdatePaymentStatus(id uuid.UUID, status string) error {
+func (r *TransactionRepository) UpdatePaymentStatus(id uuid.UUID, status models.PaymentStatus) error {
 	query := `
 		UPDATE transactions 
 		SET payment_status = $1, updated_at = NOW()
@@ -133,57 +201,60 @@ func (r *TransactionRepository) UpdatePaymentStatus(id uuid.UUID, status string)
 	return err
 }
 
-func (r *TransactionRepository) Create(transaction *models.Transaction) error {
+func (r *TransactionRepository) UpdatePaymentCallback(id uuid.UUID, callback string) error {
 	query := `
-		INSERT INTO transactions (
-			id, user_id, event_id, total_amount,
-			status, payment_method, payment_status,
-			payment_url, payment_callback,
-			created_at, updated_at
-		) VALUES (
-			:id, :user_id, :event_id, :total_amount,
-			:status, :payment_method, :payment_status,
-			:payment_url, :payment_callback,
-			:created_at, :updated_at
-		)
+		UPDATE transactions 
+		SET payment_callback = $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL
 	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":               transaction.ID,
-		"user_id":          transaction.UserID,
-		"event_id":         transaction.EventID,
-		"total_amount":     transaction.TotalAmount,
-		"status":           transaction.Status,
-		"payment_method":   transaction.PaymentMethod,
-		"payment_status":   transaction.PaymentStatus,
-		"payment_url":      transaction.PaymentUrl,
-		"payment_callback": transaction.PaymentCallback,
-		"created_at":       transaction.CreatedAt,
-		"updated_at":       transaction.UpdatedAt,
-	})
+	_, err := r.db.Exec(query, callback, id)
 	return err
 }
 
-func (r *TransactionRepository) Update(transaction *models.Transaction) error {
+// UpdatePaymentCharge records the provider charge of a transaction.
+func (r *TransactionRepository) UpdatePaymentCharge(id uuid.UUID, provider, reference, url string) error {
 	query := `
-		UPDATE transactions SET
-			total_amount = :total_amount,
-			status = :status,
-			payment_method = :payment_method,
-			payment_status = :payment_status,
-			payment_url = :payment_url,
-			payment_callback = :payment_callback,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
+		UPDATE transactions
+		SET payment_provider = $1, payment_reference = $2, payment_url = $3, updated_at = NOW()
+		WHERE id = $4 AND deleted_at IS NULL
 	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":               transaction.ID,
-		"total_amount":     transaction.TotalAmount,
-		"status":           transaction.Status,
-		"payment_method":   transaction.PaymentMethod,
-		"payment_status":   transaction.PaymentStatus,
-		"payment_url":      transaction.PaymentUrl,
-		"payment_callback": transaction.PaymentCallback,
-		"updated_at":       transaction.UpdatedAt,
-	})
+	_, err := r.db.Exec(query, provider, reference, url, id)
 	return err
 }
+
+func (r *TransactionRepository) Update(transaction *models.Transaction) error {
+	return r.UpdateColumns(transaction.ID, transaction,
+		"total_amount", "status", "payment_method", "payment_status",
+		"payment_url", "payment_callback")
+}
+
+var transactionListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"status":         {Column: "status"},
+		"payment_status": {Column: "payment_status"},
+		"payment_method": {Column: "payment_method"},
+		"event_id":       {Column: "event_id", Parse: parseUUIDFilter},
+		"user_id":        {Column: "user_id", Parse: parseUUIDFilter},
+		"created_from":   {Column: "created_at", Op: FilterGte, Parse: parseTimeFilter},
+		"created_to":     {Column: "created_at", Op: FilterLte, Parse: parseTimeFilter},
+	},
+	Sorts: map[string]string{
+		"total_amount": "total_amount",
+		"created_at":   "created_at",
+		"updated_at":   "updated_at",
+	},
+}
+
+func (r *TransactionRepository) List(q *ListQuery) (*Page[models.Transaction], error) {
+	return r.list(q, transactionListSpec, "deleted_at IS NULL")
+}
+
+// ListByUserId returns a page of the transactions of a user, each with the
+// user attached.
+func (r *TransactionRepository) ListByUserId(userId uuid.UUID, q *ListQuery) (*Page[models.Transaction], error) {
+	page, err := r.list(q, transactionListSpec, "user_id = $1 AND deleted_at IS NULL", userId)
+	if err != nil {
+		return nil, err
+	}
+	return page, attachUsers(r.db, page.Items)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// TransactionStatusHistoryRepository does not embed Repository[T] because
+// history rows are append-only and have no updated_at or deleted_at.
+type TransactionStatusHistoryRepository struct {
+	db DBTX
+}
+
+func NewTransactionStatusHistoryRepository(db *sqlx.DB) *TransactionStatusHistoryRepository {
+	return &TransactionStatusHistoryRepository{
+		db: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *TransactionStatusHistoryRepository) WithTx(tx *sqlx.Tx) *TransactionStatusHistoryRepository {
+	return &TransactionStatusHistoryRepository{
+		db: tx,
+	}
+}
+
+func (r *TransactionStatusHistoryRepository) FindByTransactionId(transactionId uuid.UUID) ([]models.TransactionStatusHistory, error) {
+	query := `
+		SELECT * FROM transaction_status_history 
+		WHERE transaction_id = $1 
+		ORDER BY created_at, id
+	`
+
+	var history []models.TransactionStatusHistory
+	err := r.db.Select(&history, query, transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (r *TransactionStatusHistoryRepository) Create(history *models.TransactionStatusHistory) error {
+	query := `
+		INSERT INTO transaction_status_history (
+			id, transaction_id, field, from_status, to_status,
+			actor, reason, created_at
+		) VALUES (
+			:id, :transaction_id, :field, :from_status, :to_status,
+			:actor, :reason, :created_at
+		)
+	`
+	_, err := r.db.NamedExec(query, map[string]interface{}{
+		"id":             history.ID,
+		"transaction_id": history.TransactionID,
+		"field":          history.Field,
+		"from_status":    history.FromStatus,
+		"to_status":      history.ToStatus,
+		"actor":          history.Actor,
+		"reason":         history.Reason,
+		"created_at":     history.CreatedAt,
+	})
+	return err
+}
@@ -3,12 +3,13 @@ package repository
 import (
 	"go-ticket/models"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type UserRepository struct {
 	*Repository[models.User]
-	db *sqlx.DB
+	db DBTX
 }
 
 func NewUserRepository(db *sqlx.DB) *UserRepository {
@@ -18,6 +19,14 @@ func NewUserRepository(db *sqlx.DB) *UserRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries inside tx.
+func (r *UserRepository) WithTx(tx *sqlx.Tx) *UserRepository {
+	return &UserRepository{
+		Repository: r.Repository.withTx(tx),
+		db:         tx,
+	}
+}
+
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
 	query := `
@@ -46,45 +55,53 @@ func (r *UserRepository) FindByPhone(phone string) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *UserRepository) Create(user *models.User) error {
+// LockBuyers locks the account of a buyer and returns its ID. With
+// byContact it also locks and returns the accounts sharing the buyer's
+// email, regardless of case, or phone, where both accounts verified that
+// contact, so nobody can tie their tickets to an account by claiming its
+// contact. Rows are locked in ID order so that checkouts locking
+// overlapping accounts wait for each other instead of deadlocking.
+func (r *UserRepository) LockBuyers(userId uuid.UUID, byContact bool) ([]uuid.UUID, error) {
 	query := `
-		INSERT INTO users (
-			id, fullname, email, phone, password,
-			created_at, updated_at
-		) VALUES (
-			:id, :fullname, :email, :phone, :password,
-			:created_at, :updated_at
-		)
+		SELECT u.id FROM users u
+		JOIN users buyer ON buyer.id = $1
+		WHERE u.id = buyer.id
+		OR ($2 AND u.deleted_at IS NULL AND (
+			(buyer.email_verified_at IS NOT NULL AND u.email_verified_at IS NOT NULL
+				AND LOWER(u.email) = LOWER(buyer.email))
+			OR (buyer.phone_verified_at IS NOT NULL AND u.phone_verified_at IS NOT NULL
+				AND COALESCE(buyer.phone, '') <> '' AND u.phone = buyer.phone)
+		))
+		ORDER BY u.id
+		FOR NO KEY UPDATE OF u
 	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":         user.ID,
-		"fullname":   user.Fullname,
-		"email":      user.Email,
-		"phone":      user.Phone,
-		"password":   "-",
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
-	})
-	return err
+
+	var ids []uuid.UUID
+	err := r.db.Select(&ids, query, userId, byContact)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
 }
 
 func (r *UserRepository) Update(user *models.User) error {
-	query := `
-		UPDATE users SET
-			fullname = :fullname,
-			email = :email,
-			phone = :phone,
-			password = :password,
-			updated_at = :updated_at
-		WHERE id = :id AND deleted_at IS NULL
-	`
-	_, err := r.db.NamedExec(query, map[string]interface{}{
-		"id":         user.ID,
-		"fullname":   user.Fullname,
-		"email":      user.Email,
-		"phone":      user.Phone,
-		"password":   user.Password,
-		"updated_at": user.UpdatedAt,
-	})
-	return err
+	return r.UpdateColumns(user.ID, user,
+		"fullname", "email", "phone", "password", "email_verified_at", "phone_verified_at")
+}
+
+var userListSpec = ListSpec{
+	Filters: map[string]Filter{
+		"name":  {Column: "fullname", Op: FilterContains},
+		"email": {Column: "email", Op: FilterContains},
+	},
+	Sorts: map[string]string{
+		"name":       "fullname",
+		"email":      "email",
+		"created_at": "created_at",
+	},
+}
+
+func (r *UserRepository) List(q *ListQuery) (*Page[models.User], error) {
+	return r.list(q, userListSpec, "deleted_at IS NULL")
 }
@@ -0,0 +1,222 @@
+package seating
+
+import (
+	"bytes"
+	"go-ticket/models"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Point is a position on a seat map, in the units of its seat coordinates.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Allocator hands out the best available seats of an event. It is built
+// once from every seat the event sells and then answers any number of
+// requests, remembering the seats it handed out so that parties of one
+// checkout never share a seat.
+type Allocator struct {
+	rows  [][]*seat
+	taken map[uuid.UUID]bool
+}
+
+type seat struct {
+	id           uuid.UUID
+	ticketTypeID uuid.UUID
+	sectionID    uuid.UUID
+	available    bool
+	distance     float64
+}
+
+// window is a block of adjacent seats of a row, from start up to end.
+type window struct {
+	row      int
+	start    int
+	end      int
+	orphans  int
+	distance float64
+}
+
+// better ranks windows of the same size: fewer seats left alone in their
+// block of free seats first, then closer to the stage in total.
+func (w window) better(other window) bool {
+	if w.orphans != other.orphans {
+		return w.orphans < other.orphans
+	}
+	return w.distance < other.distance
+}
+
+// NewAllocator lays out eventSeats by row. Seats of a row are adjacent when
+// they follow each other by number, so a row is only broken up by seats
+// that are sold, held or sold under another ticket type. The distance of a
+// seat is measured to the stage of its seat map in stages.
+func NewAllocator(eventSeats []models.EventSeat, stages map[uuid.UUID]Point) *Allocator {
+	type rowKey struct {
+		sectionID uuid.UUID
+		label     string
+	}
+
+	byRow := make(map[rowKey][]models.EventSeat)
+	var keys []rowKey
+	for _, eventSeat := range eventSeats {
+		if eventSeat.Seat == nil {
+			continue
+		}
+		key := rowKey{eventSeat.Seat.SectionID, eventSeat.Seat.Row}
+		if _, ok := byRow[key]; !ok {
+			keys = append(keys, key)
+		}
+		byRow[key] = append(byRow[key], eventSeat)
+	}
+
+	// Rows are kept in a fixed order so that equally good windows are
+	// always resolved the same way.
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].sectionID != keys[j].sectionID {
+			return bytes.Compare(keys[i].sectionID[:], keys[j].sectionID[:]) < 0
+		}
+		return keys[i].label < keys[j].label
+	})
+
+	a := &Allocator{
+		rows:  make([][]*seat, 0, len(keys)),
+		taken: make(map[uuid.UUID]bool),
+	}
+	for _, key := range keys {
+		rowSeats := byRow[key]
+		sort.Slice(rowSeats, func(i, j int) bool {
+			return rowSeats[i].Seat.Number < rowSeats[j].Seat.Number
+		})
+
+		row := make([]*seat, len(rowSeats))
+		for i, eventSeat := range rowSeats {
+			stage := stages[eventSeat.Seat.SeatMapID]
+			row[i] = &seat{
+				id:           eventSeat.SeatID,
+				ticketTypeID: eventSeat.TicketTypeID,
+				sectionID:    eventSeat.Seat.SectionID,
+				available:    eventSeat.Status == models.EventSeatStatusAvailable,
+				distance:     math.Hypot(eventSeat.Seat.X-stage.X, eventSeat.Seat.Y-stage.Y),
+			}
+		}
+		a.rows = append(a.rows, row)
+	}
+
+	return a
+}
+
+// Take marks seats as handed out, for seats a buyer picked themselves.
+func (a *Allocator) Take(seatIds []uuid.UUID) {
+	for _, id := range seatIds {
+		a.taken[id] = true
+	}
+}
+
+// Allocate picks quantity available seats of a ticket type, within a
+// section unless sectionID is nil. It prefers the whole party in adjacent
+// seats of one row, then the fewest seats left alone, then the seats
+// closest to the stage. When no row has room for the party it is split
+// into as few blocks as possible. It returns false when there are not
+// enough seats.
+func (a *Allocator) Allocate(ticketTypeID uuid.UUID, sectionID *uuid.UUID, quantity int) ([]uuid.UUID, bool) {
+	free := func(s *seat) bool {
+		return s.available && !a.taken[s.id] && s.ticketTypeID == ticketTypeID &&
+			(sectionID == nil || s.sectionID == *sectionID)
+	}
+
+	seatIds := make([]uuid.UUID, 0, quantity)
+	for len(seatIds) < quantity {
+		size := min(quantity-len(seatIds), a.longestBlock(free))
+		if size == 0 {
+			a.release(seatIds)
+			return nil, false
+		}
+
+		best := a.bestWindow(free, size)
+		for _, s := range a.rows[best.row][best.start:best.end] {
+			a.taken[s.id] = true
+			seatIds = append(seatIds, s.id)
+		}
+	}
+
+	return seatIds, true
+}
+
+func (a *Allocator) release(seatIds []uuid.UUID) {
+	for _, id := range seatIds {
+		delete(a.taken, id)
+	}
+}
+
+// longestBlock returns the length of the longest block of adjacent free
+// seats.
+func (a *Allocator) longestBlock(free func(*seat) bool) int {
+	longest := 0
+	for _, row := range a.rows {
+		length := 0
+		for _, s := range row {
+			if !free(s) {
+				length = 0
+				continue
+			}
+			length++
+			longest = max(longest, length)
+		}
+	}
+	return longest
+}
+
+// bestWindow slides a window of size seats over every block of free seats
+// and returns the best one, of which there is at least one when a block is
+// as long as size. Summing distances as the window slides keeps it linear
+// in the number of seats.
+func (a *Allocator) bestWindow(free func(*seat) bool, size int) window {
+	var best window
+	found := false
+
+	for r, row := range a.rows {
+		for blockStart := 0; blockStart < len(row); {
+			if !free(row[blockStart]) {
+				blockStart++
+				continue
+			}
+			blockEnd := blockStart
+			for blockEnd < len(row) && free(row[blockEnd]) {
+				blockEnd++
+			}
+
+			distance := 0.0
+			for i := blockStart; i < blockEnd; i++ {
+				distance += row[i].distance
+				start := i - size + 1
+				if start < blockStart {
+					continue
+				}
+				if start > blockStart {
+					distance -= row[start-1].distance
+				}
+
+				candidate := window{row: r, start: start, end: i + 1, distance: distance}
+				if start-blockStart == 1 {
+					candidate.orphans++
+				}
+				if blockEnd-candidate.end == 1 {
+					candidate.orphans++
+				}
+
+				if !found || candidate.better(best) {
+					best = candidate
+					found = true
+				}
+			}
+
+			blockStart = blockEnd
+		}
+	}
+
+	return best
+}
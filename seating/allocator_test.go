@@ -0,0 +1,209 @@
+package seating
+
+import (
+	"fmt"
+	"go-ticket/models"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// seatMap lays out rows of seats from strings such as "..x.", a '.' for an
+// available seat and an 'x' for a sold one. Rows are named A, B, ... from
+// the stage back, and seats numbered from 1 away from it.
+type seatMap struct {
+	eventSeats []models.EventSeat
+	labels     map[uuid.UUID]string
+	mapID      uuid.UUID
+}
+
+func newSeatMap(ticketTypeID, sectionID uuid.UUID, rows ...string) *seatMap {
+	m := &seatMap{labels: make(map[uuid.UUID]string), mapID: uuid.New()}
+	for r, row := range rows {
+		for i, status := range row {
+			label := fmt.Sprintf("%c%d", 'A'+r, i+1)
+			eventSeat := models.EventSeat{
+				SeatID:       uuid.New(),
+				TicketTypeID: ticketTypeID,
+				Status:       models.EventSeatStatusAvailable,
+				Seat: &models.Seat{
+					SeatMapID: m.mapID,
+					SectionID: sectionID,
+					Row:       fmt.Sprintf("%c", 'A'+r),
+					Number:    i + 1,
+					X:         float64(i + 1),
+					Y:         float64(r+1) * 10,
+				},
+			}
+			if status == 'x' {
+				eventSeat.Status = models.EventSeatStatusSold
+			}
+			m.eventSeats = append(m.eventSeats, eventSeat)
+			m.labels[eventSeat.SeatID] = label
+		}
+	}
+	return m
+}
+
+func (m *seatMap) allocator() *Allocator {
+	return NewAllocator(m.eventSeats, map[uuid.UUID]Point{m.mapID: {}})
+}
+
+func (m *seatMap) names(seatIds []uuid.UUID) []string {
+	names := make([]string, len(seatIds))
+	for i, id := range seatIds {
+		names[i] = m.labels[id]
+	}
+	return names
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     []string
+		quantity int
+		want     []string
+	}{
+		{
+			name:     "adjacent seats over closer scattered ones",
+			rows:     []string{"x.x.x.x", "...."},
+			quantity: 3,
+			want:     []string{"B1", "B2", "B3"},
+		},
+		{
+			name:     "closest seats when nothing is left alone",
+			rows:     []string{"......", "......"},
+			quantity: 2,
+			want:     []string{"A1", "A2"},
+		},
+		{
+			name:     "no seat left alone over closer seats",
+			rows:     []string{"...x", "..x"},
+			quantity: 2,
+			want:     []string{"B1", "B2"},
+		},
+		{
+			name:     "split party when no row has room",
+			rows:     []string{"..x..", "x.x.x"},
+			quantity: 4,
+			want:     []string{"A1", "A2", "A4", "A5"},
+		},
+		{
+			name:     "split party across rows",
+			rows:     []string{"x..x", "x..x"},
+			quantity: 4,
+			want:     []string{"A2", "A3", "B2", "B3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticketTypeID := uuid.New()
+			m := newSeatMap(ticketTypeID, uuid.New(), tt.rows...)
+
+			seatIds, ok := m.allocator().Allocate(ticketTypeID, nil, tt.quantity)
+			if !ok {
+				t.Fatalf("Allocate(%d) found no seats", tt.quantity)
+			}
+			if got := m.names(seatIds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate(%d) = %v, want %v", tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateInsufficientSeats(t *testing.T) {
+	ticketTypeID := uuid.New()
+	m := newSeatMap(ticketTypeID, uuid.New(), "..x", "x.x")
+	a := m.allocator()
+
+	seatIds, ok := a.Allocate(ticketTypeID, nil, 4)
+	if ok || seatIds != nil {
+		t.Fatalf("Allocate(4) of 3 seats = %v, %v, want nil, false", m.names(seatIds), ok)
+	}
+
+	// The seats picked before running out are handed back
+	seatIds, ok = a.Allocate(ticketTypeID, nil, 3)
+	if !ok {
+		t.Fatal("Allocate(3) found no seats after a failed allocation")
+	}
+	if got, want := m.names(seatIds), []string{"A1", "A2", "B2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate(3) = %v, want %v", got, want)
+	}
+}
+
+func TestAllocateNeverHandsOutASeatTwice(t *testing.T) {
+	ticketTypeID := uuid.New()
+	m := newSeatMap(ticketTypeID, uuid.New(), "....")
+	a := m.allocator()
+	a.Take([]uuid.UUID{m.eventSeats[0].SeatID})
+
+	first, ok := a.Allocate(ticketTypeID, nil, 2)
+	if !ok {
+		t.Fatal("Allocate(2) found no seats")
+	}
+	if got, want := m.names(first), []string{"A2", "A3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate(2) = %v, want %v", got, want)
+	}
+
+	_, ok = a.Allocate(ticketTypeID, nil, 2)
+	if ok {
+		t.Error("Allocate(2) succeeded with one seat left")
+	}
+}
+
+func TestAllocateTicketTypeAndSection(t *testing.T) {
+	ticketTypeID := uuid.New()
+	sectionID := uuid.New()
+	floor := newSeatMap(ticketTypeID, uuid.New(), "....")
+	balcony := newSeatMap(ticketTypeID, sectionID, "", "", "...")
+	other := newSeatMap(uuid.New(), sectionID, "....")
+
+	eventSeats := append(append(append([]models.EventSeat{}, floor.eventSeats...), balcony.eventSeats...), other.eventSeats...)
+	stages := map[uuid.UUID]Point{floor.mapID: {}, balcony.mapID: {}, other.mapID: {}}
+	a := NewAllocator(eventSeats, stages)
+
+	seatIds, ok := a.Allocate(ticketTypeID, &sectionID, 2)
+	if !ok {
+		t.Fatal("Allocate(2) in the balcony found no seats")
+	}
+	if got, want := balcony.names(seatIds), []string{"C1", "C2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate(2) in the balcony = %v, want %v", got, want)
+	}
+
+	_, ok = a.Allocate(ticketTypeID, &sectionID, 2)
+	if ok {
+		t.Error("Allocate(2) in the balcony took seats of another section or ticket type")
+	}
+}
+
+// BenchmarkAllocate allocates parties of four on a 20,000 seat map, 100
+// rows of 200 seats with every seventh seat sold.
+func BenchmarkAllocate(b *testing.B) {
+	rows := make([]string, 100)
+	for r := range rows {
+		row := make([]byte, 200)
+		for i := range row {
+			row[i] = '.'
+			if (r+i)%7 == 0 {
+				row[i] = 'x'
+			}
+		}
+		rows[r] = string(row)
+	}
+
+	ticketTypeID := uuid.New()
+	m := newSeatMap(ticketTypeID, uuid.New(), rows...)
+	a := m.allocator()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(a.taken) > 10000 {
+			a.taken = make(map[uuid.UUID]bool)
+		}
+		if _, ok := a.Allocate(ticketTypeID, nil, 4); !ok {
+			b.Fatal("Allocate(4) found no seats")
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ticket/models"
+
+	"github.com/google/uuid"
+)
+
+// Roles seeded by the roles migration.
+const (
+	RoleAdmin     = "admin"
+	RoleOrganizer = "organizer"
+	RoleStaff     = "staff"
+	RoleCustomer  = "customer"
+)
+
+// Permissions seeded by the roles migration.
+const (
+	PermissionEventsManage        = "events.manage"
+	PermissionEventsManageAny     = "events.manage_any"
+	PermissionCatalogManage       = "catalog.manage"
+	PermissionTransactionsCreate  = "transactions.create"
+	PermissionTransactionsReadAny = "transactions.read_any"
+	PermissionTransactionsManage  = "transactions.manage"
+	PermissionTicketsCheckIn      = "tickets.check_in"
+	PermissionUsersManage         = "users.manage"
+	PermissionOrganizersManage    = "organizers.manage"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Principal is the authenticated user a service call is made for, with the
+// permissions granted by their roles and the organizers they are a member
+// of.
+type Principal struct {
+	User         *models.User `json:"user"`
+	Roles        []string     `json:"roles"`
+	Permissions  []string     `json:"permissions"`
+	OrganizerIDs []uuid.UUID  `json:"organizer_ids"`
+	granted      map[string]bool
+}
+
+func NewPrincipal(user *models.User, roles, permissions []string, organizerIds []uuid.UUID) *Principal {
+	granted := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+
+	return &Principal{
+		User:         user,
+		Roles:        roles,
+		Permissions:  permissions,
+		OrganizerIDs: organizerIds,
+		granted:      granted,
+	}
+}
+
+// Can reports whether the principal holds any of permissions.
+func (p *Principal) Can(permissions ...string) bool {
+	if p == nil {
+		return false
+	}
+	for _, permission := range permissions {
+		if p.granted[permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// Is reports whether the principal is the user with userId.
+func (p *Principal) Is(userId uuid.UUID) bool {
+	return p != nil && p.User.ID == userId
+}
+
+// actor names the principal in status history and refunds.
+func (p *Principal) actor() string {
+	return "user:" + p.User.ID.String()
+}
+
+func (p *Principal) require(permission string) error {
+	if !p.Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// requireSelfOr allows a user to act on their own records, and holders of
+// permission to act on anyone's.
+func (p *Principal) requireSelfOr(userId uuid.UUID, permission string) error {
+	if !p.Is(userId) && !p.Can(permission) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// isMember reports whether the principal is a member of organizerId.
+func (p *Principal) isMember(organizerId uuid.UUID) bool {
+	if p == nil {
+		return false
+	}
+	for _, id := range p.OrganizerIDs {
+		if id == organizerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) requireMember(organizerId uuid.UUID) error {
+	if !p.isMember(organizerId) && !p.Can(PermissionOrganizersManage) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// tenant returns the organizers whose events, locations and ticket types
+// the principal works with. It returns false instead for principals that
+// work with the data of every organizer.
+func (p *Principal) tenant() ([]uuid.UUID, bool) {
+	if p.Can(PermissionOrganizersManage) {
+		return nil, false
+	}
+	if p == nil {
+		return nil, true
+	}
+	return p.OrganizerIDs, true
+}
+
+// organizerFor picks the organizer a new event or location belongs to: the
+// requested one, or the only organizer the principal is a member of.
+func (p *Principal) organizerFor(requested *uuid.UUID) (uuid.UUID, error) {
+	if requested != nil {
+		if !p.isMember(*requested) && !p.Can(PermissionOrganizersManage) {
+			return uuid.Nil, ErrForbidden
+		}
+		return *requested, nil
+	}
+
+	if p == nil || len(p.OrganizerIDs) != 1 {
+		return uuid.Nil, fmt.Errorf("%w: organizer_id is required", ErrInvalidOrganizer)
+	}
+	return p.OrganizerIDs[0], nil
+}
+
+// ownsEvent reports whether the principal may manage event as a member of
+// its organizer or as an admin.
+func (p *Principal) ownsEvent(event *models.Event) bool {
+	if p.Can(PermissionEventsManageAny) {
+		return true
+	}
+	return p.Can(PermissionEventsManage) && p.isMember(event.OrganizerID)
+}
+
+func (p *Principal) requireEventOwner(event *models.Event) error {
+	if !p.ownsEvent(event) {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenIssuer = "go-ticket"
+	minPasswordLength = 8
+	// bcrypt ignores everything after the first 72 bytes of a password.
+	maxPasswordLength = 72
+	// unusablePassword is stored for users created without a password. It
+	// is not a bcrypt hash, so no password ever matches it.
+	unusablePassword = "-"
+	// MinJWTSecretSize is the shortest JWT_SECRET the service accepts.
+	// Access tokens are signed with HS256, so a guessable secret lets
+	// anyone sign in as anyone.
+	MinJWTSecretSize = 32
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidPassword     = errors.New("invalid password")
+	ErrInvalidAccessToken  = errors.New("invalid access token")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrWeakJWTSecret       = errors.New("JWT secret is too short")
+)
+
+// dummyPasswordHash is compared against when a login names an unknown email,
+// so the response time does not reveal which emails are registered.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("go-ticket-dummy-password"), bcrypt.DefaultCost)
+
+type AuthService struct {
+	uow              *repository.UnitOfWork
+	userRepo         *repository.UserRepository
+	refreshTokenRepo *repository.RefreshTokenRepository
+	roleRepo         *repository.RoleRepository
+	organizerRepo    *repository.OrganizerRepository
+	users            *UserService
+	secret           []byte
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+}
+
+// NewAuthService returns the auth service, signing access tokens with
+// secret, which must be at least MinJWTSecretSize bytes long.
+func NewAuthService(
+	uow *repository.UnitOfWork,
+	userRepo *repository.UserRepository,
+	refreshTokenRepo *repository.RefreshTokenRepository,
+	roleRepo *repository.RoleRepository,
+	organizerRepo *repository.OrganizerRepository,
+	users *UserService,
+	secret string,
+	accessTokenTTL time.Duration,
+	refreshTokenTTL time.Duration,
+) (*AuthService, error) {
+	if len(secret) < MinJWTSecretSize {
+		return nil, fmt.Errorf("%w: need at least %d bytes, got %d", ErrWeakJWTSecret, MinJWTSecretSize, len(secret))
+	}
+
+	return &AuthService{
+		uow:              uow,
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		roleRepo:         roleRepo,
+		organizerRepo:    organizerRepo,
+		users:            users,
+		secret:           []byte(secret),
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+	}, nil
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Phone    string `json:"phone" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type AuthResponse struct {
+	User *models.User `json:"user"`
+	TokenPair
+}
+
+// Register creates a customer and signs them in. The user and their first
+// refresh token are stored together, so a failed sign-in leaves no account
+// behind that would block registering the same email again.
+func (s *AuthService) Register(req *RegisterRequest) (*AuthResponse, error) {
+	var response *AuthResponse
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		user, err := s.users.insertUser(tx, &CreateUserRequest{
+			Name:     req.Name,
+			Email:    req.Email,
+			Phone:    req.Phone,
+			Password: req.Password,
+		})
+		if err != nil {
+			return err
+		}
+
+		response, err = s.startSession(s.refreshTokenRepo.WithTx(tx), user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (s *AuthService) Login(req *LoginRequest) (*AuthResponse, error) {
+	user, err := s.userRepo.FindByEmail(req.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !checkPassword(user.Password, req.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return s.startSession(s.refreshTokenRepo, user)
+}
+
+// Refresh rotates a refresh token: the presented token is revoked and a new
+// pair is issued in the same family. Presenting a token that was already
+// rotated means it leaked, so the whole family is revoked.
+func (s *AuthService) Refresh(req *RefreshTokenRequest) (*TokenPair, error) {
+	var pair *TokenPair
+	var reused bool
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.refreshTokenRepo.WithTx(tx)
+		now := time.Now()
+
+		current, err := repo.FindByHashForUpdate(hashRefreshToken(req.RefreshToken))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reused = true
+			return repo.RevokeFamily(current.FamilyID, now)
+		}
+		if now.After(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		user, err := s.userRepo.WithTx(tx).FindById(current.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		next, refreshToken, err := s.newRefreshToken(user.ID, current.FamilyID, now)
+		if err != nil {
+			return err
+		}
+
+		err = repo.Create(next)
+		if err != nil {
+			return err
+		}
+
+		err = repo.Revoke(current.ID, &next.ID, now)
+		if err != nil {
+			return err
+		}
+
+		pair, err = s.tokenPair(user.ID, refreshToken, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return pair, nil
+}
+
+// Logout revokes every refresh token issued from the same login as the
+// presented one. Access tokens stay valid until they expire.
+func (s *AuthService) Logout(req *RefreshTokenRequest) error {
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.refreshTokenRepo.WithTx(tx)
+
+		current, err := repo.FindByHashForUpdate(hashRefreshToken(req.RefreshToken))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return repo.RevokeFamily(current.FamilyID, time.Now())
+	})
+}
+
+// Authenticate returns the user an access token was issued to, with the
+// permissions their roles grant and the organizers they belong to now
+// rather than at login.
+func (s *AuthService) Authenticate(accessToken string) (*Principal, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(accessTokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	userId, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+
+	user, err := s.userRepo.FindById(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.FindNamesByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.roleRepo.FindPermissionsByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	organizerIds, err := s.organizerRepo.FindIdsByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrincipal(user, roles, permissions, organizerIds), nil
+}
+
+// startSession issues the first token pair of a new refresh token family,
+// storing the refresh token with repo.
+func (s *AuthService) startSession(repo *repository.RefreshTokenRepository, user *models.User) (*AuthResponse, error) {
+	now := time.Now()
+
+	token, refreshToken, err := s.newRefreshToken(user.ID, uuid.New(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.Create(token)
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := s.tokenPair(user.ID, refreshToken, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{User: user, TokenPair: *pair}, nil
+}
+
+func (s *AuthService) tokenPair(userId uuid.UUID, refreshToken string, now time.Time) (*TokenPair, error) {
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   userId.String(),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenTTL)),
+	}).SignedString(s.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newRefreshToken returns a random refresh token and the row storing its
+// hash.
+func (s *AuthService) newRefreshToken(userId, familyId uuid.UUID, now time.Time) (*models.RefreshToken, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	refreshToken := base64.RawURLEncoding.EncodeToString(b)
+
+	return &models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userId,
+		FamilyID:  familyId,
+		TokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt: now.Add(s.refreshTokenTTL),
+		CreatedAt: now,
+	}, refreshToken, nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", fmt.Errorf("%w: must be between %d and %d characters", ErrInvalidPassword, minPasswordLength, maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
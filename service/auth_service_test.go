@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"go-ticket/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestNewAuthServiceRejectsWeakSecret(t *testing.T) {
+	for _, secret := range []string{"", "secret", strings.Repeat("s", MinJWTSecretSize-1)} {
+		_, err := NewAuthService(nil, nil, nil, nil, nil, nil, secret, time.Minute, time.Hour)
+		if !errors.Is(err, ErrWeakJWTSecret) {
+			t.Errorf("NewAuthService() with a %d byte secret error = %v, want %v", len(secret), err, ErrWeakJWTSecret)
+		}
+	}
+
+	_, err := NewAuthService(nil, nil, nil, nil, nil, nil, strings.Repeat("s", MinJWTSecretSize), time.Minute, time.Hour)
+	if err != nil {
+		t.Errorf("NewAuthService() with a %d byte secret error = %v", MinJWTSecretSize, err)
+	}
+}
+
+func newTestAuthService(t *testing.T, db *sqlx.DB) *AuthService {
+	t.Helper()
+
+	uow := repository.NewUnitOfWork(db)
+	userRepo := repository.NewUserRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	auth, err := NewAuthService(
+		uow, userRepo, repository.NewRefreshTokenRepository(db), roleRepo, repository.NewOrganizerRepository(db),
+		NewUserService(uow, userRepo, roleRepo), strings.Repeat("j", MinJWTSecretSize), time.Minute, time.Hour,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	db := openTestDB(t)
+	auth := newTestAuthService(t, db)
+
+	session, err := auth.Register(&RegisterRequest{
+		Name:     "Buyer",
+		Email:    "rotate@example.com",
+		Phone:    "+628111000100",
+		Password: "correct horse",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := session.RefreshToken
+	pair, err := auth.Refresh(&RefreshTokenRequest{RefreshToken: first})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.RefreshToken == first {
+		t.Fatal("Refresh() returned the refresh token it was given")
+	}
+	if _, err := auth.Authenticate(pair.AccessToken); err != nil {
+		t.Errorf("Authenticate() of the rotated access token error = %v", err)
+	}
+
+	second, err := auth.Refresh(&RefreshTokenRequest{RefreshToken: pair.RefreshToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Presenting a rotated token again means it leaked: it is refused and
+	// the tokens issued from it are revoked too
+	if _, err := auth.Refresh(&RefreshTokenRequest{RefreshToken: first}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with a rotated token error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := auth.Refresh(&RefreshTokenRequest{RefreshToken: second.RefreshToken}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() with the latest token of a reused family error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+
+	// Another login starts a family of its own
+	login, err := auth.Login(&LoginRequest{Email: "rotate@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(&RefreshTokenRequest{RefreshToken: login.RefreshToken}); err != nil {
+		t.Errorf("Refresh() of a new login error = %v", err)
+	}
+}
+
+func TestRegisterTakenEmail(t *testing.T) {
+	db := openTestDB(t)
+	auth := newTestAuthService(t, db)
+
+	req := &RegisterRequest{Name: "Buyer", Email: "taken@example.com", Phone: "+628111000101", Password: "correct horse"}
+	if _, err := auth.Register(req); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Phone = "+628111000102"
+	if _, err := auth.Register(req); !errors.Is(err, ErrEmailRegistered) {
+		t.Errorf("Register() with a taken email error = %v, want %v", err, ErrEmailRegistered)
+	}
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"go-ticket/ticketing"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// maxOfflineScans bounds how many scans one batch upload may carry.
+const maxOfflineScans = 500
+
+var ErrInvalidCheckIn = errors.New("invalid check-in")
+
+type CheckInService struct {
+	uow       *repository.UnitOfWork
+	repo      *repository.TicketRepository
+	scanRepo  *repository.TicketScanRepository
+	eventRepo *repository.EventRepository
+	signer    *ticketing.Signer
+}
+
+func NewCheckInService(
+	uow *repository.UnitOfWork,
+	repo *repository.TicketRepository,
+	scanRepo *repository.TicketScanRepository,
+	eventRepo *repository.EventRepository,
+	signer *ticketing.Signer,
+) *CheckInService {
+	return &CheckInService{
+		uow:       uow,
+		repo:      repo,
+		scanRepo:  scanRepo,
+		eventRepo: eventRepo,
+		signer:    signer,
+	}
+}
+
+// CheckInRequest takes either the code printed on a ticket or the signed
+// token scanned from its QR code.
+type CheckInRequest struct {
+	Code string `json:"code" validate:"required"`
+	Gate string `json:"gate" validate:"required"`
+}
+
+// CheckInResponse carries the scanned ticket for valid, already used and
+// voided scans. An already used ticket keeps the time and gate of its first
+// scan in used_at and used_gate.
+type CheckInResponse struct {
+	Result models.ScanResult `json:"result"`
+	Ticket *models.Ticket    `json:"ticket,omitempty"`
+}
+
+type OfflineScanRequest struct {
+	ID        uuid.UUID `json:"id" validate:"required"`
+	Token     string    `json:"token" validate:"required"`
+	Gate      string    `json:"gate"`
+	ScannedAt time.Time `json:"scanned_at" validate:"required"`
+}
+
+// ReconcileScansRequest uploads the scans an offline scanner accepted. Gate
+// applies to every scan that does not name its own.
+type ReconcileScansRequest struct {
+	DeviceID string               `json:"device_id" validate:"required"`
+	Gate     string               `json:"gate"`
+	Scans    []OfflineScanRequest `json:"scans" validate:"required,dive"`
+}
+
+// CheckIn admits a ticket to the event. Only the first scan of a valid
+// ticket is accepted; every other scan is classified without changing the
+// ticket. Every scan is recorded.
+func (s *CheckInService) CheckIn(p *Principal, eventId uuid.UUID, req *CheckInRequest) (*CheckInResponse, error) {
+	code := strings.TrimSpace(req.Code)
+	if code == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrInvalidCheckIn)
+	}
+	if req.Gate == "" {
+		return nil, fmt.Errorf("%w: gate is required", ErrInvalidCheckIn)
+	}
+
+	err := s.requireGateAccess(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *CheckInResponse
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		now := time.Now()
+
+		res, err = s.checkIn(tx, eventId, code, req.Gate, now)
+		if err != nil {
+			return err
+		}
+
+		scan := &models.TicketScan{
+			ID:        uuid.New(),
+			EventID:   eventId,
+			Gate:      req.Gate,
+			Source:    models.ScanSourceOnline,
+			Result:    res.Result,
+			ScannedAt: now,
+			CreatedAt: now,
+		}
+		if res.Ticket != nil {
+			scan.TicketID = &res.Ticket.ID
+		}
+
+		_, err = s.scanRepo.WithTx(tx).Create(scan)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *CheckInService) checkIn(tx *sqlx.Tx, eventId uuid.UUID, code, gate string, now time.Time) (*CheckInResponse, error) {
+	repo := s.repo.WithTx(tx)
+
+	if strings.Contains(code, ".") {
+		claims, err := s.signer.Verify(code, now)
+		if err != nil {
+			return &CheckInResponse{Result: models.ScanResultInvalidToken}, nil
+		}
+
+		ticket, err := repo.FindById(claims.TicketID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &CheckInResponse{Result: models.ScanResultNotFound}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		code = ticket.Code
+	}
+
+	code = strings.ToUpper(code)
+	ticket, err := repo.CheckIn(eventId, code, gate, now)
+	if err == nil {
+		return &CheckInResponse{Result: models.ScanResultValid, Ticket: ticket}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	ticket, err = repo.FindByCode(code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &CheckInResponse{Result: models.ScanResultNotFound}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ticket.EventID != eventId:
+		return &CheckInResponse{Result: models.ScanResultWrongEvent}, nil
+	case ticket.Status == models.TicketStatusVoid:
+		return &CheckInResponse{Result: models.ScanResultVoided, Ticket: ticket}, nil
+	case ticket.Status == models.TicketStatusUsed:
+		return &CheckInResponse{Result: models.ScanResultAlreadyUsed, Ticket: ticket}, nil
+	default:
+		return nil, fmt.Errorf("ticket %s could not be checked in", ticket.ID)
+	}
+}
+
+// ReconcileScans records the scans of an offline scanner after the fact.
+// Scans are applied oldest first, so the earliest scan of a ticket is the
+// admission and every other scan of it is flagged as already_used, even
+// when the admission was recorded before the upload. Uploading the same
+// scan again returns its recorded result.
+func (s *CheckInService) ReconcileScans(p *Principal, eventId uuid.UUID, req *ReconcileScansRequest) ([]models.TicketScan, error) {
+	if req.DeviceID == "" {
+		return nil, fmt.Errorf("%w: device_id is required", ErrInvalidCheckIn)
+	}
+	if len(req.Scans) == 0 {
+		return nil, fmt.Errorf("%w: at least one scan is required", ErrInvalidCheckIn)
+	}
+	if len(req.Scans) > maxOfflineScans {
+		return nil, fmt.Errorf("%w: at most %d scans per upload", ErrInvalidCheckIn, maxOfflineScans)
+	}
+
+	for _, scan := range req.Scans {
+		if scan.ID == uuid.Nil || scan.Token == "" || scan.ScannedAt.IsZero() {
+			return nil, fmt.Errorf("%w: every scan needs an id, token and scanned_at", ErrInvalidCheckIn)
+		}
+		if scan.Gate == "" && req.Gate == "" {
+			return nil, fmt.Errorf("%w: gate is required for scan %s", ErrInvalidCheckIn, scan.ID)
+		}
+	}
+
+	err := s.requireGateAccess(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	offline := make([]OfflineScanRequest, len(req.Scans))
+	copy(offline, req.Scans)
+	sort.SliceStable(offline, func(i, j int) bool {
+		return offline[i].ScannedAt.Before(offline[j].ScannedAt)
+	})
+
+	scans := make([]models.TicketScan, 0, len(offline))
+	for _, o := range offline {
+		gate := o.Gate
+		if gate == "" {
+			gate = req.Gate
+		}
+
+		var scan *models.TicketScan
+		err = s.uow.Do(func(tx *sqlx.Tx) error {
+			scan, err = s.reconcile(tx, eventId, req.DeviceID, gate, &o)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		scans = append(scans, *scan)
+	}
+
+	return scans, nil
+}
+
+func (s *CheckInService) reconcile(tx *sqlx.Tx, eventId uuid.UUID, deviceId, gate string, o *OfflineScanRequest) (*models.TicketScan, error) {
+	scanRepo := s.scanRepo.WithTx(tx)
+
+	existing, err := scanRepo.FindById(o.ID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	scan := &models.TicketScan{
+		ID:        o.ID,
+		EventID:   eventId,
+		Gate:      gate,
+		DeviceID:  &deviceId,
+		Source:    models.ScanSourceOffline,
+		ScannedAt: o.ScannedAt,
+		CreatedAt: time.Now(),
+	}
+
+	scan.Result, err = s.admitOffline(tx, eventId, gate, o, scan)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := scanRepo.Create(scan)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, fmt.Errorf("scan %s was uploaded concurrently", o.ID)
+	}
+
+	return scan, nil
+}
+
+// admitOffline applies an offline scan to its ticket and sets the ticket
+// on scan once the ticket is known.
+func (s *CheckInService) admitOffline(tx *sqlx.Tx, eventId uuid.UUID, gate string, o *OfflineScanRequest, scan *models.TicketScan) (models.ScanResult, error) {
+	repo := s.repo.WithTx(tx)
+
+	claims, err := s.signer.Verify(o.Token, o.ScannedAt)
+	if err != nil {
+		return models.ScanResultInvalidToken, nil
+	}
+
+	ticket, err := repo.FindByIdForUpdate(claims.TicketID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ScanResultNotFound, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	scan.TicketID = &ticket.ID
+
+	switch {
+	case ticket.EventID != eventId:
+		return models.ScanResultWrongEvent, nil
+	case ticket.Status == models.TicketStatusVoid:
+		return models.ScanResultVoided, nil
+	case ticket.Status == models.TicketStatusUsed && ticket.UsedAt != nil && !o.ScannedAt.Before(*ticket.UsedAt):
+		return models.ScanResultAlreadyUsed, nil
+	}
+
+	// The ticket is unused, or its recorded admission came after this scan.
+	err = s.scanRepo.WithTx(tx).FlagAdmissions(ticket.ID)
+	if err != nil {
+		return "", err
+	}
+
+	err = repo.MarkUsed(ticket.ID, gate, o.ScannedAt)
+	if err != nil {
+		return "", err
+	}
+
+	return models.ScanResultValid, nil
+}
+
+func (s *CheckInService) GetScans(p *Principal, eventId uuid.UUID, q *repository.ListQuery) (*repository.Page[models.TicketScan], error) {
+	result := models.ScanResult(q.Filters["result"])
+	if result != "" && !result.Valid() {
+		return nil, fmt.Errorf("%w: unknown scan result %s", ErrInvalidCheckIn, result)
+	}
+
+	err := s.requireGateAccess(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.scanRepo.ListByEventId(eventId, q)
+}
+
+// GetScannerKeys returns the public keys offline scanners use to verify the
+// ticket tokens of an event.
+func (s *CheckInService) GetScannerKeys(p *Principal, eventId uuid.UUID) ([]ticketing.ScannerKey, error) {
+	err := s.requireGateAccess(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return []ticketing.ScannerKey{s.signer.ScannerKey(eventId)}, nil
+}
+
+func (s *CheckInService) GetCheckInCounts(p *Principal, eventId uuid.UUID) ([]models.TicketTypeCheckIn, error) {
+	err := s.requireGateAccess(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CountCheckInsByEventId(eventId)
+}
+
+// requireGateAccess allows gate staff and admins to work the gates of every
+// event, and organizers the gates of their own events.
+func (s *CheckInService) requireGateAccess(p *Principal, eventId uuid.UUID) error {
+	event, err := s.eventRepo.FindById(eventId)
+	if err != nil {
+		return err
+	}
+
+	if !p.Can(PermissionTicketsCheckIn) && !p.ownsEvent(event) {
+		return ErrForbidden
+	}
+	return nil
+}
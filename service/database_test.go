@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/payment"
+	"go-ticket/repository"
+	"go-ticket/ticketing"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the PostgreSQL database named by
+// TEST_DATABASE_URL and loads database/schema.sql into a schema of its
+// own, dropped when the test ends. Tests that need a database are skipped
+// without one.
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	// lib/pq passes parameters it does not know on to the server, so every
+	// connection of the pool starts in the schema
+	searchPath := "search_path=" + schema + ",public"
+	switch {
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + searchPath
+	default:
+		dsn += " " + searchPath
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schemaSQL, err := os.ReadFile("../database/schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(string(schemaSQL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// testServices wires the services a checkout goes through to db, with the
+// fake payment gateway reporting its charges to the transaction service.
+type testServices struct {
+	transactions *TransactionService
+	reservations *ReservationService
+	refunds      *RefundService
+	promoCodes   *PromoCodeService
+	ticketTypes  *TicketTypeService
+	gateway      *payment.FakeGateway
+}
+
+func newTestServices(t *testing.T, db *sqlx.DB) *testServices {
+	t.Helper()
+
+	uow := repository.NewUnitOfWork(db)
+	eventRepo := repository.NewEventRepository(db)
+	locationRepo := repository.NewLocationRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	ticketTypeRepo := repository.NewTicketTypeRepository(db)
+	transactionRepo := repository.NewTransactionRepository(db)
+	detailRepo := repository.NewTransactionDetailRepository(db)
+	historyRepo := repository.NewTransactionStatusHistoryRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
+	ticketRepo := repository.NewTicketRepository(db)
+	seatMapRepo := repository.NewSeatMapRepository(db)
+	eventSeatRepo := repository.NewEventSeatRepository(db)
+	promoCodeRepo := repository.NewPromoCodeRepository(db)
+	pricePhaseRepo := repository.NewPricePhaseRepository(db)
+	gateway := payment.NewFakeGateway("http://localhost:8000")
+
+	reservations := NewReservationService(
+		uow, transactionRepo, detailRepo, ticketTypeRepo, eventSeatRepo, promoCodeRepo, historyRepo, 15*time.Minute,
+	)
+	promoCodes := NewPromoCodeService(uow, promoCodeRepo)
+	seats := NewSeatMapService(uow, seatMapRepo, eventSeatRepo, locationRepo, eventRepo, ticketTypeRepo)
+	signer, err := ticketing.NewSigner(strings.Repeat("s", ticketing.MinSecretSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickets := NewTicketService(ticketRepo, transactionRepo, detailRepo, userRepo, eventRepo, ticketTypeRepo, eventSeatRepo, signer)
+
+	transactions := NewTransactionService(
+		uow, transactionRepo, detailRepo, eventRepo, ticketTypeRepo, eventSeatRepo, pricePhaseRepo,
+		historyRepo, refundRepo, userRepo, reservations, tickets, seats, promoCodes, gateway,
+	)
+	gateway.OnEvent(transactions.HandlePaymentEvent)
+
+	return &testServices{
+		transactions: transactions,
+		reservations: reservations,
+		refunds: NewRefundService(
+			uow, refundRepo, transactionRepo, detailRepo, eventRepo, ticketTypeRepo, historyRepo, tickets, gateway,
+		),
+		promoCodes:  promoCodes,
+		ticketTypes: NewTicketTypeService(uow, ticketTypeRepo, eventRepo, eventSeatRepo, pricePhaseRepo),
+		gateway:     gateway,
+	}
+}
+
+// saleFixture is an event on sale with one ticket type and a fixed promo
+// code of its organizer.
+type saleFixture struct {
+	organizerID  uuid.UUID
+	eventID      uuid.UUID
+	ticketTypeID uuid.UUID
+	promoCode    string
+}
+
+func seedSale(t *testing.T, db *sqlx.DB, quota int) saleFixture {
+	t.Helper()
+
+	f := saleFixture{
+		organizerID:  uuid.New(),
+		eventID:      uuid.New(),
+		ticketTypeID: uuid.New(),
+		promoCode:    "SAVE10",
+	}
+	locationId := uuid.New()
+	scheduleId := uuid.New()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO organizers (id, name) VALUES ($1, 'Organizer')`, []interface{}{f.organizerID}},
+		{`INSERT INTO locations (id, name, address, city, country, organizer_id)
+			VALUES ($1, 'Hall', 'Jl. Sudirman 1', 'Jakarta', 'Indonesia', $2)`, []interface{}{locationId, f.organizerID}},
+		{`INSERT INTO schedules (id, title, start_date, end_date)
+			VALUES ($1, 'Show', NOW() + INTERVAL '30 days', NOW() + INTERVAL '30 days 3 hours')`, []interface{}{scheduleId}},
+		{`INSERT INTO events (id, name, description, location_id, schedule_id, organizer_id, status)
+			VALUES ($1, 'Concert', '', $2, $3, $4, 'on_sale')`, []interface{}{f.eventID, locationId, scheduleId, f.organizerID}},
+		{`INSERT INTO ticket_types (id, event_id, name, description, price, currency, quota, remaining_quota, organizer_id)
+			VALUES ($1, $2, 'Regular', '', 100000, 'IDR', $3, $3, $4)`, []interface{}{f.ticketTypeID, f.eventID, quota, f.organizerID}},
+		{`INSERT INTO promo_codes (organizer_id, code, kind, amount_off, currency)
+			VALUES ($1, $2, 'fixed', 10000, 'IDR')`, []interface{}{f.organizerID, f.promoCode}},
+	}
+	for _, statement := range statements {
+		_, err := db.Exec(statement.query, statement.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return f
+}
+
+// seedBuyer creates a user and returns them as a principal allowed to buy
+// tickets.
+func seedBuyer(t *testing.T, db *sqlx.DB) *Principal {
+	t.Helper()
+
+	user := &models.User{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Fullname:  "Buyer",
+	}
+	user.Email = fmt.Sprintf("buyer-%s@example.com", user.ID)
+
+	_, err := db.Exec(`INSERT INTO users (id, fullname, email, password) VALUES ($1, $2, $3, 'x')`,
+		user.ID, user.Fullname, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewPrincipal(user, []string{"user"}, []string{PermissionTransactionsCreate}, nil)
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrEventJobActive = errors.New("event already has a job in progress")
+
+// errNotPaid tells a cancel job that a transaction has no payment to refund.
+var errNotPaid = errors.New("transaction is not paid")
+
+const (
+	// eventJobBatchSize is how many transactions a job works through before
+	// saving its progress.
+	eventJobBatchSize = 50
+	// eventJobLease is how long a worker may run a batch before another
+	// worker assumes it died and takes the job over.
+	eventJobLease = 5 * time.Minute
+)
+
+// EventJobService cancels and postpones events. The event changes at once,
+// while refunds and notifications for its transactions are left to a job
+// the background worker runs in batches.
+type EventJobService struct {
+	uow             *repository.UnitOfWork
+	repo            *repository.EventJobRepository
+	eventRepo       *repository.EventRepository
+	scheduleRepo    *repository.ScheduleRepository
+	transactionRepo *repository.TransactionRepository
+	userRepo        *repository.UserRepository
+	refunds         *RefundService
+	reservations    *ReservationService
+	notifications   *NotificationService
+}
+
+func NewEventJobService(
+	uow *repository.UnitOfWork,
+	repo *repository.EventJobRepository,
+	eventRepo *repository.EventRepository,
+	scheduleRepo *repository.ScheduleRepository,
+	transactionRepo *repository.TransactionRepository,
+	userRepo *repository.UserRepository,
+	refunds *RefundService,
+	reservations *ReservationService,
+	notifications *NotificationService,
+) *EventJobService {
+	return &EventJobService{
+		uow:             uow,
+		repo:            repo,
+		eventRepo:       eventRepo,
+		scheduleRepo:    scheduleRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+		refunds:         refunds,
+		reservations:    reservations,
+		notifications:   notifications,
+	}
+}
+
+type CancelEventRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// PostponeEventRequest moves an event to another schedule. Buyers keep
+// their tickets unless they ask for a refund before RefundDeadline.
+type PostponeEventRequest struct {
+	ScheduleID     uuid.UUID `json:"schedule_id" validate:"required"`
+	RefundDeadline time.Time `json:"refund_deadline" validate:"required"`
+	Reason         string    `json:"reason" validate:"required"`
+}
+
+func (s *EventJobService) GetJobsByEventId(p *Principal, eventId uuid.UUID) ([]models.EventJob, error) {
+	event, err := s.eventRepo.FindById(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireEventOwner(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindByEventId(eventId)
+}
+
+// CancelEvent cancels an event and starts a job that releases the holds and
+// refunds the payments of all its transactions, voiding their tickets.
+// Cancelling an event that is already cancelled starts a new job, which
+// retries the transactions an earlier job could not refund.
+func (s *EventJobService) CancelEvent(p *Principal, eventId uuid.UUID, req *CancelEventRequest) (*models.EventJob, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidEvent)
+	}
+
+	var job *models.EventJob
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		event, err := eventRepo.FindByIdForUpdate(eventId)
+		if err != nil {
+			return err
+		}
+
+		err = p.requireEventOwner(event)
+		if err != nil {
+			return err
+		}
+
+		if event.Status != models.EventStatusCancelled {
+			if !canTransition(eventTransitions, event.Status, models.EventStatusCancelled) {
+				return fmt.Errorf("%w: event cannot move from %s to %s", ErrInvalidStatusTransition, event.Status, models.EventStatusCancelled)
+			}
+
+			event.Status = models.EventStatusCancelled
+			event.UpdatedAt = time.Now()
+			err = eventRepo.UpdateStatus(event)
+			if err != nil {
+				return err
+			}
+		}
+
+		job, err = s.start(tx, event, models.EventJobKindCancel, req.Reason, p.actor())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// PostponeEvent moves an event to another schedule and starts a job that
+// tells its buyers about the new date and the refund deadline.
+func (s *EventJobService) PostponeEvent(p *Principal, eventId uuid.UUID, req *PostponeEventRequest) (*models.EventJob, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidEvent)
+	}
+	if !req.RefundDeadline.After(time.Now()) {
+		return nil, fmt.Errorf("%w: refund deadline must be in the future", ErrInvalidEvent)
+	}
+
+	_, err = s.scheduleRepo.FindById(req.ScheduleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: schedule %s does not exist", ErrInvalidEvent, req.ScheduleID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job *models.EventJob
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		event, err := eventRepo.FindByIdForUpdate(eventId)
+		if err != nil {
+			return err
+		}
+
+		err = p.requireEventOwner(event)
+		if err != nil {
+			return err
+		}
+
+		// A postponed event may be postponed again to yet another date
+		if event.Status != models.EventStatusPostponed &&
+			!canTransition(eventTransitions, event.Status, models.EventStatusPostponed) {
+			return fmt.Errorf("%w: event cannot move from %s to %s", ErrInvalidStatusTransition, event.Status, models.EventStatusPostponed)
+		}
+
+		deadline := req.RefundDeadline
+		event.Status = models.EventStatusPostponed
+		event.ScheduleID = req.ScheduleID
+		event.RefundDeadline = &deadline
+		event.UpdatedAt = time.Now()
+		err = eventRepo.Reschedule(event)
+		if err != nil {
+			return err
+		}
+
+		job, err = s.start(tx, event, models.EventJobKindPostpone, req.Reason, p.actor())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// start queues a job over every transaction of event. The event row must be
+// locked, which keeps two jobs from starting for it at once.
+func (s *EventJobService) start(tx *sqlx.Tx, event *models.Event, kind models.EventJobKind, reason, actor string) (*models.EventJob, error) {
+	repo := s.repo.WithTx(tx)
+
+	active, err := repo.HasActive(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	if active {
+		return nil, ErrEventJobActive
+	}
+
+	total, err := s.transactionRepo.WithTx(tx).CountByEventId(event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.EventJob{
+		ID:        uuid.New(),
+		EventID:   event.ID,
+		Kind:      kind,
+		Status:    models.EventJobStatusPending,
+		Reason:    reason,
+		Actor:     actor,
+		Total:     total,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	err = repo.Create(job)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// StartWorker runs pending event jobs every interval until ctx is done.
+func (s *EventJobService) StartWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.RunPending(ctx)
+			if err != nil {
+				log.Printf("Failed to run event jobs: %v", err)
+			}
+		}
+	}
+}
+
+// RunPending runs batches of pending jobs until none is left or ctx is
+// done. Progress is saved after every batch.
+func (s *EventJobService) RunPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		now := time.Now()
+		job, err := s.repo.Claim(now, now.Add(eventJobLease))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = s.runBatch(job)
+		if err != nil {
+			return err
+		}
+
+		if job.Status == models.EventJobStatusCompleted {
+			log.Printf("Completed %s job %s of event %s: %d of %d transactions processed, %d failed",
+				job.Kind, job.ID, job.EventID, job.Processed, job.Total, job.Failed)
+		}
+	}
+
+	return nil
+}
+
+// runBatch processes the next transactions of job, each in its own database
+// transaction so a failed refund does not hold back the others.
+func (s *EventJobService) runBatch(job *models.EventJob) error {
+	event, err := s.eventRepo.FindWithRelations(job.EventID)
+	if err != nil {
+		return err
+	}
+
+	ids, err := s.transactionRepo.FindIdsByEventIdAfter(job.EventID, job.LastTransactionID, eventJobBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		err := s.process(job, event, id)
+		if err != nil {
+			message := fmt.Sprintf("transaction %s: %v", id, err)
+			log.Printf("Event job %s failed on %s", job.ID, message)
+			job.Failed++
+			job.LastError = &message
+		}
+
+		job.Processed++
+		job.LastTransactionID = &id
+	}
+
+	if len(ids) < eventJobBatchSize {
+		completedAt := time.Now()
+		job.Status = models.EventJobStatusCompleted
+		job.CompletedAt = &completedAt
+	}
+
+	return s.repo.SaveProgress(job)
+}
+
+// process applies job to a single transaction and notifies its buyer.
+// Transactions with nothing left to do are skipped, which makes a batch
+// safe to run again after a worker died before saving its progress.
+//
+// Paid transactions of a cancelled event are refunded through the payment
+// provider, which must not be called inside a database transaction, so
+// they are handled apart from the others.
+func (s *EventJobService) process(job *models.EventJob, event *models.Event, transactionId uuid.UUID) error {
+	if job.Kind == models.EventJobKindCancel {
+		_, err := s.refunds.refund(transactionId, refundRequest{
+			reason: job.Reason,
+			actor:  job.Actor,
+			check: func(tx *sqlx.Tx, transaction *models.Transaction) error {
+				if !isPaid(transaction.PaymentStatus) {
+					return errNotPaid
+				}
+				return nil
+			},
+			done: func(tx *sqlx.Tx, transaction *models.Transaction, refund *models.Refund) error {
+				subject := fmt.Sprintf("%s has been cancelled", event.Name)
+				body := fmt.Sprintf("%s has been cancelled: %s\n\nYour tickets are no longer valid and %s %s has been refunded to you.",
+					event.Name, job.Reason, refund.Amount.Currency, refund.Amount)
+				return s.notify(tx, transaction, subject, body)
+			},
+		})
+		if !errors.Is(err, errNotPaid) {
+			return err
+		}
+	}
+
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		transaction, err := s.transactionRepo.WithTx(tx).FindByIdForUpdate(transactionId)
+		if err != nil {
+			return err
+		}
+
+		var subject, body string
+		switch {
+		case job.Kind == models.EventJobKindCancel && transaction.Status == models.TransactionStatusPending:
+			err = s.reservations.release(tx, transaction, job.Actor, job.Reason)
+			if err != nil {
+				return err
+			}
+			subject = fmt.Sprintf("%s has been cancelled", event.Name)
+			body = fmt.Sprintf("%s has been cancelled: %s\n\nYour unpaid order has been cancelled.", event.Name, job.Reason)
+
+		case job.Kind == models.EventJobKindPostpone && isPaid(transaction.PaymentStatus):
+			subject = fmt.Sprintf("%s has been postponed", event.Name)
+			body = fmt.Sprintf("%s has been postponed: %s\n\nYour tickets remain valid", event.Name, job.Reason)
+			if event.Schedule != nil {
+				body += fmt.Sprintf(" for the new date, %s", event.Schedule.StartDate.Format(time.RFC1123))
+			}
+			body += "."
+			if event.RefundDeadline != nil {
+				body += fmt.Sprintf(" If you cannot attend, you can ask for a refund until %s.", event.RefundDeadline.Format(time.RFC1123))
+			}
+
+		default:
+			return nil
+		}
+
+		return s.notify(tx, transaction, subject, body)
+	})
+}
+
+// notify queues a message to the buyer of transaction.
+func (s *EventJobService) notify(tx *sqlx.Tx, transaction *models.Transaction, subject, body string) error {
+	user, err := s.userRepo.WithTx(tx).FindById(transaction.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.notifications.enqueue(tx, user, subject, body)
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"go-ticket/models"
 	"go-ticket/repository"
 	"time"
@@ -9,68 +11,158 @@ import (
 	"github.com/google/uuid"
 )
 
+var ErrInvalidEvent = errors.New("invalid event")
+
+// eventTransitions lists the statuses an event may move to from each
+// status. Cancelled events stay cancelled.
+var eventTransitions = map[models.EventStatus][]models.EventStatus{
+	models.EventStatusDraft:     {models.EventStatusPublished, models.EventStatusCancelled},
+	models.EventStatusPublished: {models.EventStatusDraft, models.EventStatusOnSale, models.EventStatusPostponed, models.EventStatusCancelled},
+	models.EventStatusOnSale:    {models.EventStatusPublished, models.EventStatusSoldOut, models.EventStatusPostponed, models.EventStatusCancelled},
+	models.EventStatusSoldOut:   {models.EventStatusOnSale, models.EventStatusPostponed, models.EventStatusCancelled},
+	models.EventStatusPostponed: {models.EventStatusPublished, models.EventStatusOnSale, models.EventStatusCancelled},
+}
+
 type EventService struct {
-	repo *repository.EventRepository
+	repo         *repository.EventRepository
+	locationRepo *repository.LocationRepository
 }
 
-func NewEventService(repo *repository.EventRepository) *EventService {
+func NewEventService(repo *repository.EventRepository, locationRepo *repository.LocationRepository) *EventService {
 	return &EventService{
-		repo: repo,
+		repo:         repo,
+		locationRepo: locationRepo,
 	}
 }
 
+// CreateEventRequest may leave OrganizerID out when the caller is a member
+// of a single organizer.
 type CreateEventRequest struct {
-	Name        string    `json:"name" validate:"required"`
-	Description string    `json:"description"`
-	LocationID  uuid.UUID `json:"location_id" validate:"required"`
-	ScheduleID  uuid.UUID `json:"schedule_id" validate:"required"`
+	OrganizerID *uuid.UUID `json:"organizer_id"`
+	Name        string     `json:"name" validate:"required"`
+	Description string     `json:"description"`
+	LocationID  uuid.UUID  `json:"location_id" validate:"required"`
+	ScheduleID  uuid.UUID  `json:"schedule_id" validate:"required"`
+	models.PurchaseLimits
+	LimitByContact bool `json:"limit_by_contact"`
 }
 
+// UpdateEventRequest keeps the purchase limits it leaves out. A purchase
+// limit of 0 removes that limit.
 type UpdateEventRequest struct {
 	Name        string    `json:"name" validate:"required"`
 	Description string    `json:"description"`
 	LocationID  uuid.UUID `json:"location_id" validate:"required"`
 	ScheduleID  uuid.UUID `json:"schedule_id" validate:"required"`
+	models.PurchaseLimits
+	LimitByContact *bool `json:"limit_by_contact"`
 }
 
-func (s *EventService) GetAllEvents() ([]models.Event, error) {
-	return s.repo.FindAllWithRelations()
+// GetAllEvents lists the events the public sees. Organizers also see every
+// event of their own organizers, and admins see every event.
+func (s *EventService) GetAllEvents(p *Principal, q *repository.ListQuery) (*repository.Page[models.Event], error) {
+	if p.Can(PermissionEventsManageAny) {
+		return s.repo.List(q)
+	}
+
+	var organizerIds []uuid.UUID
+	if p.Can(PermissionEventsManage) {
+		organizerIds = p.OrganizerIDs
+	}
+	return s.repo.ListListed(q, organizerIds)
 }
 
-func (s *EventService) GetEventById(id uuid.UUID) (*models.Event, error) {
+// GetEventById hides events that are not listed from everyone but the
+// organizers who own them.
+func (s *EventService) GetEventById(p *Principal, id uuid.UUID) (*models.Event, error) {
 	event, err := s.repo.FindWithRelations(id)
 	if err != nil {
 		return nil, err
 	}
-	if event == nil {
-		return nil, errors.New("event not found")
+	if !event.Status.Listed() && !p.ownsEvent(event) {
+		return nil, sql.ErrNoRows
 	}
 	return event, nil
 }
 
-func (s *EventService) CreateEvent(req *CreateEventRequest) (*models.Event, error) {
+// CreateEvent creates an event of one of the principal's organizers, at a
+// location of the same organizer.
+func (s *EventService) CreateEvent(p *Principal, req *CreateEventRequest) (*models.Event, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	organizerId, err := p.organizerFor(req.OrganizerID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireLocation(organizerId, req.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePurchaseLimits(req.PurchaseLimits)
+	if err != nil {
+		return nil, err
+	}
+
 	event := &models.Event{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		},
-		Name:        req.Name,
-		Description: req.Description,
-		LocationID:  req.LocationID,
-		ScheduleID:  req.ScheduleID,
+		Name:           req.Name,
+		Description:    req.Description,
+		LocationID:     req.LocationID,
+		ScheduleID:     req.ScheduleID,
+		OrganizerID:    organizerId,
+		Status:         models.EventStatusDraft,
+		PurchaseLimits: req.PurchaseLimits,
+		LimitByContact: req.LimitByContact,
 	}
 
-	err := s.repo.Create(event)
+	err = s.repo.Create(event)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetEventById(event.ID)
+	return s.repo.FindWithRelations(event.ID)
 }
 
-func (s *EventService) UpdateEvent(id uuid.UUID, req *UpdateEventRequest) (*models.Event, error) {
-	event, err := s.GetEventById(id)
+func (s *EventService) UpdateEvent(p *Principal, id uuid.UUID, req *UpdateEventRequest) (*models.Event, error) {
+	repo := s.tenantRepo(p)
+
+	event, err := repo.FindWithRelations(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireEventOwner(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.LocationID != event.LocationID {
+		err = s.requireLocation(event.OrganizerID, req.LocationID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.MaxPerTransaction != nil {
+		event.MaxPerTransaction = limitOrNone(req.MaxPerTransaction)
+	}
+	if req.MaxPerUser != nil {
+		event.MaxPerUser = limitOrNone(req.MaxPerUser)
+	}
+	if req.LimitByContact != nil {
+		event.LimitByContact = *req.LimitByContact
+	}
+
+	err = validatePurchaseLimits(event.PurchaseLimits)
 	if err != nil {
 		return nil, err
 	}
@@ -80,19 +172,85 @@ func (s *EventService) UpdateEvent(id uuid.UUID, req *UpdateEventRequest) (*mode
 	event.LocationID = req.LocationID
 	event.ScheduleID = req.ScheduleID
 
-	err = s.repo.Update(event)
+	err = repo.Update(event)
 	if err != nil {
 		return nil, err
 	}
 
-	return s.GetEventById(id)
+	return s.repo.FindWithRelations(id)
+}
+
+type ChangeEventStatusRequest struct {
+	Status models.EventStatus `json:"status" validate:"required"`
 }
 
-func (s *EventService) DeleteEvent(id uuid.UUID) error {
-	event, err := s.GetEventById(id)
+// ChangeEventStatus moves an event through its lifecycle. Only events on
+// sale accept purchases. Cancelling and postponing go through
+// EventJobService instead, as they also refund and notify buyers.
+func (s *EventService) ChangeEventStatus(p *Principal, id uuid.UUID, req *ChangeEventStatusRequest) (*models.Event, error) {
+	repo := s.tenantRepo(p)
+
+	event, err := repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireEventOwner(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Status.Valid() {
+		return nil, fmt.Errorf("%w: unknown event status %s", ErrInvalidStatus, req.Status)
+	}
+	if req.Status == models.EventStatusCancelled || req.Status == models.EventStatusPostponed {
+		return nil, fmt.Errorf("%w: events are %s through their own endpoint, which also takes care of their buyers", ErrInvalidStatusTransition, req.Status)
+	}
+	if !canTransition(eventTransitions, event.Status, req.Status) {
+		return nil, fmt.Errorf("%w: event cannot move from %s to %s", ErrInvalidStatusTransition, event.Status, req.Status)
+	}
+
+	event.Status = req.Status
+	event.UpdatedAt = time.Now()
+
+	err = repo.UpdateStatus(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindWithRelations(id)
+}
+
+func (s *EventService) DeleteEvent(p *Principal, id uuid.UUID) error {
+	repo := s.tenantRepo(p)
+
+	event, err := repo.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	err = p.requireEventOwner(event)
 	if err != nil {
 		return err
 	}
 
-	return s.repo.Delete(event.ID)
+	return repo.Delete(event.ID)
+}
+
+// tenantRepo scopes the repository to the organizers of the principal.
+func (s *EventService) tenantRepo(p *Principal) *repository.EventRepository {
+	if organizerIds, scoped := p.tenant(); scoped {
+		return s.repo.ForTenant(organizerIds)
+	}
+	return s.repo
+}
+
+// requireLocation allows events only at the locations of their own
+// organizer.
+func (s *EventService) requireLocation(organizerId, locationId uuid.UUID) error {
+	_, err := s.locationRepo.ForTenant([]uuid.UUID{organizerId}).FindById(locationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: location %s does not belong to the organizer", ErrInvalidEvent, locationId)
+	}
+	return err
 }
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// completeAttempts is how many times storing a response is tried, each
+// attempt waiting completeRetryDelay longer than the one before.
+const (
+	completeAttempts   = 3
+	completeRetryDelay = 50 * time.Millisecond
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IdempotencyService struct {
+	repo *repository.IdempotencyKeyRepository
+	ttl  time.Duration
+}
+
+func NewIdempotencyService(repo *repository.IdempotencyKeyRepository, ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// IdempotencyClaim is a key claimed by Begin for a request being processed.
+// A service records the response of the request inside the database
+// transaction of its changes, so a key is completed exactly when the
+// changes are committed and is never released after that.
+type IdempotencyClaim struct {
+	Scope string
+	Key   string
+	// Respond renders the response the request gives for data. A service
+	// only records a response when it is set.
+	Respond func(data interface{}) (statusCode int, body []byte, err error)
+
+	repo      *repository.IdempotencyKeyRepository
+	committed bool
+}
+
+// Committed reports whether the changes of the request were committed
+// along with a response for the key.
+func (c *IdempotencyClaim) Committed() bool {
+	return c != nil && c.committed
+}
+
+// record stores the response for data inside tx. A nil claim records
+// nothing.
+func (c *IdempotencyClaim) record(tx *sqlx.Tx, data interface{}) error {
+	if c == nil || c.Respond == nil {
+		return nil
+	}
+
+	statusCode, body, err := c.Respond(data)
+	if err != nil {
+		return err
+	}
+	return c.repo.WithTx(tx).Complete(c.Scope, c.Key, statusCode, body)
+}
+
+// commit marks the response recorded by record as committed.
+func (c *IdempotencyClaim) commit() {
+	if c != nil && c.Respond != nil {
+		c.committed = true
+	}
+}
+
+// Begin claims key for a request with requestHash. It returns the claim
+// when the caller should process the request, or the stored key when the
+// request was already completed and its response should be replayed.
+func (s *IdempotencyService) Begin(scope, key, requestHash string) (*IdempotencyClaim, *models.IdempotencyKey, error) {
+	now := time.Now()
+	claimed, err := s.repo.Claim(&models.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if claimed {
+		return &IdempotencyClaim{Scope: scope, Key: key, repo: s.repo}, nil, nil
+	}
+
+	existing, err := s.repo.Find(scope, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, nil, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode == nil {
+		return nil, nil, ErrIdempotencyKeyInProgress
+	}
+
+	return nil, existing, nil
+}
+
+// Complete stores the response produced for key so retries can replay it,
+// replacing one recorded along with the changes of the request. It is
+// retried a few times, but never releases the key, as the request may have
+// changed something a retry must not do again.
+func (s *IdempotencyService) Complete(scope, key string, statusCode int, responseBody []byte) error {
+	var err error
+	for attempt := 0; attempt < completeAttempts; attempt++ {
+		time.Sleep(time.Duration(attempt) * completeRetryDelay)
+
+		err = s.repo.Complete(scope, key, statusCode, responseBody)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// Abandon releases key after a request failed without changing anything,
+// so the client may retry it. It must not be called for a claim whose
+// changes were committed.
+func (s *IdempotencyService) Abandon(scope, key string) error {
+	return s.repo.Delete(scope, key)
+}
+
+// StartPurger deletes expired keys every interval until ctx is done.
+func (s *IdempotencyService) StartPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := s.repo.DeleteExpired(time.Now())
+			if err != nil {
+				log.Printf("Failed to purge expired idempotency keys: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotentCheckout(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	idempotency := NewIdempotencyService(repository.NewIdempotencyKeyRepository(db), time.Hour)
+	sale := seedSale(t, db, 10)
+	buyer := seedBuyer(t, db)
+
+	const scope, key, hash = "transactions", "checkout-1", "request-hash"
+	respond := func(data interface{}) (int, []byte, error) {
+		body, err := json.Marshal(data)
+		return http.StatusCreated, body, err
+	}
+
+	claim, stored, err := idempotency.Begin(scope, key, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim == nil || stored != nil {
+		t.Fatalf("Begin() of a new key = %v, %v, want a claim", claim, stored)
+	}
+
+	if _, _, err := idempotency.Begin(scope, key, hash); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Errorf("Begin() while the checkout runs error = %v, want %v", err, ErrIdempotencyKeyInProgress)
+	}
+
+	claim.Respond = respond
+	transaction, err := services.transactions.CreateTransaction(buyer, &CreateTransactionRequest{
+		EventID:       sale.eventID,
+		PaymentMethod: models.PaymentMethodBankTransfer,
+		Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: 1}},
+	}, claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claim.Committed() {
+		t.Error("the response was not committed along with the checkout")
+	}
+
+	t.Run("a retry replays the response", func(t *testing.T) {
+		claim, stored, err := idempotency.Begin(scope, key, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claim != nil || stored == nil {
+			t.Fatalf("Begin() of a completed key = %v, %v, want the stored response", claim, stored)
+		}
+
+		// The charge is attached after the response is recorded, so only the
+		// transaction it describes is compared
+		var replayed models.Transaction
+		if err := json.Unmarshal(stored.ResponseBody, &replayed); err != nil {
+			t.Fatal(err)
+		}
+		if stored.StatusCode == nil || *stored.StatusCode != http.StatusCreated || replayed.ID != transaction.ID {
+			t.Errorf("stored response = %v for transaction %s, want %d for %s", stored.StatusCode, replayed.ID, http.StatusCreated, transaction.ID)
+		}
+
+		var count int
+		if err := db.Get(&count, `SELECT COUNT(*) FROM transactions WHERE event_id = $1`, sale.eventID); err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%d transactions were created, want 1", count)
+		}
+	})
+
+	t.Run("a different request cannot reuse the key", func(t *testing.T) {
+		if _, _, err := idempotency.Begin(scope, key, "other-hash"); !errors.Is(err, ErrIdempotencyKeyReused) {
+			t.Errorf("Begin() error = %v, want %v", err, ErrIdempotencyKeyReused)
+		}
+	})
+
+	t.Run("a failed checkout records nothing", func(t *testing.T) {
+		claim, _, err := idempotency.Begin(scope, "checkout-2", hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claim.Respond = respond
+
+		_, err = services.transactions.CreateTransaction(buyer, &CreateTransactionRequest{
+			EventID:       sale.eventID,
+			PaymentMethod: models.PaymentMethodBankTransfer,
+			Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: 100}},
+		}, claim)
+		if !errors.Is(err, repository.ErrInsufficientQuota) {
+			t.Fatalf("CreateTransaction() error = %v, want %v", err, repository.ErrInsufficientQuota)
+		}
+		if claim.Committed() {
+			t.Error("a failed checkout committed its response")
+		}
+	})
+}
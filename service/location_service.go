@@ -19,13 +19,16 @@ func NewLocationService(repo *repository.LocationRepository) *LocationService {
 	}
 }
 
+// CreateLocationRequest may leave OrganizerID out when the caller is a
+// member of a single organizer.
 type CreateLocationRequest struct {
-	Name       string `json:"name" validate:"required"`
-	Address    string `json:"address" validate:"required"`
-	City       string `json:"city" validate:"required"`
-	State      string `json:"state"`
-	Country    string `json:"country" validate:"required"`
-	PostalCode string `json:"postal_code"`
+	OrganizerID *uuid.UUID `json:"organizer_id"`
+	Name        string     `json:"name" validate:"required"`
+	Address     string     `json:"address" validate:"required"`
+	City        string     `json:"city" validate:"required"`
+	State       string     `json:"state"`
+	Country     string     `json:"country" validate:"required"`
+	PostalCode  string     `json:"postal_code"`
 }
 
 type UpdateLocationRequest struct {
@@ -42,8 +45,8 @@ type SearchLocationRequest struct {
 	Country string `json:"country"`
 }
 
-func (s *LocationService) GetAllLocations() ([]models.Location, error) {
-	return s.repo.FindAll()
+func (s *LocationService) GetAllLocations(q *repository.ListQuery) (*repository.Page[models.Location], error) {
+	return s.repo.List(q)
 }
 
 func (s *LocationService) GetLocationById(id uuid.UUID) (*models.Location, error) {
@@ -57,22 +60,33 @@ func (s *LocationService) GetLocationById(id uuid.UUID) (*models.Location, error
 	return location, nil
 }
 
-func (s *LocationService) CreateLocation(req *CreateLocationRequest) (*models.Location, error) {
+func (s *LocationService) CreateLocation(p *Principal, req *CreateLocationRequest) (*models.Location, error) {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return nil, err
+	}
+
+	organizerId, err := p.organizerFor(req.OrganizerID)
+	if err != nil {
+		return nil, err
+	}
+
 	location := &models.Location{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		},
-		Name:       req.Name,
-		Address:    req.Address,
-		City:       req.City,
-		State:      req.State,
-		Country:    req.Country,
-		PostalCode: req.PostalCode,
+		Name:        req.Name,
+		Address:     req.Address,
+		City:        req.City,
+		State:       req.State,
+		Country:     req.Country,
+		PostalCode:  req.PostalCode,
+		OrganizerID: organizerId,
 	}
 
-	err := s.repo.Create(location)
+	err = s.repo.Create(location)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +94,15 @@ func (s *LocationService) CreateLocation(req *CreateLocationRequest) (*models.Lo
 	return s.GetLocationById(location.ID)
 }
 
-func (s *LocationService) UpdateLocation(id uuid.UUID, req *UpdateLocationRequest) (*models.Location, error) {
-	location, err := s.GetLocationById(id)
+func (s *LocationService) UpdateLocation(p *Principal, id uuid.UUID, req *UpdateLocationRequest) (*models.Location, error) {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := s.tenantRepo(p)
+
+	location, err := repo.FindById(id)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +114,7 @@ func (s *LocationService) UpdateLocation(id uuid.UUID, req *UpdateLocationReques
 	location.Country = req.Country
 	location.PostalCode = req.PostalCode
 
-	err = s.repo.Update(location)
+	err = repo.Update(location)
 	if err != nil {
 		return nil, err
 	}
@@ -101,13 +122,20 @@ func (s *LocationService) UpdateLocation(id uuid.UUID, req *UpdateLocationReques
 	return s.GetLocationById(id)
 }
 
-func (s *LocationService) DeleteLocation(id uuid.UUID) error {
-	location, err := s.GetLocationById(id)
+func (s *LocationService) DeleteLocation(p *Principal, id uuid.UUID) error {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return err
+	}
+
+	repo := s.tenantRepo(p)
+
+	location, err := repo.FindById(id)
 	if err != nil {
 		return err
 	}
 
-	return s.repo.Delete(location.ID)
+	return repo.Delete(location.ID)
 }
 
 func (s *LocationService) SearchLocations(req *SearchLocationRequest) ([]models.Location, error) {
@@ -117,5 +145,13 @@ func (s *LocationService) SearchLocations(req *SearchLocationRequest) ([]models.
 	if req.Country != "" {
 		return s.repo.FindByCountry(req.Country)
 	}
-	return s.GetAllLocations()
+	return s.repo.FindAll()
+}
+
+// tenantRepo scopes the repository to the organizers of the principal.
+func (s *LocationService) tenantRepo(p *Principal) *repository.LocationRepository {
+	if organizerIds, scoped := p.tenant(); scoped {
+		return s.repo.ForTenant(organizerIds)
+	}
+	return s.repo
 }
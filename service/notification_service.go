@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"go-ticket/models"
+	"go-ticket/notification"
+	"go-ticket/repository"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// dispatchBatchSize caps how many notifications a single dispatch
+	// delivers.
+	dispatchBatchSize = 50
+	// maxNotificationAttempts is how often delivery of a notification is
+	// tried before it is marked failed.
+	maxNotificationAttempts = 5
+)
+
+type NotificationService struct {
+	uow    *repository.UnitOfWork
+	repo   *repository.NotificationRepository
+	sender notification.Sender
+}
+
+func NewNotificationService(uow *repository.UnitOfWork, repo *repository.NotificationRepository, sender notification.Sender) *NotificationService {
+	return &NotificationService{
+		uow:    uow,
+		repo:   repo,
+		sender: sender,
+	}
+}
+
+// enqueue adds a notification for user to the outbox within tx. It is
+// delivered by the dispatcher once tx commits.
+func (s *NotificationService) enqueue(tx *sqlx.Tx, user *models.User, subject, body string) error {
+	now := time.Now()
+	return s.repo.WithTx(tx).Create(&models.Notification{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Recipient: user.Email,
+		Subject:   subject,
+		Body:      body,
+		Status:    models.NotificationStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// StartDispatcher delivers pending notifications every interval until ctx
+// is done.
+func (s *NotificationService) StartDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.Dispatch()
+			if err != nil {
+				log.Printf("Failed to dispatch notifications: %v", err)
+			}
+			if sent > 0 {
+				log.Printf("Sent %d notifications", sent)
+			}
+		}
+	}
+}
+
+// Dispatch delivers a batch of pending notifications and returns how many
+// were sent. The batch stays locked while it is delivered, so concurrent
+// dispatchers never send the same notification twice.
+func (s *NotificationService) Dispatch() (int, error) {
+	sent := 0
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		notifications, err := repo.FindPendingForUpdate(dispatchBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, n := range notifications {
+			err = s.sender.Send(notification.Message{
+				Recipient: n.Recipient,
+				Subject:   n.Subject,
+				Body:      n.Body,
+			})
+			if err != nil {
+				status := models.NotificationStatusPending
+				if n.Attempts+1 >= maxNotificationAttempts {
+					status = models.NotificationStatusFailed
+				}
+				err = repo.MarkAttemptFailed(n.ID, status, err.Error())
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err = repo.MarkSent(n.ID, time.Now())
+			if err != nil {
+				return err
+			}
+			sent++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return sent, nil
+}
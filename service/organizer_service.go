@@ -0,0 +1,283 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrInvalidOrganizer = errors.New("invalid organizer")
+
+type OrganizerService struct {
+	uow      *repository.UnitOfWork
+	repo     *repository.OrganizerRepository
+	userRepo *repository.UserRepository
+	roleRepo *repository.RoleRepository
+}
+
+func NewOrganizerService(
+	uow *repository.UnitOfWork,
+	repo *repository.OrganizerRepository,
+	userRepo *repository.UserRepository,
+	roleRepo *repository.RoleRepository,
+) *OrganizerService {
+	return &OrganizerService{
+		uow:      uow,
+		repo:     repo,
+		userRepo: userRepo,
+		roleRepo: roleRepo,
+	}
+}
+
+// CreateOrganizerRequest names the user who owns the new organizer.
+type CreateOrganizerRequest struct {
+	Name    string    `json:"name" validate:"required"`
+	OwnerID uuid.UUID `json:"owner_id" validate:"required"`
+}
+
+type UpdateOrganizerRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type UpdateOrganizerMemberRequest struct {
+	Role models.OrganizerRole `json:"role" validate:"required"`
+}
+
+// GetOrganizers returns every organizer to admins, and the organizers they
+// are a member of to everyone else.
+func (s *OrganizerService) GetOrganizers(p *Principal, q *repository.ListQuery) (*repository.Page[models.Organizer], error) {
+	if p.Can(PermissionOrganizersManage) {
+		return s.repo.List(q)
+	}
+	return s.repo.ListByUserId(p.User.ID, q)
+}
+
+func (s *OrganizerService) GetOrganizerById(p *Principal, id uuid.UUID) (*models.Organizer, error) {
+	err := p.requireMember(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindById(id)
+}
+
+// CreateOrganizer creates an organizer and makes its owner an organizer, so
+// they can manage events right away.
+func (s *OrganizerService) CreateOrganizer(p *Principal, req *CreateOrganizerRequest) (*models.Organizer, error) {
+	err := p.require(PermissionOrganizersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidOrganizer)
+	}
+
+	organizer := &models.Organizer{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		Name: req.Name,
+	}
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		err := s.repo.WithTx(tx).Create(organizer)
+		if err != nil {
+			return err
+		}
+
+		return s.saveMember(tx, organizer.ID, req.OwnerID, models.OrganizerRoleOwner)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return organizer, nil
+}
+
+func (s *OrganizerService) UpdateOrganizer(p *Principal, id uuid.UUID, req *UpdateOrganizerRequest) (*models.Organizer, error) {
+	err := s.requireOwner(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidOrganizer)
+	}
+
+	organizer, err := s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	organizer.Name = req.Name
+	organizer.UpdatedAt = time.Now()
+
+	err = s.repo.Update(organizer)
+	if err != nil {
+		return nil, err
+	}
+
+	return organizer, nil
+}
+
+func (s *OrganizerService) GetMembers(p *Principal, id uuid.UUID) ([]models.OrganizerMember, error) {
+	err := p.requireMember(id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindMembers(id)
+}
+
+// UpdateMember adds a user to an organizer or changes their role. Only
+// owners and admins manage members, and the last owner cannot step down.
+func (s *OrganizerService) UpdateMember(p *Principal, id, userId uuid.UUID, req *UpdateOrganizerMemberRequest) (*models.OrganizerMember, error) {
+	err := s.requireOwner(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown member role %s", ErrInvalidOrganizer, req.Role)
+	}
+
+	var member *models.OrganizerMember
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		_, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if req.Role != models.OrganizerRoleOwner {
+			err = s.requireOtherOwner(repo, id, userId)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = s.saveMember(tx, id, userId, req.Role)
+		if err != nil {
+			return err
+		}
+
+		member, err = repo.FindMember(id, userId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *OrganizerService) RemoveMember(p *Principal, id, userId uuid.UUID) error {
+	err := s.requireOwner(p, id)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		_, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.FindMember(id, userId)
+		if err != nil {
+			return err
+		}
+
+		err = s.requireOtherOwner(repo, id, userId)
+		if err != nil {
+			return err
+		}
+
+		return repo.RemoveMember(id, userId)
+	})
+}
+
+// saveMember adds a user to an organizer with role and grants them the
+// organizer role.
+func (s *OrganizerService) saveMember(tx *sqlx.Tx, organizerId, userId uuid.UUID, role models.OrganizerRole) error {
+	_, err := s.userRepo.WithTx(tx).FindById(userId)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.WithTx(tx).SaveMember(&models.OrganizerMember{
+		OrganizerID: organizerId,
+		UserID:      userId,
+		Role:        role,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.roleRepo.WithTx(tx).AddUserRole(userId, RoleOrganizer)
+	return err
+}
+
+// requireOtherOwner fails when userId is the only owner of an organizer.
+func (s *OrganizerService) requireOtherOwner(repo *repository.OrganizerRepository, organizerId, userId uuid.UUID) error {
+	member, err := repo.FindMember(organizerId, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if member.Role != models.OrganizerRoleOwner {
+		return nil
+	}
+
+	owners, err := repo.CountOwners(organizerId)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return fmt.Errorf("%w: an organizer needs at least one owner", ErrInvalidOrganizer)
+	}
+	return nil
+}
+
+// requireOwner allows the owners of an organizer and admins.
+func (s *OrganizerService) requireOwner(p *Principal, organizerId uuid.UUID) error {
+	if p.Can(PermissionOrganizersManage) {
+		return nil
+	}
+
+	if p == nil {
+		return ErrForbidden
+	}
+
+	member, err := s.repo.FindMember(organizerId, p.User.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrForbidden
+	}
+	if err != nil {
+		return err
+	}
+	if member.Role != models.OrganizerRoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+	"go-ticket/payment"
+	"go-ticket/repository"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrUnknownPaymentProvider = errors.New("unknown payment provider")
+	ErrWebhookReplay          = errors.New("webhook nonce already used")
+)
+
+type PaymentService struct {
+	uow          *repository.UnitOfWork
+	nonceRepo    *repository.PaymentWebhookNonceRepository
+	transactions *TransactionService
+	gateway      payment.Gateway
+	secret       []byte
+	tolerance    time.Duration
+}
+
+func NewPaymentService(
+	uow *repository.UnitOfWork,
+	nonceRepo *repository.PaymentWebhookNonceRepository,
+	transactions *TransactionService,
+	gateway payment.Gateway,
+	secret string,
+	tolerance time.Duration,
+) *PaymentService {
+	return &PaymentService{
+		uow:          uow,
+		nonceRepo:    nonceRepo,
+		transactions: transactions,
+		gateway:      gateway,
+		secret:       []byte(secret),
+		tolerance:    tolerance,
+	}
+}
+
+type WebhookRequest struct {
+	Provider  string
+	Signature string
+	Timestamp string
+	Nonce     string
+	Body      []byte
+}
+
+// HandleWebhook verifies a provider webhook and applies the charge status it
+// reports. The nonce is claimed in the same database transaction as the
+// status change, so a delivery that fails can be retried with its nonce.
+// A delivery reporting a status the transaction already has is a no-op.
+func (s *PaymentService) HandleWebhook(req *WebhookRequest) error {
+	if req.Provider != s.gateway.Name() {
+		return ErrUnknownPaymentProvider
+	}
+
+	now := time.Now()
+	err := payment.VerifySignature(s.secret, req.Timestamp, req.Nonce, req.Body, req.Signature, now, s.tolerance)
+	if err != nil {
+		return err
+	}
+
+	event, err := s.gateway.ParseEvent(req.Body)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		nonceRepo := s.nonceRepo.WithTx(tx)
+
+		err := nonceRepo.DeleteReceivedBefore(now.Add(-2 * s.tolerance))
+		if err != nil {
+			return err
+		}
+
+		claimed, err := nonceRepo.Claim(req.Provider, req.Nonce, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return ErrWebhookReplay
+		}
+
+		return s.transactions.applyPaymentEvent(tx, *event)
+	})
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"go-ticket/payment"
+	"go-ticket/repository"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// webhookDB is a database/sql driver that answers just the queries of a
+// webhook delivery: it keeps claimed nonces, committed or rolled back with
+// their transaction, and loads a transaction already paid so that applying
+// the delivery changes nothing else.
+type webhookDB struct {
+	mu       sync.Mutex
+	nonces   map[string]bool
+	queries  int
+	loadFail error
+}
+
+func (d *webhookDB) Connect(context.Context) (driver.Conn, error) {
+	return &webhookConn{db: d}, nil
+}
+
+func (d *webhookDB) Driver() driver.Driver {
+	return nil
+}
+
+type webhookConn struct {
+	db      *webhookDB
+	pending []string
+}
+
+func (c *webhookConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *webhookConn) Close() error {
+	return nil
+}
+
+func (c *webhookConn) Begin() (driver.Tx, error) {
+	c.pending = nil
+	return c, nil
+}
+
+func (c *webhookConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for _, nonce := range c.pending {
+		c.db.nonces[nonce] = true
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *webhookConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+func (c *webhookConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries++
+
+	if !strings.Contains(query, "INSERT INTO payment_webhook_nonces") {
+		return driver.RowsAffected(1), nil
+	}
+
+	nonce := args[0].Value.(string) + "/" + args[1].Value.(string)
+	if c.db.nonces[nonce] {
+		return driver.RowsAffected(0), nil
+	}
+	for _, pending := range c.pending {
+		if pending == nonce {
+			return driver.RowsAffected(0), nil
+		}
+	}
+	c.pending = append(c.pending, nonce)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *webhookConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries++
+
+	if c.db.loadFail != nil {
+		return nil, c.db.loadFail
+	}
+	return &paidTransactionRows{}, nil
+}
+
+type paidTransactionRows struct {
+	done bool
+}
+
+func (r *paidTransactionRows) Columns() []string {
+	return []string{"id", "status", "payment_status"}
+}
+
+func (r *paidTransactionRows) Close() error {
+	return nil
+}
+
+func (r *paidTransactionRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = uuid.NewString()
+	dest[1] = "confirmed"
+	dest[2] = "paid"
+	return nil
+}
+
+const webhookSecret = "webhook-secret"
+
+func newWebhookService(t *testing.T) (*PaymentService, *webhookDB) {
+	fake := &webhookDB{nonces: make(map[string]bool)}
+	db := sqlx.NewDb(sql.OpenDB(fake), "postgres")
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	transactions := &TransactionService{
+		repo:        repository.NewTransactionRepository(db),
+		historyRepo: repository.NewTransactionStatusHistoryRepository(db),
+	}
+	service := NewPaymentService(
+		repository.NewUnitOfWork(db),
+		repository.NewPaymentWebhookNonceRepository(db),
+		transactions,
+		payment.NewFakeGateway("http://localhost"),
+		webhookSecret,
+		5*time.Minute,
+	)
+	return service, fake
+}
+
+func signedWebhook(nonce string) *WebhookRequest {
+	body := []byte(`{"reference":"fake_1","status":"paid"}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return &WebhookRequest{
+		Provider:  payment.FakeProvider,
+		Signature: payment.Sign([]byte(webhookSecret), timestamp, nonce, body),
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Body:      body,
+	}
+}
+
+func TestHandleWebhookRejectsReplay(t *testing.T) {
+	service, _ := newWebhookService(t)
+	req := signedWebhook("nonce-1")
+
+	err := service.HandleWebhook(req)
+	if err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+
+	err = service.HandleWebhook(req)
+	if !errors.Is(err, ErrWebhookReplay) {
+		t.Errorf("replayed delivery = %v, want %v", err, ErrWebhookReplay)
+	}
+
+	err = service.HandleWebhook(signedWebhook("nonce-2"))
+	if err != nil {
+		t.Errorf("delivery with a new nonce: %v", err)
+	}
+}
+
+func TestHandleWebhookRetriesFailedDelivery(t *testing.T) {
+	service, fake := newWebhookService(t)
+	req := signedWebhook("nonce-1")
+
+	fake.loadFail = errors.New("connection reset")
+	err := service.HandleWebhook(req)
+	if err == nil {
+		t.Fatal("delivery succeeded while the transaction could not be loaded")
+	}
+
+	// The claim was rolled back along with the delivery, so the provider's
+	// retry with the same nonce goes through
+	fake.loadFail = nil
+	err = service.HandleWebhook(req)
+	if err != nil {
+		t.Errorf("retried delivery: %v", err)
+	}
+}
+
+func TestHandleWebhookRejectsUnverifiedDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(req *WebhookRequest)
+		wantErr error
+	}{
+		{"unknown provider", func(req *WebhookRequest) { req.Provider = "other" }, ErrUnknownPaymentProvider},
+		{"bad signature", func(req *WebhookRequest) { req.Signature = strings.Repeat("0", 64) }, payment.ErrInvalidSignature},
+		{"tampered body", func(req *WebhookRequest) { req.Body = []byte(`{"reference":"fake_2","status":"paid"}`) }, payment.ErrInvalidSignature},
+		{"stale timestamp", func(req *WebhookRequest) {
+			req.Timestamp = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+			req.Signature = payment.Sign([]byte(webhookSecret), req.Timestamp, req.Nonce, req.Body)
+		}, payment.ErrStaleWebhook},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, fake := newWebhookService(t)
+			req := signedWebhook("nonce-1")
+			tt.tamper(req)
+
+			err := service.HandleWebhook(req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("HandleWebhook() = %v, want %v", err, tt.wantErr)
+			}
+			if fake.queries != 0 {
+				t.Errorf("made %d queries for a rejected delivery, want 0", fake.queries)
+			}
+		})
+	}
+}
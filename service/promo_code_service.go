@@ -0,0 +1,347 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInvalidPromoCode = errors.New("invalid promo code")
+	// ErrPromoCodeUsedUp is returned when a promo code reached its cap, in
+	// total or for the buyer.
+	ErrPromoCodeUsedUp = errors.New("promo code is used up")
+)
+
+// PromoCodeService manages the promo codes of organizers and applies them
+// at checkout.
+type PromoCodeService struct {
+	uow  *repository.UnitOfWork
+	repo *repository.PromoCodeRepository
+}
+
+func NewPromoCodeService(uow *repository.UnitOfWork, repo *repository.PromoCodeRepository) *PromoCodeService {
+	return &PromoCodeService{
+		uow:  uow,
+		repo: repo,
+	}
+}
+
+// CreatePromoCodeRequest may leave OrganizerID out when the caller is a
+// member of a single organizer. A percent code takes PercentOff, a fixed
+// code AmountOff in Currency, or DefaultCurrency when left out. EventIDs
+// and TicketTypeIDs restrict the code to some of the organizer's events and
+// ticket types.
+type CreatePromoCodeRequest struct {
+	OrganizerID           *uuid.UUID          `json:"organizer_id"`
+	Code                  string              `json:"code" validate:"required,max=50"`
+	Kind                  models.DiscountKind `json:"kind" validate:"required"`
+	PercentOff            *int                `json:"percent_off"`
+	AmountOff             *models.Money       `json:"amount_off"`
+	Currency              string              `json:"currency"`
+	MinQuantity           int                 `json:"min_quantity" validate:"omitempty,min=1"`
+	MaxRedemptions        *int                `json:"max_redemptions" validate:"omitempty,min=1"`
+	MaxRedemptionsPerUser *int                `json:"max_redemptions_per_user" validate:"omitempty,min=1"`
+	StartsAt              *time.Time          `json:"starts_at"`
+	EndsAt                *time.Time          `json:"ends_at"`
+	EventIDs              []uuid.UUID         `json:"event_ids"`
+	TicketTypeIDs         []uuid.UUID         `json:"ticket_type_ids"`
+}
+
+func (s *PromoCodeService) GetPromoCodes(p *Principal) ([]models.PromoCode, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tenantRepo(p).FindAllWithTargets()
+}
+
+func (s *PromoCodeService) GetPromoCodeById(p *Principal, id uuid.UUID) (*models.PromoCode, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tenantRepo(p).FindWithTargets(id)
+}
+
+func (s *PromoCodeService) CreatePromoCode(p *Principal, req *CreatePromoCodeRequest) (*models.PromoCode, error) {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	organizerId, err := p.organizerFor(req.OrganizerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var currency *string
+	if req.Kind == models.DiscountKindFixed {
+		code := req.Currency
+		if code == "" {
+			code = models.DefaultCurrency
+		}
+		currency = &code
+	}
+
+	promoCode := &models.PromoCode{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		OrganizerID:           organizerId,
+		Code:                  strings.ToUpper(strings.TrimSpace(req.Code)),
+		Kind:                  req.Kind,
+		PercentOff:            req.PercentOff,
+		AmountOff:             req.AmountOff,
+		Currency:              currency,
+		MinQuantity:           max(req.MinQuantity, 1),
+		MaxRedemptions:        req.MaxRedemptions,
+		MaxRedemptionsPerUser: req.MaxRedemptionsPerUser,
+		StartsAt:              req.StartsAt,
+		EndsAt:                req.EndsAt,
+	}
+
+	err = validatePromoCode(promoCode)
+	if err != nil {
+		return nil, err
+	}
+
+	eventIds := uniqueIds(req.EventIDs)
+	ticketTypeIds := uniqueIds(req.TicketTypeIDs)
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		_, err := repo.FindByCode(organizerId, promoCode.Code)
+		if err == nil {
+			return fmt.Errorf("%w: code %s already exists", ErrInvalidPromoCode, promoCode.Code)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		err = repo.Create(promoCode)
+		if err != nil {
+			return err
+		}
+
+		added, err := repo.AddTargets(promoCode.ID, organizerId, eventIds, ticketTypeIds)
+		if err != nil {
+			return err
+		}
+		if added != len(eventIds)+len(ticketTypeIds) {
+			return fmt.Errorf("%w: some events or ticket types do not belong to the organizer", ErrInvalidPromoCode)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindWithTargets(promoCode.ID)
+}
+
+// DeletePromoCode ends a promo code. Transactions that redeemed it keep
+// their discount.
+func (s *PromoCodeService) DeletePromoCode(p *Principal, id uuid.UUID) error {
+	err := p.require(PermissionEventsManage)
+	if err != nil {
+		return err
+	}
+
+	repo := s.tenantRepo(p)
+
+	promoCode, err := repo.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	return repo.Delete(promoCode.ID)
+}
+
+// apply looks up code among the promo codes of the event's organizer and
+// discounts the eligible details, lowering their subtotals. It returns the
+// promo code, which must be redeemed once the transaction exists.
+func (s *PromoCodeService) apply(tx *sqlx.Tx, event *models.Event, code string, details []models.TransactionDetail, now time.Time) (*models.PromoCode, error) {
+	promoCode, err := s.repo.WithTx(tx).FindByCode(event.OrganizerID, strings.TrimSpace(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: code %s does not exist", ErrInvalidPromoCode, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !promoCode.ValidAt(now) {
+		return nil, fmt.Errorf("%w: code %s is not valid at this time", ErrInvalidPromoCode, promoCode.Code)
+	}
+	if len(promoCode.EventIDs) > 0 && !slices.Contains(promoCode.EventIDs, event.ID) {
+		return nil, fmt.Errorf("%w: code %s does not apply to this event", ErrInvalidPromoCode, promoCode.Code)
+	}
+
+	var eligible []int
+	quantity := 0
+	var subtotal models.Money
+	for i, detail := range details {
+		if len(promoCode.TicketTypeIDs) > 0 && !slices.Contains(promoCode.TicketTypeIDs, detail.TicketTypeID) {
+			continue
+		}
+		eligible = append(eligible, i)
+		quantity += detail.Quantity
+		subtotal, err = subtotal.Add(detail.Subtotal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("%w: code %s does not apply to these tickets", ErrInvalidPromoCode, promoCode.Code)
+	}
+	if quantity < promoCode.MinQuantity {
+		return nil, fmt.Errorf("%w: code %s needs at least %d eligible tickets", ErrInvalidPromoCode, promoCode.Code, promoCode.MinQuantity)
+	}
+	if promoCode.Kind == models.DiscountKindFixed && *promoCode.Currency != subtotal.Currency {
+		return nil, fmt.Errorf("%w: code %s is in %s, not %s", ErrInvalidPromoCode, promoCode.Code, *promoCode.Currency, subtotal.Currency)
+	}
+
+	for i, discount := range discounts(promoCode, details, eligible, subtotal) {
+		detail := &details[eligible[i]]
+		detail.Discount = discount
+		detail.Subtotal, err = detail.Subtotal.Sub(discount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return promoCode, nil
+}
+
+// discounts splits the discount of promoCode over the eligible details. A
+// percent is taken off each detail, rounded down to a minor unit of the
+// currency. A fixed amount, capped at the eligible subtotal, is shared in
+// proportion to the subtotals and the last detail takes the rounding
+// remainder.
+func discounts(promoCode *models.PromoCode, details []models.TransactionDetail, eligible []int, subtotal models.Money) []models.Money {
+	result := make([]models.Money, len(eligible))
+
+	if promoCode.Kind == models.DiscountKindPercent {
+		for i, index := range eligible {
+			result[i] = details[index].Subtotal.Share(int64(*promoCode.PercentOff), 100)
+		}
+		return result
+	}
+
+	total := models.NewMoney(min(promoCode.AmountOff.Amount, subtotal.Amount), subtotal.Currency)
+	remaining := total.Amount
+	for i, index := range eligible {
+		amount := remaining
+		if i < len(eligible)-1 && subtotal.Amount > 0 {
+			amount = total.Share(details[index].Subtotal.Amount, subtotal.Amount).Amount
+		}
+		remaining -= amount
+		result[i] = models.NewMoney(amount, subtotal.Currency)
+	}
+	return result
+}
+
+// redeem counts the use of promoCode for a transaction of the principal.
+// The total cap is enforced by the conditional update that counts it,
+// which also serializes redemptions of the code, so the per user count
+// that follows cannot be raced either.
+func (s *PromoCodeService) redeem(tx *sqlx.Tx, p *Principal, promoCode *models.PromoCode, transactionId uuid.UUID) error {
+	repo := s.repo.WithTx(tx)
+
+	redeemed, err := repo.Redeem(promoCode.ID)
+	if err != nil {
+		return err
+	}
+	if !redeemed {
+		return fmt.Errorf("%w: code %s reached its redemption limit", ErrPromoCodeUsedUp, promoCode.Code)
+	}
+
+	if promoCode.MaxRedemptionsPerUser != nil {
+		count, err := repo.CountRedemptionsByUser(promoCode.ID, p.User.ID)
+		if err != nil {
+			return err
+		}
+		if count >= *promoCode.MaxRedemptionsPerUser {
+			return fmt.Errorf("%w: code %s can be used %d times per buyer", ErrPromoCodeUsedUp, promoCode.Code, *promoCode.MaxRedemptionsPerUser)
+		}
+	}
+
+	return repo.CreateRedemption(&models.PromoCodeRedemption{
+		ID:            uuid.New(),
+		PromoCodeID:   promoCode.ID,
+		UserID:        p.User.ID,
+		TransactionID: transactionId,
+		CreatedAt:     time.Now(),
+	})
+}
+
+func validatePromoCode(promoCode *models.PromoCode) error {
+	if promoCode.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidPromoCode)
+	}
+
+	switch promoCode.Kind {
+	case models.DiscountKindPercent:
+		if promoCode.PercentOff == nil || *promoCode.PercentOff < 1 || *promoCode.PercentOff > 100 {
+			return fmt.Errorf("%w: percent_off must be between 1 and 100", ErrInvalidPromoCode)
+		}
+		if promoCode.AmountOff != nil {
+			return fmt.Errorf("%w: a percent code takes no amount_off", ErrInvalidPromoCode)
+		}
+	case models.DiscountKindFixed:
+		if promoCode.AmountOff == nil || promoCode.AmountOff.IsNegative() || promoCode.AmountOff.IsZero() {
+			return fmt.Errorf("%w: amount_off must be positive", ErrInvalidPromoCode)
+		}
+		_, err := promoCode.AmountOff.In(*promoCode.Currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromoCode, err)
+		}
+		if promoCode.PercentOff != nil {
+			return fmt.Errorf("%w: a fixed code takes no percent_off", ErrInvalidPromoCode)
+		}
+	default:
+		return fmt.Errorf("%w: kind must be %s or %s", ErrInvalidPromoCode, models.DiscountKindPercent, models.DiscountKindFixed)
+	}
+
+	if promoCode.StartsAt != nil && promoCode.EndsAt != nil && !promoCode.EndsAt.After(*promoCode.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromoCode)
+	}
+
+	return nil
+}
+
+// uniqueIds drops repeated ids, keeping the first of each.
+func uniqueIds(ids []uuid.UUID) []uuid.UUID {
+	unique := make([]uuid.UUID, 0, len(ids))
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// tenantRepo scopes the repository to the organizers of the principal.
+func (s *PromoCodeService) tenantRepo(p *Principal) *repository.PromoCodeRepository {
+	if organizerIds, scoped := p.tenant(); scoped {
+		return s.repo.ForTenant(organizerIds)
+	}
+	return s.repo
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"go-ticket/models"
+	"reflect"
+	"testing"
+)
+
+func TestDiscounts(t *testing.T) {
+	percent := func(off int) *models.PromoCode {
+		return &models.PromoCode{Kind: models.DiscountKindPercent, PercentOff: &off}
+	}
+	fixed := func(off int64) *models.PromoCode {
+		amount := models.NewMoney(off, "IDR")
+		return &models.PromoCode{Kind: models.DiscountKindFixed, AmountOff: &amount}
+	}
+
+	tests := []struct {
+		name      string
+		promoCode *models.PromoCode
+		subtotals []int64
+		eligible  []int
+		want      []int64
+	}{
+		{"percent of each detail", percent(10), []int64{1000000, 333300}, []int{0, 1}, []int64{100000, 33300}},
+		{"percent of eligible details only", percent(50), []int64{1000000, 400000, 300000}, []int{1, 2}, []int64{200000, 150000}},
+		{"full percent", percent(100), []int64{1000000}, []int{0}, []int64{1000000}},
+		{"fixed in proportion", fixed(100000), []int64{500000, 300000, 200000}, []int{0, 1, 2}, []int64{50000, 30000, 20000}},
+		{"fixed remainder to the last detail", fixed(100000), []int64{333300, 333300, 333300}, []int{0, 1, 2}, []int64{33300, 33300, 33400}},
+		{"fixed over eligible details only", fixed(100000), []int64{500000, 900000, 500000}, []int{0, 2}, []int64{50000, 50000}},
+		{"fixed capped at the subtotal", fixed(2000000), []int64{1000000, 500000}, []int{0, 1}, []int64{1000000, 500000}},
+		{"fixed on free tickets", fixed(100000), []int64{0, 0}, []int{0, 1}, []int64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := make([]models.TransactionDetail, len(tt.subtotals))
+			for i, subtotal := range tt.subtotals {
+				details[i].Subtotal = models.NewMoney(subtotal, "IDR")
+			}
+			var subtotal int64
+			for _, index := range tt.eligible {
+				subtotal += tt.subtotals[index]
+			}
+
+			result := discounts(tt.promoCode, details, tt.eligible, models.NewMoney(subtotal, "IDR"))
+
+			got := make([]int64, len(result))
+			for i, discount := range result {
+				got[i] = discount.Amount
+				if discount.Currency != "IDR" {
+					t.Errorf("discount %d is in %q, want IDR", i, discount.Currency)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("discounts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/payment"
+	"go-ticket/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrRefundNotAllowed = errors.New("transaction cannot be refunded")
+	ErrInvalidRefund    = errors.New("invalid refund")
+	// ErrRefundDeadlinePassed is returned when a buyer asks for the refund
+	// of a postponed event too late.
+	ErrRefundDeadlinePassed = errors.New("refund deadline has passed")
+	ErrEventNotPostponed    = errors.New("event is not postponed")
+)
+
+type RefundService struct {
+	uow             *repository.UnitOfWork
+	repo            *repository.RefundRepository
+	transactionRepo *repository.TransactionRepository
+	detailRepo      *repository.TransactionDetailRepository
+	eventRepo       *repository.EventRepository
+	ticketTypeRepo  *repository.TicketTypeRepository
+	historyRepo     *repository.TransactionStatusHistoryRepository
+	tickets         *TicketService
+	gateway         payment.Gateway
+}
+
+func NewRefundService(
+	uow *repository.UnitOfWork,
+	repo *repository.RefundRepository,
+	transactionRepo *repository.TransactionRepository,
+	detailRepo *repository.TransactionDetailRepository,
+	eventRepo *repository.EventRepository,
+	ticketTypeRepo *repository.TicketTypeRepository,
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	tickets *TicketService,
+	gateway payment.Gateway,
+) *RefundService {
+	return &RefundService{
+		uow:             uow,
+		repo:            repo,
+		transactionRepo: transactionRepo,
+		detailRepo:      detailRepo,
+		eventRepo:       eventRepo,
+		ticketTypeRepo:  ticketTypeRepo,
+		historyRepo:     historyRepo,
+		tickets:         tickets,
+		gateway:         gateway,
+	}
+}
+
+type RefundItemRequest struct {
+	TransactionDetailID uuid.UUID `json:"transaction_detail_id" validate:"required"`
+	Quantity            int       `json:"quantity" validate:"required,min=1"`
+}
+
+// RefundTransactionRequest refunds the listed items, or every ticket not
+// yet refunded when Items is empty.
+type RefundTransactionRequest struct {
+	Reason string              `json:"reason" validate:"required"`
+	Items  []RefundItemRequest `json:"items"`
+}
+
+func (s *RefundService) GetRefundsByTransactionId(p *Principal, transactionId uuid.UUID) ([]models.Refund, error) {
+	transaction, err := s.transactionRepo.FindById(transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(transaction.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindByTransactionId(transactionId)
+}
+
+// RefundTransaction refunds a paid transaction in full or in part.
+func (s *RefundService) RefundTransaction(p *Principal, transactionId uuid.UUID, req *RefundTransactionRequest) (*models.Refund, error) {
+	err := p.require(PermissionTransactionsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidRefund)
+	}
+
+	return s.refund(transactionId, refundRequest{
+		items:  req.Items,
+		reason: req.Reason,
+		actor:  p.actor(),
+	})
+}
+
+// RefundPostponed refunds every ticket of a transaction whose event was
+// postponed, for buyers who cannot make the new date. Buyers may ask for it
+// themselves until the refund deadline of the event, as long as the event
+// is still postponed.
+func (s *RefundService) RefundPostponed(p *Principal, transactionId uuid.UUID) (*models.Refund, error) {
+	return s.refund(transactionId, refundRequest{
+		reason: "event postponed",
+		actor:  p.actor(),
+		check: func(tx *sqlx.Tx, transaction *models.Transaction) error {
+			err := p.requireSelfOr(transaction.UserID, PermissionTransactionsManage)
+			if err != nil {
+				return err
+			}
+
+			event, err := s.eventRepo.WithTx(tx).FindByIdForShare(transaction.EventID)
+			if err != nil {
+				return err
+			}
+
+			if event.Status != models.EventStatusPostponed {
+				return fmt.Errorf("%w: event is %s", ErrEventNotPostponed, event.Status)
+			}
+			if event.RefundDeadline == nil || !time.Now().Before(*event.RefundDeadline) {
+				return ErrRefundDeadlinePassed
+			}
+			return nil
+		},
+	})
+}
+
+// refundRequest is a refund of the items of a transaction, or of every
+// ticket not yet refunded when items is empty.
+type refundRequest struct {
+	items  []RefundItemRequest
+	reason string
+	actor  string
+	// check vets the locked transaction before anything is refunded.
+	check func(tx *sqlx.Tx, transaction *models.Transaction) error
+	// done runs in the database transaction recording a refund the
+	// provider made.
+	done func(tx *sqlx.Tx, transaction *models.Transaction, refund *models.Refund) error
+}
+
+// refund refunds a paid transaction in three steps, so the provider is
+// never asked for money inside a database transaction:
+//
+//  1. The refund is stored as pending. Its tickets are voided, so they
+//     cannot be used meanwhile, and marked refunded on the transaction's
+//     details, so no other refund takes them too.
+//  2. The provider refunds the charge.
+//  3. A refund the provider made gives its tickets back to their ticket
+//     types' remaining quota, puts their seats back on sale and moves the
+//     payment status to partially_refunded or refunded, cancelling the
+//     transaction with a full refund. A refund the provider turned down is
+//     recorded as failed and its tickets are made valid again.
+//
+// Only one refund of a transaction is pending at a time. One left pending
+// by a crash between the steps keeps its tickets void until it is settled
+// with the provider.
+func (s *RefundService) refund(transactionId uuid.UUID, req refundRequest) (*models.Refund, error) {
+	var refund *models.Refund
+	var reference string
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		transaction, err := s.transactionRepo.WithTx(tx).FindByIdForUpdate(transactionId)
+		if err != nil {
+			return err
+		}
+
+		if req.check != nil {
+			err = req.check(tx, transaction)
+			if err != nil {
+				return err
+			}
+		}
+
+		refund, err = s.begin(tx, transaction, req)
+		if err != nil {
+			return err
+		}
+		reference = *transaction.PaymentReference
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	providerRefund, refundErr := s.gateway.Refund(payment.RefundRequest{
+		Reference: reference,
+		Amount:    refund.Amount,
+		Reason:    req.reason,
+	})
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		transaction, err := s.transactionRepo.WithTx(tx).FindByIdForUpdate(transactionId)
+		if err != nil {
+			return err
+		}
+
+		if refundErr != nil {
+			return s.fail(tx, refund, refundErr)
+		}
+
+		refund.ProviderReference = &providerRefund.Reference
+		err = s.complete(tx, transaction, refund, req.actor, req.reason)
+		if err != nil {
+			return err
+		}
+
+		if req.done != nil {
+			return req.done(tx, transaction, refund)
+		}
+		return nil
+	})
+	if refundErr != nil {
+		return nil, errors.Join(refundErr, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// begin stores a pending refund of a locked, paid transaction, voids its
+// tickets and marks them refunded.
+func (s *RefundService) begin(tx *sqlx.Tx, transaction *models.Transaction, req refundRequest) (*models.Refund, error) {
+	detailRepo := s.detailRepo.WithTx(tx)
+	repo := s.repo.WithTx(tx)
+
+	if !isPaid(transaction.PaymentStatus) || transaction.PaymentReference == nil {
+		return nil, fmt.Errorf("%w: payment status is %s", ErrRefundNotAllowed, transaction.PaymentStatus)
+	}
+
+	pending, err := repo.HasPending(transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, fmt.Errorf("%w: another refund is in progress", ErrRefundNotAllowed)
+	}
+
+	details, err := detailRepo.FindByTransactionIdForUpdate(transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities, err := refundQuantities(details, req.items)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.Refund{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		TransactionID: transaction.ID,
+		Amount:        models.NewMoney(0, transaction.Currency),
+		Reason:        req.reason,
+		Actor:         req.actor,
+		Status:        models.RefundStatusPending,
+	}
+
+	for i := range details {
+		detail := &details[i]
+		quantity := quantities[detail.ID]
+		if quantity == 0 {
+			continue
+		}
+
+		err = detailRepo.AddRefundedQuantity(detail.ID, quantity)
+		if err != nil {
+			return nil, err
+		}
+		err = s.tickets.voidRefunded(tx, detail.ID, quantity)
+		if err != nil {
+			return nil, err
+		}
+		amount := detail.RefundAmount(quantity, transaction.Currency)
+
+		refund.Amount, err = refund.Amount.Add(amount)
+		if err != nil {
+			return nil, err
+		}
+		refund.Items = append(refund.Items, models.RefundItem{
+			BaseModel: models.BaseModel{
+				ID:        uuid.New(),
+				CreatedAt: refund.CreatedAt,
+				UpdatedAt: refund.UpdatedAt,
+			},
+			RefundID:            refund.ID,
+			TransactionDetailID: detail.ID,
+			Quantity:            quantity,
+			Amount:              amount,
+		})
+	}
+
+	err = repo.Create(refund)
+	if err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// complete records a refund the provider made on its locked transaction.
+func (s *RefundService) complete(tx *sqlx.Tx, transaction *models.Transaction, refund *models.Refund, actor, reason string) error {
+	transactionRepo := s.transactionRepo.WithTx(tx)
+	historyRepo := s.historyRepo.WithTx(tx)
+
+	details, err := s.detailRepo.WithTx(tx).FindByTransactionIdForUpdate(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	byId := make(map[uuid.UUID]models.TransactionDetail, len(details))
+	fullyRefunded := true
+	for _, detail := range details {
+		byId[detail.ID] = detail
+		if detail.RefundedQuantity < detail.Quantity {
+			fullyRefunded = false
+		}
+	}
+
+	restored := make(map[uuid.UUID]int)
+	for _, item := range refund.Items {
+		restored[byId[item.TransactionDetailID].TicketTypeID] += item.Quantity
+	}
+
+	err = restoreQuota(s.ticketTypeRepo.WithTx(tx), restored)
+	if err != nil {
+		return err
+	}
+
+	// Seats are released after the quota, as a checkout locks them after
+	// its ticket types
+	for _, item := range refund.Items {
+		err = s.tickets.releaseRefunded(tx, item.TransactionDetailID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fullyRefunded {
+		err = changePaymentStatus(transactionRepo, historyRepo, transaction, models.PaymentStatusRefunded, actor, reason)
+		if err != nil {
+			return err
+		}
+		err = changeTransactionStatus(transactionRepo, historyRepo, transaction, models.TransactionStatusCancelled, actor, reason)
+		if err != nil {
+			return err
+		}
+	} else if transaction.PaymentStatus != models.PaymentStatusPartiallyRefunded {
+		err = changePaymentStatus(transactionRepo, historyRepo, transaction, models.PaymentStatusPartiallyRefunded, actor, reason)
+		if err != nil {
+			return err
+		}
+	}
+
+	refund.Status = models.RefundStatusSucceeded
+	return s.repo.WithTx(tx).Settle(refund)
+}
+
+// fail records a refund the provider turned down and makes its tickets
+// valid again.
+func (s *RefundService) fail(tx *sqlx.Tx, refund *models.Refund, refundErr error) error {
+	detailRepo := s.detailRepo.WithTx(tx)
+
+	for _, item := range refund.Items {
+		err := detailRepo.RemoveRefundedQuantity(item.TransactionDetailID, item.Quantity)
+		if err != nil {
+			return err
+		}
+		err = s.tickets.restoreRefunded(tx, item.TransactionDetailID, item.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	failureReason := refundErr.Error()
+	refund.Status = models.RefundStatusFailed
+	refund.FailureReason = &failureReason
+	return s.repo.WithTx(tx).Settle(refund)
+}
+
+// refundQuantities validates the requested items against the transaction's
+// details and returns the quantity to refund per detail ID.
+func refundQuantities(details []models.TransactionDetail, items []RefundItemRequest) (map[uuid.UUID]int, error) {
+	quantities := make(map[uuid.UUID]int)
+
+	if len(items) == 0 {
+		for _, detail := range details {
+			if remaining := detail.Quantity - detail.RefundedQuantity; remaining > 0 {
+				quantities[detail.ID] = remaining
+			}
+		}
+		if len(quantities) == 0 {
+			return nil, fmt.Errorf("%w: nothing left to refund", ErrInvalidRefund)
+		}
+		return quantities, nil
+	}
+
+	byId := make(map[uuid.UUID]models.TransactionDetail, len(details))
+	for _, detail := range details {
+		byId[detail.ID] = detail
+	}
+
+	for _, item := range items {
+		detail, ok := byId[item.TransactionDetailID]
+		if !ok {
+			return nil, fmt.Errorf("%w: detail %s does not belong to the transaction", ErrInvalidRefund, item.TransactionDetailID)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidRefund)
+		}
+
+		quantities[detail.ID] += item.Quantity
+		if quantities[detail.ID] > detail.Quantity-detail.RefundedQuantity {
+			return nil, fmt.Errorf("%w: only %d tickets of detail %s can be refunded", ErrInvalidRefund, detail.Quantity-detail.RefundedQuantity, detail.ID)
+		}
+	}
+
+	return quantities, nil
+}
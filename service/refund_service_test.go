@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"go-ticket/models"
+	"go-ticket/payment"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// buyPaid buys quantity tickets of sale for p and pays for them through
+// the fake gateway, which issues the tickets.
+func buyPaid(t *testing.T, services *testServices, db *sqlx.DB, sale saleFixture, p *Principal, quantity int) uuid.UUID {
+	t.Helper()
+
+	transaction, err := services.transactions.CreateTransaction(p, &CreateTransactionRequest{
+		EventID:       sale.eventID,
+		PaymentMethod: models.PaymentMethodBankTransfer,
+		Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: quantity}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reference string
+	err = db.Get(&reference, `SELECT payment_reference FROM transactions WHERE id = $1`, transaction.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = services.gateway.Simulate(reference, payment.ChargeStatusPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return transaction.ID
+}
+
+// refundState is what a refund leaves behind in the database.
+type refundState struct {
+	RemainingQuota int    `db:"remaining_quota"`
+	Refunded       int    `db:"refunded_quantity"`
+	ValidTickets   int    `db:"valid_tickets"`
+	PaymentStatus  string `db:"payment_status"`
+}
+
+func loadRefundState(t *testing.T, db *sqlx.DB, transactionId uuid.UUID) refundState {
+	t.Helper()
+
+	var state refundState
+	err := db.Get(&state, `
+		SELECT tt.remaining_quota, d.refunded_quantity, tr.payment_status,
+			(SELECT COUNT(*) FROM tickets WHERE transaction_id = tr.id AND status = 'valid') AS valid_tickets
+		FROM transactions tr
+		JOIN transaction_details d ON d.transaction_id = tr.id
+		JOIN ticket_types tt ON tt.id = d.ticket_type_id
+		WHERE tr.id = $1
+	`, transactionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestRefundTransaction(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	admin := NewPrincipal(&models.User{BaseModel: models.BaseModel{ID: uuid.New()}}, []string{"admin"},
+		[]string{PermissionTransactionsManage}, nil)
+
+	t.Run("a refund the provider makes frees the tickets", func(t *testing.T) {
+		sale := seedSale(t, db, 10)
+		transactionId := buyPaid(t, services, db, sale, seedBuyer(t, db), 2)
+
+		refund, err := services.refunds.RefundTransaction(admin, transactionId, &RefundTransactionRequest{Reason: "asked"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refund.Status != models.RefundStatusSucceeded || refund.ProviderReference == nil {
+			t.Errorf("refund status = %s, provider reference = %v, want succeeded with a reference", refund.Status, refund.ProviderReference)
+		}
+
+		want := refundState{RemainingQuota: 10, Refunded: 2, ValidTickets: 0, PaymentStatus: string(models.PaymentStatusRefunded)}
+		if got := loadRefundState(t, db, transactionId); got != want {
+			t.Errorf("after the refund %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("a refund the provider turns down is recorded as failed", func(t *testing.T) {
+		sale := seedSale(t, db, 10)
+		transactionId := buyPaid(t, services, db, sale, seedBuyer(t, db), 2)
+
+		// The provider does not know the charge any more
+		_, err := db.Exec(`UPDATE transactions SET payment_reference = 'fake_missing' WHERE id = $1`, transactionId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = services.refunds.RefundTransaction(admin, transactionId, &RefundTransactionRequest{Reason: "asked"})
+		if !errors.Is(err, payment.ErrChargeNotFound) {
+			t.Fatalf("RefundTransaction() error = %v, want %v", err, payment.ErrChargeNotFound)
+		}
+
+		want := refundState{RemainingQuota: 8, Refunded: 0, ValidTickets: 2, PaymentStatus: string(models.PaymentStatusPaid)}
+		if got := loadRefundState(t, db, transactionId); got != want {
+			t.Errorf("after the failed refund %+v, want %+v", got, want)
+		}
+
+		var status models.RefundStatus
+		err = db.Get(&status, `SELECT status FROM refunds WHERE transaction_id = $1`, transactionId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != models.RefundStatusFailed {
+			t.Errorf("refund status = %s, want %s", status, models.RefundStatusFailed)
+		}
+	})
+}
+
+func TestRefundPostponed(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	sale := seedSale(t, db, 10)
+	buyer := seedBuyer(t, db)
+	transactionId := buyPaid(t, services, db, sale, buyer, 1)
+
+	// An event back on sale keeps the refund deadline of its postponement
+	_, err := db.Exec(`UPDATE events SET refund_deadline = NOW() + INTERVAL '1 day' WHERE id = $1`, sale.eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.refunds.RefundPostponed(buyer, transactionId); !errors.Is(err, ErrEventNotPostponed) {
+		t.Errorf("RefundPostponed() of an event on sale error = %v, want %v", err, ErrEventNotPostponed)
+	}
+
+	_, err = db.Exec(`UPDATE events SET status = $1 WHERE id = $2`, models.EventStatusPostponed, sale.eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := services.refunds.RefundPostponed(buyer, transactionId); err != nil {
+		t.Errorf("RefundPostponed() of a postponed event error = %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// sweepBatchSize caps how many expired holds a single sweep releases, so a
+// large backlog is drained over several ticks instead of one long pass.
+const sweepBatchSize = 100
+
+type ReservationService struct {
+	uow            *repository.UnitOfWork
+	repo           *repository.TransactionRepository
+	detailRepo     *repository.TransactionDetailRepository
+	ticketTypeRepo *repository.TicketTypeRepository
+	eventSeatRepo  *repository.EventSeatRepository
+	promoCodeRepo  *repository.PromoCodeRepository
+	historyRepo    *repository.TransactionStatusHistoryRepository
+	holdTTL        time.Duration
+}
+
+func NewReservationService(
+	uow *repository.UnitOfWork,
+	repo *repository.TransactionRepository,
+	detailRepo *repository.TransactionDetailRepository,
+	ticketTypeRepo *repository.TicketTypeRepository,
+	eventSeatRepo *repository.EventSeatRepository,
+	promoCodeRepo *repository.PromoCodeRepository,
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	holdTTL time.Duration,
+) *ReservationService {
+	return &ReservationService{
+		uow:            uow,
+		repo:           repo,
+		detailRepo:     detailRepo,
+		ticketTypeRepo: ticketTypeRepo,
+		eventSeatRepo:  eventSeatRepo,
+		promoCodeRepo:  promoCodeRepo,
+		historyRepo:    historyRepo,
+		holdTTL:        holdTTL,
+	}
+}
+
+// Hold stamps a new transaction with the time its quota is released if it
+// has not been paid.
+func (s *ReservationService) Hold(transaction *models.Transaction) {
+	expiresAt := transaction.CreatedAt.Add(s.holdTTL)
+	transaction.ExpiresAt = &expiresAt
+}
+
+// StartSweeper releases expired holds every interval until ctx is done.
+func (s *ReservationService) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := s.ReleaseExpired(time.Now())
+			if err != nil {
+				log.Printf("Failed to release expired holds: %v", err)
+			}
+			if released > 0 {
+				log.Printf("Released %d expired holds", released)
+			}
+		}
+	}
+}
+
+// ReleaseExpired cancels pending transactions whose hold expired at or
+// before now and returns their quantities to the ticket types. Each
+// transaction is released in its own database transaction so one failure
+// does not block the rest of the batch.
+func (s *ReservationService) ReleaseExpired(now time.Time) (int, error) {
+	ids, err := s.repo.FindExpiredPendingIds(now, sweepBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	var lastErr error
+	for _, id := range ids {
+		err := s.uow.Do(func(tx *sqlx.Tx) error {
+			return s.releaseExpired(tx, id, now)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Paid, cancelled or picked up by another sweeper in the meantime
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		released++
+	}
+
+	return released, lastErr
+}
+
+func (s *ReservationService) releaseExpired(tx *sqlx.Tx, id uuid.UUID, now time.Time) error {
+	transaction, err := s.repo.WithTx(tx).FindExpiredPendingForUpdate(id, now)
+	if err != nil {
+		return err
+	}
+
+	return s.release(tx, transaction, systemActor, "hold expired")
+}
+
+// release cancels a locked transaction and returns every ticket it still
+// holds to the ticket types, along with the seats it held and the promo
+// code it redeemed. They are released in the order a checkout takes
+// them: ticket types, then the promo code, then seats.
+func (s *ReservationService) release(tx *sqlx.Tx, transaction *models.Transaction, actor, reason string) error {
+	details, err := s.detailRepo.WithTx(tx).FindByTransactionId(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	err = restoreQuota(s.ticketTypeRepo.WithTx(tx), heldQuantities(details))
+	if err != nil {
+		return err
+	}
+
+	err = s.promoCodeRepo.WithTx(tx).ReleaseRedemption(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	err = s.eventSeatRepo.WithTx(tx).ReleaseHeld(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	return changeTransactionStatus(s.repo.WithTx(tx), s.historyRepo.WithTx(tx), transaction, models.TransactionStatusCancelled, actor, reason)
+}
+
+// heldQuantities sums, per ticket type, the tickets of details that have
+// not been refunded yet.
+func heldQuantities(details []models.TransactionDetail) map[uuid.UUID]int {
+	quantities := make(map[uuid.UUID]int)
+	for _, detail := range details {
+		quantities[detail.TicketTypeID] += detail.Quantity - detail.RefundedQuantity
+	}
+	return quantities
+}
+
+// restoreQuota returns quantities to their ticket types, updating them in
+// ID order like lockTicketTypes. Callers restore quota before they give
+// back the promo code or seats of the tickets, which a checkout takes
+// after locking its ticket types, so both take their locks in the same
+// order.
+func restoreQuota(ticketTypeRepo *repository.TicketTypeRepository, quantities map[uuid.UUID]int) error {
+	ids := make([]uuid.UUID, 0, len(quantities))
+	for id, quantity := range quantities {
+		if quantity > 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	for _, id := range ids {
+		err := ticketTypeRepo.RestoreQuota(id, quantities[id])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
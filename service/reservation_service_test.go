@@ -0,0 +1,113 @@
+package service
+
+import (
+	"go-ticket/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TestReleaseRacesCheckout releases expired holds that redeemed a promo
+// code while buyers check out with the same code. A release that took the
+// promo code before the ticket type would deadlock against a checkout,
+// which locks them the other way round.
+func TestReleaseRacesCheckout(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	sale := seedSale(t, db, 10000)
+
+	const (
+		rounds   = 5
+		buyers   = 8
+		holds    = 40
+		checkout = 5
+	)
+	principals := make([]*Principal, buyers)
+	for i := range principals {
+		principals[i] = seedBuyer(t, db)
+	}
+
+	buy := func(p *Principal) error {
+		_, err := services.transactions.CreateTransaction(p, &CreateTransactionRequest{
+			EventID:       sale.eventID,
+			PaymentMethod: models.PaymentMethodBankTransfer,
+			Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: 1}},
+			PromoCode:     sale.promoCode,
+		}, nil)
+		return err
+	}
+
+	for round := 0; round < rounds; round++ {
+		for i := 0; i < holds; i++ {
+			if err := buy(principals[i%buyers]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := db.Exec(`UPDATE transactions SET expires_at = NOW() - INTERVAL '1 minute' WHERE status = 'pending'`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, buyers*checkout+1)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := services.reservations.ReleaseExpired(time.Now())
+			errs <- err
+		}()
+		for _, p := range principals {
+			wg.Add(1)
+			go func(p *Principal) {
+				defer wg.Done()
+				for i := 0; i < checkout; i++ {
+					errs <- buy(p)
+				}
+			}(p)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("round %d: %v", round+1, err)
+			}
+		}
+	}
+
+	assertHeldTickets(t, db, sale)
+}
+
+// assertHeldTickets checks that the quota and the redemptions of the code
+// account for exactly the tickets of the transactions still pending.
+func assertHeldTickets(t *testing.T, db *sqlx.DB, sale saleFixture) {
+	t.Helper()
+
+	var held struct {
+		Quota          int `db:"quota"`
+		RemainingQuota int `db:"remaining_quota"`
+		Redemptions    int `db:"redemption_count"`
+		Pending        int `db:"pending"`
+		Redeemed       int `db:"redeemed"`
+	}
+	err := db.Get(&held, `
+		SELECT tt.quota, tt.remaining_quota, pc.redemption_count,
+			(SELECT COUNT(*) FROM transactions WHERE status = 'pending') AS pending,
+			(SELECT COUNT(*) FROM promo_code_redemptions WHERE promo_code_id = pc.id) AS redeemed
+		FROM ticket_types tt, promo_codes pc
+		WHERE tt.id = $1 AND pc.organizer_id = $2
+	`, sale.ticketTypeID, sale.organizerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if held.Quota-held.RemainingQuota != held.Pending {
+		t.Errorf("%d tickets are taken from the quota, want the %d of the pending transactions", held.Quota-held.RemainingQuota, held.Pending)
+	}
+	if held.Redemptions != held.Pending || held.Redeemed != held.Pending {
+		t.Errorf("the code counts %d redemptions and has %d, want the %d of the pending transactions", held.Redemptions, held.Redeemed, held.Pending)
+	}
+}
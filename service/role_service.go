@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+type RoleService struct {
+	uow      *repository.UnitOfWork
+	repo     *repository.RoleRepository
+	userRepo *repository.UserRepository
+}
+
+func NewRoleService(uow *repository.UnitOfWork, repo *repository.RoleRepository, userRepo *repository.UserRepository) *RoleService {
+	return &RoleService{
+		uow:      uow,
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+type UpdateUserRolesRequest struct {
+	Roles []string `json:"roles" validate:"required"`
+}
+
+func (s *RoleService) GetAllRoles(p *Principal) ([]models.Role, error) {
+	err := p.require(PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindAll()
+}
+
+func (s *RoleService) GetUserRoles(p *Principal, userId uuid.UUID) ([]string, error) {
+	err := p.requireSelfOr(userId, PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.userRepo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindNamesByUserId(userId)
+}
+
+// UpdateUserRoles replaces the roles of a user. Admins cannot drop their own
+// admin role, so the last admin cannot lock everyone out by accident.
+func (s *RoleService) UpdateUserRoles(p *Principal, userId uuid.UUID, req *UpdateUserRolesRequest) ([]string, error) {
+	err := p.require(PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := uniqueRoles(req.Roles)
+	if p.Is(userId) && !containsRole(roles, RoleAdmin) && containsRole(p.Roles, RoleAdmin) {
+		return nil, fmt.Errorf("%w: admins cannot remove their own admin role", ErrForbidden)
+	}
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		_, err := s.userRepo.WithTx(tx).FindById(userId)
+		if err != nil {
+			return err
+		}
+
+		granted, err := s.repo.WithTx(tx).ReplaceUserRoles(userId, roles)
+		if err != nil {
+			return err
+		}
+		if granted != len(roles) {
+			return fmt.Errorf("%w: %v", ErrUnknownRole, roles)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindNamesByUserId(userId)
+}
+
+// GrantRoleByEmail grants a role outside of any request, for bootstrapping
+// the first admin.
+func (s *RoleService) GrantRoleByEmail(email, role string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.repo.AddUserRole(user.ID, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	return nil
+}
+
+func uniqueRoles(roles []string) []string {
+	seen := make(map[string]bool, len(roles))
+	unique := []string{}
+	for _, role := range roles {
+		if !seen[role] {
+			seen[role] = true
+			unique = append(unique, role)
+		}
+	}
+	return unique
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -38,8 +38,8 @@ type SearchScheduleRequest struct {
 	EndDate   string `json:"end_date"`
 }
 
-func (s *ScheduleService) GetAllSchedules() ([]models.Schedule, error) {
-	return s.repo.FindAll()
+func (s *ScheduleService) GetAllSchedules(q *repository.ListQuery) (*repository.Page[models.Schedule], error) {
+	return s.repo.List(q)
 }
 
 func (s *ScheduleService) GetScheduleById(id uuid.UUID) (*models.Schedule, error) {
@@ -53,7 +53,12 @@ func (s *ScheduleService) GetScheduleById(id uuid.UUID) (*models.Schedule, error
 	return schedule, nil
 }
 
-func (s *ScheduleService) CreateSchedule(req *CreateScheduleRequest) (*models.Schedule, error) {
+func (s *ScheduleService) CreateSchedule(p *Principal, req *CreateScheduleRequest) (*models.Schedule, error) {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return nil, err
+	}
+
 	schedule := &models.Schedule{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
@@ -66,7 +71,7 @@ func (s *ScheduleService) CreateSchedule(req *CreateScheduleRequest) (*models.Sc
 		EndDate:     req.EndDate,
 	}
 
-	err := s.repo.Create(schedule)
+	err = s.repo.Create(schedule)
 	if err != nil {
 		return nil, err
 	}
@@ -74,7 +79,12 @@ func (s *ScheduleService) CreateSchedule(req *CreateScheduleRequest) (*models.Sc
 	return s.GetScheduleById(schedule.ID)
 }
 
-func (s *ScheduleService) UpdateSchedule(id uuid.UUID, req *UpdateScheduleRequest) (*models.Schedule, error) {
+func (s *ScheduleService) UpdateSchedule(p *Principal, id uuid.UUID, req *UpdateScheduleRequest) (*models.Schedule, error) {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return nil, err
+	}
+
 	schedule, err := s.GetScheduleById(id)
 	if err != nil {
 		return nil, err
@@ -93,7 +103,12 @@ func (s *ScheduleService) UpdateSchedule(id uuid.UUID, req *UpdateScheduleReques
 	return s.GetScheduleById(id)
 }
 
-func (s *ScheduleService) DeleteSchedule(id uuid.UUID) error {
+func (s *ScheduleService) DeleteSchedule(p *Principal, id uuid.UUID) error {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return err
+	}
+
 	schedule, err := s.GetScheduleById(id)
 	if err != nil {
 		return err
@@ -104,7 +119,7 @@ func (s *ScheduleService) DeleteSchedule(id uuid.UUID) error {
 
 func (s *ScheduleService) SearchSchedules(req *SearchScheduleRequest) ([]models.Schedule, error) {
 	if req.StartDate == "" || req.EndDate == "" {
-		return s.GetAllSchedules()
+		return s.repo.FindAll()
 	}
 
 	return s.repo.FindByDateRange(req.StartDate, req.EndDate)
@@ -0,0 +1,445 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"go-ticket/seating"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInvalidSeatMap = errors.New("invalid seat map")
+	ErrInvalidSeats   = errors.New("invalid seat selection")
+	// ErrSeatUnavailable is returned when a seat is held or sold by the
+	// time a checkout tries to hold it.
+	ErrSeatUnavailable = errors.New("seat is not available")
+)
+
+// SeatMapService manages the seat maps of locations and the seats events
+// sell under their ticket types. A ticket type with seats bound to it is
+// seated: its quota is its number of seats, and buyers pick their seats at
+// checkout.
+type SeatMapService struct {
+	uow            *repository.UnitOfWork
+	repo           *repository.SeatMapRepository
+	eventSeatRepo  *repository.EventSeatRepository
+	locationRepo   *repository.LocationRepository
+	eventRepo      *repository.EventRepository
+	ticketTypeRepo *repository.TicketTypeRepository
+}
+
+func NewSeatMapService(
+	uow *repository.UnitOfWork,
+	repo *repository.SeatMapRepository,
+	eventSeatRepo *repository.EventSeatRepository,
+	locationRepo *repository.LocationRepository,
+	eventRepo *repository.EventRepository,
+	ticketTypeRepo *repository.TicketTypeRepository,
+) *SeatMapService {
+	return &SeatMapService{
+		uow:            uow,
+		repo:           repo,
+		eventSeatRepo:  eventSeatRepo,
+		locationRepo:   locationRepo,
+		eventRepo:      eventRepo,
+		ticketTypeRepo: ticketTypeRepo,
+	}
+}
+
+type SeatRequest struct {
+	Number     int     `json:"number" validate:"required"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Accessible bool    `json:"accessible"`
+	Obstructed bool    `json:"obstructed"`
+	Aisle      bool    `json:"aisle"`
+}
+
+type SeatRowRequest struct {
+	Label string        `json:"label" validate:"required,max=20"`
+	Seats []SeatRequest `json:"seats" validate:"required,min=1"`
+}
+
+type SeatSectionRequest struct {
+	Name string           `json:"name" validate:"required"`
+	Rows []SeatRowRequest `json:"rows" validate:"required,min=1"`
+}
+
+// CreateSeatMapRequest describes a whole seat map. Sections keep the order
+// they are listed in.
+type CreateSeatMapRequest struct {
+	Name     string               `json:"name" validate:"required"`
+	StageX   float64              `json:"stage_x"`
+	StageY   float64              `json:"stage_y"`
+	Sections []SeatSectionRequest `json:"sections" validate:"required,min=1"`
+}
+
+type SeatSelectionRequest struct {
+	SeatIDs []uuid.UUID `json:"seat_ids" validate:"required,min=1"`
+}
+
+func (s *SeatMapService) GetSeatMapsByLocationId(locationId uuid.UUID) ([]models.SeatMap, error) {
+	return s.repo.FindByLocationId(locationId)
+}
+
+func (s *SeatMapService) GetSeatMapById(id uuid.UUID) (*models.SeatMap, error) {
+	return s.repo.FindWithSeats(id)
+}
+
+// CreateSeatMap creates a seat map of a location of one of the principal's
+// organizers, with all its sections and seats.
+func (s *SeatMapService) CreateSeatMap(p *Principal, locationId uuid.UUID, req *CreateSeatMapRequest) (*models.SeatMap, error) {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return nil, err
+	}
+
+	locationRepo := s.locationRepo
+	if organizerIds, scoped := p.tenant(); scoped {
+		locationRepo = locationRepo.ForTenant(organizerIds)
+	}
+
+	location, err := locationRepo.FindById(locationId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateSeatMap(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	seatMap := &models.SeatMap{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		LocationID:  location.ID,
+		OrganizerID: location.OrganizerID,
+		Name:        req.Name,
+		StageX:      req.StageX,
+		StageY:      req.StageY,
+	}
+
+	var sections []models.SeatSection
+	var seats []models.Seat
+	for i, sectionReq := range req.Sections {
+		section := models.SeatSection{
+			ID:        uuid.New(),
+			SeatMapID: seatMap.ID,
+			Name:      sectionReq.Name,
+			Position:  i,
+			CreatedAt: now,
+		}
+		sections = append(sections, section)
+
+		for _, row := range sectionReq.Rows {
+			for _, seat := range row.Seats {
+				seats = append(seats, models.Seat{
+					ID:         uuid.New(),
+					SeatMapID:  seatMap.ID,
+					SectionID:  section.ID,
+					Row:        row.Label,
+					Number:     seat.Number,
+					X:          seat.X,
+					Y:          seat.Y,
+					Accessible: seat.Accessible,
+					Obstructed: seat.Obstructed,
+					Aisle:      seat.Aisle,
+					CreatedAt:  now,
+				})
+			}
+		}
+	}
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		err := repo.Create(seatMap)
+		if err != nil {
+			return err
+		}
+
+		err = repo.BulkCreateSections(sections)
+		if err != nil {
+			return err
+		}
+
+		return repo.BulkCreateSeats(seats)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindWithSeats(seatMap.ID)
+}
+
+func (s *SeatMapService) DeleteSeatMap(p *Principal, id uuid.UUID) error {
+	err := p.require(PermissionCatalogManage)
+	if err != nil {
+		return err
+	}
+
+	repo := s.repo
+	if organizerIds, scoped := p.tenant(); scoped {
+		repo = repo.ForTenant(organizerIds)
+	}
+
+	seatMap, err := repo.FindById(id)
+	if err != nil {
+		return err
+	}
+
+	return repo.Delete(seatMap.ID)
+}
+
+// GetEventSeats lists the seats an event sells with their availability.
+// Like the event itself, the seats of an unlisted event are only shown to
+// its organizers.
+func (s *SeatMapService) GetEventSeats(p *Principal, eventId uuid.UUID) ([]models.EventSeat, error) {
+	event, err := s.eventRepo.FindById(eventId)
+	if err != nil {
+		return nil, err
+	}
+	if !event.Status.Listed() && !p.ownsEvent(event) {
+		return nil, sql.ErrNoRows
+	}
+
+	return s.eventSeatRepo.FindByEventId(eventId, nil)
+}
+
+// BindSeats puts seats of the event's location on sale under a ticket type
+// and raises its quota by as many. Binding the first seats turns a ticket
+// type without sales into a seated one, whose quota counts only seats.
+func (s *SeatMapService) BindSeats(p *Principal, ticketTypeId uuid.UUID, req *SeatSelectionRequest) ([]models.EventSeat, error) {
+	seatIds, err := uniqueSeatIds(req.SeatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventSeats []models.EventSeat
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		ticketTypeRepo := s.ticketTypeRepo.WithTx(tx)
+		eventSeatRepo := s.eventSeatRepo.WithTx(tx)
+
+		ticketType, event, err := s.lockOwnedTicketType(tx, p, ticketTypeId)
+		if err != nil {
+			return err
+		}
+
+		bound, err := eventSeatRepo.CountByTicketTypeId(ticketType.ID)
+		if err != nil {
+			return err
+		}
+
+		delta := 0
+		if bound == 0 {
+			if ticketType.Quota != ticketType.RemainingQuota {
+				return fmt.Errorf("%w: ticket types with tickets sold cannot become seated", ErrInvalidSeats)
+			}
+			delta = -ticketType.Quota
+		}
+
+		added, err := eventSeatRepo.Bind(event.ID, ticketType.ID, event.LocationID, seatIds)
+		if err != nil {
+			return err
+		}
+		if added != len(seatIds) {
+			return fmt.Errorf("%w: %d of the seats are not at the event's location or are already on sale", ErrInvalidSeats, len(seatIds)-added)
+		}
+
+		err = ticketTypeRepo.AdjustQuota(ticketType.ID, delta+added)
+		if err != nil {
+			return err
+		}
+
+		eventSeats, err = eventSeatRepo.FindByEventId(event.ID, &ticketType.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return eventSeats, nil
+}
+
+// UnbindSeats takes available seats of a ticket type off sale and lowers
+// its quota by as many.
+func (s *SeatMapService) UnbindSeats(p *Principal, ticketTypeId uuid.UUID, req *SeatSelectionRequest) ([]models.EventSeat, error) {
+	seatIds, err := uniqueSeatIds(req.SeatIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var eventSeats []models.EventSeat
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		eventSeatRepo := s.eventSeatRepo.WithTx(tx)
+
+		ticketType, event, err := s.lockOwnedTicketType(tx, p, ticketTypeId)
+		if err != nil {
+			return err
+		}
+
+		removed, err := eventSeatRepo.Unbind(ticketType.ID, seatIds)
+		if err != nil {
+			return err
+		}
+		if removed != len(seatIds) {
+			return fmt.Errorf("%w: only available seats of the ticket type can be unbound", ErrInvalidSeats)
+		}
+
+		err = s.ticketTypeRepo.WithTx(tx).AdjustQuota(ticketType.ID, -removed)
+		if err != nil {
+			return err
+		}
+
+		eventSeats, err = eventSeatRepo.FindByEventId(event.ID, &ticketType.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return eventSeats, nil
+}
+
+// lockOwnedTicketType locks a ticket type whose event the principal may
+// manage, which also keeps checkouts of the ticket type waiting until the
+// seats are rebound.
+func (s *SeatMapService) lockOwnedTicketType(tx *sqlx.Tx, p *Principal, ticketTypeId uuid.UUID) (*models.TicketType, *models.Event, error) {
+	ticketType, err := s.ticketTypeRepo.WithTx(tx).FindByIdForUpdate(ticketTypeId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	event, err := s.eventRepo.WithTx(tx).FindById(ticketType.EventID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = p.requireEventOwner(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ticketType, event, nil
+}
+
+func validateSeatMap(req *CreateSeatMapRequest) error {
+	if req.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSeatMap)
+	}
+	if len(req.Sections) == 0 {
+		return fmt.Errorf("%w: at least one section is required", ErrInvalidSeatMap)
+	}
+
+	sectionNames := make(map[string]bool, len(req.Sections))
+	for _, section := range req.Sections {
+		if section.Name == "" {
+			return fmt.Errorf("%w: every section needs a name", ErrInvalidSeatMap)
+		}
+		if sectionNames[section.Name] {
+			return fmt.Errorf("%w: section %s is listed twice", ErrInvalidSeatMap, section.Name)
+		}
+		sectionNames[section.Name] = true
+
+		if len(section.Rows) == 0 {
+			return fmt.Errorf("%w: section %s has no rows", ErrInvalidSeatMap, section.Name)
+		}
+
+		type seatKey struct {
+			row    string
+			number int
+		}
+		numbers := make(map[seatKey]bool)
+		for _, row := range section.Rows {
+			if row.Label == "" || len(row.Label) > 20 {
+				return fmt.Errorf("%w: row labels in section %s must be 1 to 20 characters", ErrInvalidSeatMap, section.Name)
+			}
+			if len(row.Seats) == 0 {
+				return fmt.Errorf("%w: row %s of section %s has no seats", ErrInvalidSeatMap, row.Label, section.Name)
+			}
+			for _, seat := range row.Seats {
+				key := seatKey{row.Label, seat.Number}
+				if numbers[key] {
+					return fmt.Errorf("%w: seat %s%d of section %s is listed twice", ErrInvalidSeatMap, row.Label, seat.Number, section.Name)
+				}
+				numbers[key] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// allocate picks the best available seats for every detail of a seated
+// ticket type that picked none, in the order of details, leaving alone the
+// seats other details picked. The ticket types must be locked, so that the
+// seats stay available until they are held.
+func (s *SeatMapService) allocate(tx *sqlx.Tx, eventId uuid.UUID, details []TransactionDetailRequest, seated map[uuid.UUID]bool) error {
+	var pending []int
+	for i, detail := range details {
+		if seated[detail.TicketTypeID] && len(detail.SeatIDs) == 0 {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	eventSeats, err := s.eventSeatRepo.WithTx(tx).FindByEventId(eventId, nil)
+	if err != nil {
+		return err
+	}
+
+	seatMaps, err := s.repo.WithTx(tx).FindByEventId(eventId)
+	if err != nil {
+		return err
+	}
+
+	stages := make(map[uuid.UUID]seating.Point, len(seatMaps))
+	for _, seatMap := range seatMaps {
+		stages[seatMap.ID] = seating.Point{X: seatMap.StageX, Y: seatMap.StageY}
+	}
+
+	allocator := seating.NewAllocator(eventSeats, stages)
+	for _, detail := range details {
+		allocator.Take(detail.SeatIDs)
+	}
+
+	for _, i := range pending {
+		detail := &details[i]
+		seatIds, ok := allocator.Allocate(detail.TicketTypeID, detail.SectionID, detail.Quantity)
+		if !ok {
+			return fmt.Errorf("%w: fewer than %d seats are left for ticket type %s", ErrSeatUnavailable, detail.Quantity, detail.TicketTypeID)
+		}
+		detail.SeatIDs = seatIds
+	}
+
+	return nil
+}
+
+// uniqueSeatIds rejects an empty selection or one naming a seat twice.
+func uniqueSeatIds(seatIds []uuid.UUID) ([]uuid.UUID, error) {
+	if len(seatIds) == 0 {
+		return nil, fmt.Errorf("%w: no seats selected", ErrInvalidSeats)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(seatIds))
+	for _, id := range seatIds {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: seat %s is selected twice", ErrInvalidSeats, id)
+		}
+		seen[id] = true
+	}
+
+	return seatIds, nil
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"go-ticket/ticketing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrTicketVoid       = errors.New("ticket is void")
+	ErrTicketsNotIssued = errors.New("transaction has no issued tickets")
+	// ErrTransactionNotPaid is returned when tickets are issued for a
+	// transaction that is not both confirmed and paid.
+	ErrTransactionNotPaid = errors.New("transaction is not confirmed and paid")
+)
+
+// ticketCodeEncoding renders ticket codes with A-Z and 2-7 only, so codes
+// read out at the door never mix up 0 and O or 1 and I.
+var ticketCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TicketService struct {
+	repo            *repository.TicketRepository
+	transactionRepo *repository.TransactionRepository
+	detailRepo      *repository.TransactionDetailRepository
+	userRepo        *repository.UserRepository
+	eventRepo       *repository.EventRepository
+	ticketTypeRepo  *repository.TicketTypeRepository
+	eventSeatRepo   *repository.EventSeatRepository
+	signer          *ticketing.Signer
+}
+
+func NewTicketService(
+	repo *repository.TicketRepository,
+	transactionRepo *repository.TransactionRepository,
+	detailRepo *repository.TransactionDetailRepository,
+	userRepo *repository.UserRepository,
+	eventRepo *repository.EventRepository,
+	ticketTypeRepo *repository.TicketTypeRepository,
+	eventSeatRepo *repository.EventSeatRepository,
+	signer *ticketing.Signer,
+) *TicketService {
+	return &TicketService{
+		repo:            repo,
+		transactionRepo: transactionRepo,
+		detailRepo:      detailRepo,
+		userRepo:        userRepo,
+		eventRepo:       eventRepo,
+		ticketTypeRepo:  ticketTypeRepo,
+		eventSeatRepo:   eventSeatRepo,
+		signer:          signer,
+	}
+}
+
+type UpdateTicketHolderRequest struct {
+	HolderName  *string `json:"holder_name"`
+	HolderEmail *string `json:"holder_email" validate:"omitempty,email"`
+}
+
+func (s *TicketService) GetTicketsByTransactionId(p *Principal, transactionId uuid.UUID) ([]models.Ticket, error) {
+	transaction, err := s.transactionRepo.FindById(transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(transaction.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.FindByTransactionId(transactionId)
+}
+
+func (s *TicketService) GetTicketsByUserId(p *Principal, userId uuid.UUID, q *repository.ListQuery) (*repository.Page[models.Ticket], error) {
+	err := p.requireSelfOr(userId, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.userRepo.FindById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListByUserId(userId, q)
+}
+
+func (s *TicketService) UpdateTicketHolder(p *Principal, id uuid.UUID, req *UpdateTicketHolderRequest) (*models.Ticket, error) {
+	ticket, err := s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(ticket.UserID, PermissionTransactionsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.Status == models.TicketStatusVoid {
+		return nil, fmt.Errorf("%w: cannot change its holder", ErrTicketVoid)
+	}
+
+	ticket.HolderName = req.HolderName
+	ticket.HolderEmail = req.HolderEmail
+	ticket.UpdatedAt = time.Now()
+
+	err = s.repo.UpdateHolder(ticket)
+	if err != nil {
+		return nil, err
+	}
+
+	return ticket, nil
+}
+
+// GetTicketQRCode renders the signed payload of a ticket as a PNG QR code.
+func (s *TicketService) GetTicketQRCode(p *Principal, code string) ([]byte, error) {
+	ticket, err := s.repo.FindByCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(ticket.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	if ticket.Status == models.TicketStatusVoid {
+		return nil, ErrTicketVoid
+	}
+
+	return ticketing.QRCode(s.token(ticket))
+}
+
+// RenderTransactionTickets renders every ticket of a transaction that has
+// not been voided as a page of a PDF.
+func (s *TicketService) RenderTransactionTickets(p *Principal, transactionId uuid.UUID) ([]byte, error) {
+	transaction, err := s.transactionRepo.FindById(transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(transaction.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets, err := s.repo.FindByTransactionId(transactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	var printable []models.Ticket
+	for _, ticket := range tickets {
+		if ticket.Status != models.TicketStatusVoid {
+			printable = append(printable, ticket)
+		}
+	}
+	if len(printable) == 0 {
+		return nil, ErrTicketsNotIssued
+	}
+
+	event, err := s.eventRepo.FindWithRelations(transaction.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticketTypes, err := s.ticketTypeRepo.FindByEventId(transaction.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticketTypeNames := make(map[uuid.UUID]string, len(ticketTypes))
+	for _, ticketType := range ticketTypes {
+		ticketTypeNames[ticketType.ID] = ticketType.Name
+	}
+
+	tokens := make(map[uuid.UUID]string, len(printable))
+	for i := range printable {
+		tokens[printable[i].ID] = s.token(&printable[i])
+	}
+
+	return ticketing.RenderPDF(event, ticketTypeNames, printable, tokens)
+}
+
+// token signs the QR payload of a ticket. The token is not bound to the
+// schedule of the event, which may still be postponed after the ticket is
+// printed; voiding the ticket is what stops it from admitting anyone.
+func (s *TicketService) token(ticket *models.Ticket) string {
+	return s.signer.Sign(ticketing.Claims{
+		TicketID:     ticket.ID,
+		EventID:      ticket.EventID,
+		TicketTypeID: ticket.TicketTypeID,
+	})
+}
+
+// issue creates one valid ticket per purchased and not refunded unit of a
+// paid transaction, each for one of the seats the transaction held when
+// its ticket type is seated, and marks those seats sold. Calling it again
+// for the same transaction is a no-op.
+func (s *TicketService) issue(tx *sqlx.Tx, transaction *models.Transaction) error {
+	if transaction.Status != models.TransactionStatusConfirmed || transaction.PaymentStatus != models.PaymentStatusPaid {
+		return fmt.Errorf("%w: transaction is %s and its payment %s", ErrTransactionNotPaid, transaction.Status, transaction.PaymentStatus)
+	}
+
+	repo := s.repo.WithTx(tx)
+	eventSeatRepo := s.eventSeatRepo.WithTx(tx)
+
+	details, err := s.detailRepo.WithTx(tx).FindByTransactionIdForUpdate(transaction.ID)
+	if err != nil {
+		return err
+	}
+
+	var tickets []models.Ticket
+	for _, detail := range details {
+		issued, err := repo.CountByTransactionDetailId(detail.ID)
+		if err != nil {
+			return err
+		}
+
+		seatIds, err := eventSeatRepo.FindUnissuedSeatIds(detail.ID)
+		if err != nil {
+			return err
+		}
+
+		err = eventSeatRepo.MarkSold(detail.ID)
+		if err != nil {
+			return err
+		}
+
+		for i := issued; i < detail.Quantity-detail.RefundedQuantity; i++ {
+			code, err := newTicketCode()
+			if err != nil {
+				return err
+			}
+
+			var seatId *uuid.UUID
+			if len(seatIds) > 0 {
+				seatId = &seatIds[0]
+				seatIds = seatIds[1:]
+			}
+
+			tickets = append(tickets, models.Ticket{
+				BaseModel: models.BaseModel{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
+				TransactionID:       transaction.ID,
+				TransactionDetailID: detail.ID,
+				TicketTypeID:        detail.TicketTypeID,
+				EventID:             transaction.EventID,
+				UserID:              transaction.UserID,
+				Code:                code,
+				Status:              models.TicketStatusValid,
+				SeatID:              seatId,
+			})
+		}
+	}
+
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	return repo.BulkCreate(tickets)
+}
+
+// voidRefunded voids quantity tickets of a transaction detail being
+// refunded. Their seats stay taken until the refund is made, see
+// releaseRefunded. A detail whose tickets have not been issued has nothing
+// to void, but tickets that were already used cannot be refunded.
+func (s *TicketService) voidRefunded(tx *sqlx.Tx, detailId uuid.UUID, quantity int) error {
+	repo := s.repo.WithTx(tx)
+
+	issued, err := repo.CountByTransactionDetailId(detailId)
+	if err != nil || issued == 0 {
+		return err
+	}
+
+	voided, err := repo.VoidValid(detailId, quantity)
+	if err != nil {
+		return err
+	}
+
+	if voided < quantity {
+		return fmt.Errorf("%w: only %d tickets of detail %s are still valid", ErrInvalidRefund, voided, detailId)
+	}
+
+	return nil
+}
+
+// releaseRefunded puts the seats of the refunded tickets of a transaction
+// detail back on sale.
+func (s *TicketService) releaseRefunded(tx *sqlx.Tx, detailId uuid.UUID) error {
+	return s.eventSeatRepo.WithTx(tx).ReleaseVoided(detailId)
+}
+
+// restoreRefunded makes the quantity tickets voidRefunded last voided of a
+// transaction detail valid again, when the provider did not refund them.
+func (s *TicketService) restoreRefunded(tx *sqlx.Tx, detailId uuid.UUID, quantity int) error {
+	repo := s.repo.WithTx(tx)
+
+	issued, err := repo.CountByTransactionDetailId(detailId)
+	if err != nil || issued == 0 {
+		return err
+	}
+
+	restored, err := repo.RestoreVoided(detailId, quantity)
+	if err != nil {
+		return err
+	}
+
+	if restored < quantity {
+		return fmt.Errorf("only %d voided tickets of detail %s could be restored", restored, detailId)
+	}
+
+	return nil
+}
+
+// newTicketCode returns 80 random bits encoded as 16 base32 characters.
+func newTicketCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return ticketCodeEncoding.EncodeToString(b), nil
+}
@@ -1,56 +1,252 @@
 package service
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"go-ticket/models"
 	"go-ticket/repository"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrInvalidPricePhases    = errors.New("invalid price phases")
+	ErrInvalidPurchaseLimits = errors.New("invalid purchase limits")
 )
 
 type TicketTypeService struct {
-	repo *repository.TicketTypeRepository
+	uow            *repository.UnitOfWork
+	repo           *repository.TicketTypeRepository
+	eventRepo      *repository.EventRepository
+	eventSeatRepo  *repository.EventSeatRepository
+	pricePhaseRepo *repository.PricePhaseRepository
 }
 
-func NewTicketTypeService(repo *repository.TicketTypeRepository) *TicketTypeService {
+func NewTicketTypeService(
+	uow *repository.UnitOfWork,
+	repo *repository.TicketTypeRepository,
+	eventRepo *repository.EventRepository,
+	eventSeatRepo *repository.EventSeatRepository,
+	pricePhaseRepo *repository.PricePhaseRepository,
+) *TicketTypeService {
 	return &TicketTypeService{
-		repo: repo,
+		uow:            uow,
+		repo:           repo,
+		eventRepo:      eventRepo,
+		eventSeatRepo:  eventSeatRepo,
+		pricePhaseRepo: pricePhaseRepo,
 	}
 }
 
 type CreateTicketTypeRequest struct {
-	EventID     uuid.UUID `json:"event_id" validate:"required"`
-	Name        string    `json:"name" validate:"required"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price" validate:"required,min=0"`
-	Quota       int       `json:"quota" validate:"required,min=1"`
+	EventID     uuid.UUID    `json:"event_id" validate:"required"`
+	Name        string       `json:"name" validate:"required"`
+	Description string       `json:"description"`
+	Price       models.Money `json:"price" validate:"required"`
+	Currency    string       `json:"currency"`
+	Quota       int          `json:"quota" validate:"required,min=1"`
+	SaleStart   *time.Time   `json:"sale_start"`
+	SaleEnd     *time.Time   `json:"sale_end"`
+	models.PurchaseLimits
 }
 
+// UpdateTicketTypeRequest leaves out what it does not change. A purchase
+// limit of 0 removes that limit.
 type UpdateTicketTypeRequest struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Price       *float64 `json:"price" validate:"omitempty,min=0"`
-	Quota       *int     `json:"quota" validate:"omitempty,min=1"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Price       *models.Money `json:"price"`
+	Currency    string        `json:"currency"`
+	Quota       *int          `json:"quota" validate:"omitempty,min=1"`
+	SaleStart   *time.Time    `json:"sale_start"`
+	SaleEnd     *time.Time    `json:"sale_end"`
+	models.PurchaseLimits
+}
+
+// PricePhaseRequest is one phase of ReplacePricePhasesRequest. Every phase
+// but the last needs an end, a sold limit or both.
+type PricePhaseRequest struct {
+	Name      string       `json:"name" validate:"required"`
+	Price     models.Money `json:"price" validate:"required"`
+	StartsAt  *time.Time   `json:"starts_at"`
+	EndsAt    *time.Time   `json:"ends_at"`
+	SoldLimit *int         `json:"sold_limit" validate:"omitempty,min=1"`
+}
+
+// ReplacePricePhasesRequest lists the phases of a ticket type in the order
+// they are tried in. An empty list goes back to the plain price.
+type ReplacePricePhasesRequest struct {
+	Phases []PricePhaseRequest `json:"phases"`
 }
 
-func (s *TicketTypeService) GetAllTicketTypes() ([]models.TicketType, error) {
-	return s.repo.FindAll()
+// GetAllTicketTypes lists the ticket types of listed events, along with
+// every ticket type of the principal's tenant when they manage its events.
+// Only principals that work with every organizer see them all.
+func (s *TicketTypeService) GetAllTicketTypes(p *Principal, q *repository.ListQuery) (*repository.Page[models.TicketType], error) {
+	organizerIds, scoped := p.tenant()
+	if !scoped {
+		return s.repo.List(q)
+	}
+
+	if !p.Can(PermissionEventsManage, PermissionEventsManageAny) {
+		organizerIds = nil
+	}
+	return s.repo.ListListed(q, organizerIds)
 }
 
-func (s *TicketTypeService) GetTicketTypeById(id uuid.UUID) (*models.TicketType, error) {
-	return s.repo.FindById(id)
+func (s *TicketTypeService) GetTicketTypeById(p *Principal, id uuid.UUID) (*models.TicketType, error) {
+	ticketType, err := s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.readRepo(p, ticketType.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.FindById(id)
 }
 
-func (s *TicketTypeService) GetTicketTypesByEventId(eventId uuid.UUID) ([]models.TicketType, error) {
-	return s.repo.FindByEventId(eventId)
+func (s *TicketTypeService) GetTicketTypesByEventId(p *Principal, eventId uuid.UUID, q *repository.ListQuery) (*repository.Page[models.TicketType], error) {
+	repo, err := s.readRepo(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.ListByEventId(eventId, q)
 }
 
-func (s *TicketTypeService) GetAvailableTicketTypes(eventId uuid.UUID) ([]models.TicketType, error) {
-	return s.repo.FindAvailable(eventId)
+// GetAvailableTicketTypes lists the ticket types of an event with quota
+// left, each with its current and next price phase.
+func (s *TicketTypeService) GetAvailableTicketTypes(p *Principal, eventId uuid.UUID, q *repository.ListQuery) (*repository.Page[models.TicketType], error) {
+	repo, err := s.readRepo(p, eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := repo.ListAvailable(eventId, q)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(page.Items))
+	for i, ticketType := range page.Items {
+		ids[i] = ticketType.ID
+	}
+
+	phases, err := s.pricePhaseRepo.FindByTicketTypeIds(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i := range page.Items {
+		ticketType := &page.Items[i]
+		ticketType.CurrentPhase, ticketType.NextPhase = models.CurrentPricePhase(phases[ticketType.ID], ticketType.Sold(), now)
+	}
+
+	return page, nil
 }
 
-func (s *TicketTypeService) CreateTicketType(req *CreateTicketTypeRequest) (*models.TicketType, error) {
+func (s *TicketTypeService) GetPricePhases(p *Principal, id uuid.UUID) ([]models.PricePhase, error) {
+	ticketType, err := s.GetTicketTypeById(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	phases, err := s.pricePhaseRepo.FindByTicketTypeId(ticketType.ID)
+	if err != nil {
+		return nil, err
+	}
+	if phases == nil {
+		phases = []models.PricePhase{}
+	}
+
+	return phases, nil
+}
+
+// ReplacePricePhases sets the price phases of a ticket type. The ticket
+// type is locked meanwhile, so a checkout prices its tickets with either
+// the old phases or the new ones. Tickets already bought keep their price.
+func (s *TicketTypeService) ReplacePricePhases(p *Principal, id uuid.UUID, req *ReplacePricePhasesRequest) ([]models.PricePhase, error) {
+	err := validatePricePhases(req.Phases)
+	if err != nil {
+		return nil, err
+	}
+
+	phases := make([]models.PricePhase, len(req.Phases))
+	for i, phase := range req.Phases {
+		phases[i] = models.PricePhase{
+			ID:           uuid.New(),
+			TicketTypeID: id,
+			Position:     i + 1,
+			Name:         phase.Name,
+			Price:        phase.Price,
+			StartsAt:     phase.StartsAt,
+			EndsAt:       phase.EndsAt,
+			SoldLimit:    phase.SoldLimit,
+			CreatedAt:    time.Now(),
+		}
+	}
+
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.lockingRepo(p, tx)
+
+		ticketType, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.ownedEvent(p, ticketType.EventID)
+		if err != nil {
+			return err
+		}
+
+		for i, phase := range phases {
+			_, err := phase.Price.In(ticketType.Currency)
+			if err != nil {
+				return fmt.Errorf("%w: the price of phase %s: %v", ErrInvalidPricePhases, phase.Name, err)
+			}
+			phases[i].Price.Currency = ticketType.Currency
+		}
+
+		return s.pricePhaseRepo.WithTx(tx).Replace(ticketType.ID, phases)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return phases, nil
+}
+
+func (s *TicketTypeService) CreateTicketType(p *Principal, req *CreateTicketTypeRequest) (*models.TicketType, error) {
+	event, err := s.ownedEvent(p, req.EventID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Price.IsNegative() {
+		return nil, errors.New("price cannot be negative")
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	price, err := req.Price.In(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validatePurchaseLimits(req.PurchaseLimits)
+	if err != nil {
+		return nil, err
+	}
+
 	ticketType := &models.TicketType{
 		BaseModel: models.BaseModel{
 			ID:        uuid.New(),
@@ -60,12 +256,22 @@ func (s *TicketTypeService) CreateTicketType(req *CreateTicketTypeRequest) (*mod
 		EventID:        req.EventID,
 		Name:           req.Name,
 		Description:    req.Description,
-		Price:          req.Price,
+		Price:          price,
+		Currency:       currency,
 		Quota:          req.Quota,
 		RemainingQuota: req.Quota,
+		OrganizerID:    event.OrganizerID,
+		SaleStart:      req.SaleStart,
+		SaleEnd:        req.SaleEnd,
+		PurchaseLimits: req.PurchaseLimits,
 	}
 
-	err := s.repo.Create(ticketType)
+	err = validateSaleWindow(ticketType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Create(ticketType)
 	if err != nil {
 		return nil, err
 	}
@@ -73,55 +279,252 @@ func (s *TicketTypeService) CreateTicketType(req *CreateTicketTypeRequest) (*mod
 	return ticketType, nil
 }
 
-func (s *TicketTypeService) UpdateTicketType(id uuid.UUID, req *UpdateTicketTypeRequest) (*models.TicketType, error) {
-	ticketType, err := s.repo.FindById(id)
+// UpdateTicketType changes a ticket type. Its row is locked meanwhile, so a
+// checkout cannot take tickets between reading the remaining quota and
+// writing the adjusted one back.
+func (s *TicketTypeService) UpdateTicketType(p *Principal, id uuid.UUID, req *UpdateTicketTypeRequest) (*models.TicketType, error) {
+	var ticketType *models.TicketType
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.lockingRepo(p, tx)
+
+		var err error
+		ticketType, err = repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.ownedEvent(p, ticketType.EventID)
+		if err != nil {
+			return err
+		}
+
+		if req.Name != "" {
+			ticketType.Name = req.Name
+		}
+
+		if req.Description != "" {
+			ticketType.Description = req.Description
+		}
+
+		if req.Price != nil || req.Currency != "" {
+			price := ticketType.Price
+			if req.Price != nil {
+				price = *req.Price
+			}
+			if price.IsNegative() {
+				return errors.New("price cannot be negative")
+			}
+
+			currency := ticketType.Currency
+			if req.Currency != "" {
+				currency = req.Currency
+			}
+			if currency != ticketType.Currency {
+				phases, err := s.pricePhaseRepo.WithTx(tx).FindByTicketTypeId(ticketType.ID)
+				if err != nil {
+					return err
+				}
+				if len(phases) > 0 {
+					return fmt.Errorf("%w: replace the price phases before changing the currency", ErrInvalidPricePhases)
+				}
+			}
+
+			price, err = price.In(currency)
+			if err != nil {
+				return err
+			}
+			ticketType.Price = price
+			ticketType.Currency = currency
+		}
+
+		if req.Quota != nil {
+			seats, err := s.eventSeatRepo.WithTx(tx).CountByTicketTypeId(ticketType.ID)
+			if err != nil {
+				return err
+			}
+			if seats > 0 && *req.Quota != ticketType.Quota {
+				return errors.New("the quota of a seated ticket type is its number of seats")
+			}
+			if *req.Quota < ticketType.Quota-ticketType.RemainingQuota {
+				return errors.New("new quota cannot be less than sold tickets")
+			}
+			quotaDiff := *req.Quota - ticketType.Quota
+			ticketType.Quota = *req.Quota
+			ticketType.RemainingQuota += quotaDiff
+		}
+
+		if req.SaleStart != nil {
+			ticketType.SaleStart = req.SaleStart
+		}
+
+		if req.SaleEnd != nil {
+			ticketType.SaleEnd = req.SaleEnd
+		}
+
+		if req.MaxPerTransaction != nil {
+			ticketType.MaxPerTransaction = limitOrNone(req.MaxPerTransaction)
+		}
+
+		if req.MaxPerUser != nil {
+			ticketType.MaxPerUser = limitOrNone(req.MaxPerUser)
+		}
+
+		err = validateSaleWindow(ticketType)
+		if err != nil {
+			return err
+		}
+
+		err = validatePurchaseLimits(ticketType.PurchaseLimits)
+		if err != nil {
+			return err
+		}
+
+		ticketType.UpdatedAt = time.Now()
+
+		return repo.Update(ticketType)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if req.Name != "" {
-		ticketType.Name = req.Name
+	return ticketType, nil
+}
+
+// DeleteTicketType soft deletes a ticket type nobody has bought or holds
+// tickets of. The row is locked while its quota is checked.
+func (s *TicketTypeService) DeleteTicketType(p *Principal, id uuid.UUID) error {
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.lockingRepo(p, tx)
+
+		ticketType, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.ownedEvent(p, ticketType.EventID)
+		if err != nil {
+			return err
+		}
+
+		if ticketType.Quota != ticketType.RemainingQuota {
+			return errors.New("cannot delete ticket type with sold tickets")
+		}
+
+		return repo.Delete(ticketType.ID)
+	})
+}
+
+// validatePricePhases rejects a phase that never ends before the last one,
+// as the phases after it would never apply.
+func validatePricePhases(phases []PricePhaseRequest) error {
+	for i, phase := range phases {
+		if phase.Name == "" {
+			return fmt.Errorf("%w: phase %d needs a name", ErrInvalidPricePhases, i+1)
+		}
+		if phase.Price.IsNegative() {
+			return fmt.Errorf("%w: the price of phase %s cannot be negative", ErrInvalidPricePhases, phase.Name)
+		}
+		if phase.StartsAt != nil && phase.EndsAt != nil && !phase.EndsAt.After(*phase.StartsAt) {
+			return fmt.Errorf("%w: phase %s must end after it starts", ErrInvalidPricePhases, phase.Name)
+		}
+		if phase.SoldLimit != nil && *phase.SoldLimit < 1 {
+			return fmt.Errorf("%w: the sold limit of phase %s must be positive", ErrInvalidPricePhases, phase.Name)
+		}
+		if i < len(phases)-1 && phase.EndsAt == nil && phase.SoldLimit == nil {
+			return fmt.Errorf("%w: phase %s never ends, so the phases after it never apply", ErrInvalidPricePhases, phase.Name)
+		}
 	}
+	return nil
+}
 
-	if req.Description != "" {
-		ticketType.Description = req.Description
+func validatePurchaseLimits(limits models.PurchaseLimits) error {
+	if limits.MaxPerTransaction != nil && *limits.MaxPerTransaction < 1 {
+		return fmt.Errorf("%w: max_per_transaction must be positive", ErrInvalidPurchaseLimits)
+	}
+	if limits.MaxPerUser != nil && *limits.MaxPerUser < 1 {
+		return fmt.Errorf("%w: max_per_user must be positive", ErrInvalidPurchaseLimits)
 	}
+	return nil
+}
 
-	if req.Price != nil {
-		ticketType.Price = *req.Price
+// limitOrNone turns the 0 of an update, which removes a limit, into no
+// limit.
+func limitOrNone(limit *int) *int {
+	if *limit == 0 {
+		return nil
 	}
+	return limit
+}
 
-	if req.Quota != nil {
-		if *req.Quota < ticketType.Quota-ticketType.RemainingQuota {
-			return nil, errors.New("new quota cannot be less than sold tickets")
-		}
-		quotaDiff := *req.Quota - ticketType.Quota
-		ticketType.Quota = *req.Quota
-		ticketType.RemainingQuota += quotaDiff
+func validateSaleWindow(ticketType *models.TicketType) error {
+	if ticketType.SaleStart != nil && ticketType.SaleEnd != nil && !ticketType.SaleEnd.After(*ticketType.SaleStart) {
+		return errors.New("sale end must be after sale start")
 	}
+	return nil
+}
 
-	ticketType.UpdatedAt = time.Now()
+// tenantRepo scopes the repository to the organizers of the principal.
+func (s *TicketTypeService) tenantRepo(p *Principal) *repository.TicketTypeRepository {
+	if organizerIds, scoped := p.tenant(); scoped {
+		return s.repo.ForTenant(organizerIds)
+	}
+	return s.repo
+}
+
+// lockingRepo is tenantRepo bound to tx, for reads that lock the ticket
+// type until tx ends.
+func (s *TicketTypeService) lockingRepo(p *Principal, tx *sqlx.Tx) *repository.TicketTypeRepository {
+	repo := s.repo.WithTx(tx)
+	if organizerIds, scoped := p.tenant(); scoped {
+		repo = repo.ForTenant(organizerIds)
+	}
+	return repo
+}
 
-	err = s.repo.Update(ticketType)
+// readRepo returns the repository to read the ticket types of an
+// event through. The ticket types of listed events are public. Those of
+// other events are read within the principal's tenant, and only by the
+// organizers who own the event, as the event service hides it from
+// everyone else.
+func (s *TicketTypeService) readRepo(p *Principal, eventId uuid.UUID) (*repository.TicketTypeRepository, error) {
+	event, err := s.eventRepo.FindById(eventId)
 	if err != nil {
 		return nil, err
 	}
+	if event.Status.Listed() {
+		return s.repo, nil
+	}
 
-	return ticketType, nil
+	_, err = s.ownedEvent(p, eventId)
+	if errors.Is(err, ErrForbidden) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tenantRepo(p), nil
 }
 
-func (s *TicketTypeService) DeleteTicketType(id uuid.UUID) error {
-	ticketType, err := s.repo.FindById(id)
+// ownedEvent loads an event whose ticket types the principal may manage,
+// as a member of its organizer or as an admin.
+func (s *TicketTypeService) ownedEvent(p *Principal, eventId uuid.UUID) (*models.Event, error) {
+	eventRepo := s.eventRepo
+	if organizerIds, scoped := p.tenant(); scoped {
+		eventRepo = eventRepo.ForTenant(organizerIds)
+	}
+
+	event, err := eventRepo.FindById(eventId)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if ticketType.Quota != ticketType.RemainingQuota {
-		return errors.New("cannot delete ticket type with sold tickets")
+	err = p.requireEventOwner(event)
+	if err != nil {
+		return nil, err
 	}
 
-	ticketType.DeletedAt = &time.Time{}
-	return s.repo.Update(ticketType)
+	return event, nil
 }
 
 func (s *TicketTypeService) UpdateQuota(id uuid.UUID, quantity int) error {
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTicketTypesOfDraftEventsAreHidden(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	listed := seedSale(t, db, 10)
+	draft := seedSale(t, db, 10)
+
+	_, err := db.Exec(`UPDATE events SET status = $1 WHERE id = $2`, models.EventStatusDraft, draft.eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	organizer := organizerOf(draft.organizerID)
+	viewers := map[string]*Principal{
+		"anonymous":       nil,
+		"buyer":           seedBuyer(t, db),
+		"other organizer": organizerOf(listed.organizerID),
+		// Managing every event does not reach past the principal's tenant
+		"manager of another organizer": NewPrincipal(&models.User{BaseModel: models.BaseModel{ID: uuid.New()}},
+			[]string{"manager"}, []string{PermissionEventsManageAny}, []uuid.UUID{listed.organizerID}),
+	}
+
+	for name, p := range viewers {
+		if _, err := services.ticketTypes.GetTicketTypeById(p, draft.ticketTypeID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: GetTicketTypeById() error = %v, want %v", name, err, sql.ErrNoRows)
+		}
+		if _, err := services.ticketTypes.GetTicketTypesByEventId(p, draft.eventID, &repository.ListQuery{}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: GetTicketTypesByEventId() error = %v, want %v", name, err, sql.ErrNoRows)
+		}
+		if _, err := services.ticketTypes.GetAvailableTicketTypes(p, draft.eventID, &repository.ListQuery{}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: GetAvailableTicketTypes() error = %v, want %v", name, err, sql.ErrNoRows)
+		}
+		if _, err := services.ticketTypes.GetPricePhases(p, draft.ticketTypeID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("%s: GetPricePhases() error = %v, want %v", name, err, sql.ErrNoRows)
+		}
+
+		page, err := services.ticketTypes.GetAllTicketTypes(p, &repository.ListQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := ticketTypeIds(page.Items); !ids[listed.ticketTypeID] || ids[draft.ticketTypeID] {
+			t.Errorf("%s: GetAllTicketTypes() = %v, want only the ticket type of the listed event", name, ids)
+		}
+	}
+
+	if _, err := services.ticketTypes.GetTicketTypeById(organizer, draft.ticketTypeID); err != nil {
+		t.Errorf("organizer: GetTicketTypeById() error = %v", err)
+	}
+	page, err := services.ticketTypes.GetAllTicketTypes(organizer, &repository.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := ticketTypeIds(page.Items); !ids[listed.ticketTypeID] || !ids[draft.ticketTypeID] {
+		t.Errorf("organizer: GetAllTicketTypes() = %v, want the ticket types of both events", ids)
+	}
+}
+
+// organizerOf returns a member of organizerId who manages its events.
+func organizerOf(organizerId uuid.UUID) *Principal {
+	user := &models.User{BaseModel: models.BaseModel{ID: uuid.New()}}
+	return NewPrincipal(user, []string{"organizer"}, []string{PermissionEventsManage}, []uuid.UUID{organizerId})
+}
+
+func ticketTypeIds(ticketTypes []models.TicketType) map[uuid.UUID]bool {
+	ids := make(map[uuid.UUID]bool, len(ticketTypes))
+	for _, ticketType := range ticketTypes {
+		ids[ticketType.ID] = true
+	}
+	return ids
+}
+
+// TestQuotaUpdateRacesCheckout raises the quota while buyers check out. An
+// update that read the remaining quota before a checkout took tickets and
+// wrote it back after would hand those tickets out again.
+func TestQuotaUpdateRacesCheckout(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	sale := seedSale(t, db, 1000)
+	organizer := organizerOf(sale.organizerID)
+
+	const (
+		buyers   = 8
+		checkout = 5
+		updates  = 20
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, buyers*checkout+updates)
+
+	for i := 0; i < buyers; i++ {
+		p := seedBuyer(t, db)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < checkout; j++ {
+				_, err := services.transactions.CreateTransaction(p, &CreateTransactionRequest{
+					EventID:       sale.eventID,
+					PaymentMethod: models.PaymentMethodBankTransfer,
+					Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: 1}},
+				}, nil)
+				errs <- err
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			quota := 1000 + i
+			_, err := services.ticketTypes.UpdateTicketType(organizer, sale.ticketTypeID, &UpdateTicketTypeRequest{Quota: &quota})
+			errs <- err
+		}
+	}()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var taken, pending int
+	err := db.Get(&taken, `SELECT quota - remaining_quota FROM ticket_types WHERE id = $1`, sale.ticketTypeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(&pending, `SELECT COUNT(*) FROM transactions WHERE status = 'pending'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taken != pending {
+		t.Errorf("%d tickets are taken from the quota, want the %d of the pending transactions", taken, pending)
+	}
+}
+
+func TestDeleteTicketType(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	sale := seedSale(t, db, 10)
+	organizer := organizerOf(sale.organizerID)
+
+	err := services.ticketTypes.DeleteTicketType(organizer, sale.ticketTypeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := services.ticketTypes.GetTicketTypeById(organizer, sale.ticketTypeID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTicketTypeById() of a deleted ticket type error = %v, want %v", err, sql.ErrNoRows)
+	}
+	if err := services.ticketTypes.DeleteTicketType(organizer, sale.ticketTypeID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteTicketType() twice error = %v, want %v", err, sql.ErrNoRows)
+	}
+}
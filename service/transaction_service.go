@@ -2,172 +2,801 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"go-ticket/models"
+	"go-ticket/payment"
 	"go-ticket/repository"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrNotOnSale = errors.New("not on sale")
+	// ErrPurchaseLimit is returned when a checkout would take the buyer
+	// past a purchase limit of the event or of a ticket type.
+	ErrPurchaseLimit = errors.New("purchase limit reached")
 )
 
 type TransactionService struct {
+	uow            *repository.UnitOfWork
 	repo           *repository.TransactionRepository
 	detailRepo     *repository.TransactionDetailRepository
+	eventRepo      *repository.EventRepository
 	ticketTypeRepo *repository.TicketTypeRepository
+	eventSeatRepo  *repository.EventSeatRepository
+	pricePhaseRepo *repository.PricePhaseRepository
+	historyRepo    *repository.TransactionStatusHistoryRepository
+	refundRepo     *repository.RefundRepository
+	userRepo       *repository.UserRepository
+	reservations   *ReservationService
+	tickets        *TicketService
+	seats          *SeatMapService
+	promoCodes     *PromoCodeService
+	gateway        payment.Gateway
 }
 
 func NewTransactionService(
+	uow *repository.UnitOfWork,
 	repo *repository.TransactionRepository,
 	detailRepo *repository.TransactionDetailRepository,
+	eventRepo *repository.EventRepository,
 	ticketTypeRepo *repository.TicketTypeRepository,
+	eventSeatRepo *repository.EventSeatRepository,
+	pricePhaseRepo *repository.PricePhaseRepository,
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	refundRepo *repository.RefundRepository,
+	userRepo *repository.UserRepository,
+	reservations *ReservationService,
+	tickets *TicketService,
+	seats *SeatMapService,
+	promoCodes *PromoCodeService,
+	gateway payment.Gateway,
 ) *TransactionService {
 	return &TransactionService{
+		uow:            uow,
 		repo:           repo,
 		detailRepo:     detailRepo,
+		eventRepo:      eventRepo,
 		ticketTypeRepo: ticketTypeRepo,
+		eventSeatRepo:  eventSeatRepo,
+		pricePhaseRepo: pricePhaseRepo,
+		historyRepo:    historyRepo,
+		refundRepo:     refundRepo,
+		userRepo:       userRepo,
+		reservations:   reservations,
+		tickets:        tickets,
+		seats:          seats,
+		promoCodes:     promoCodes,
+		gateway:        gateway,
 	}
 }
 
+// TransactionDetailRequest buys Quantity tickets of a ticket type. Seated
+// ticket types either take the IDs of as many seats to sit in, or leave
+// them out to get the best available seats, optionally within SectionID.
 type TransactionDetailRequest struct {
-	TicketTypeID uuid.UUID `json:"ticket_type_id" validate:"required"`
-	Quantity     int       `json:"quantity" validate:"required,min=1"`
+	TicketTypeID uuid.UUID   `json:"ticket_type_id" validate:"required"`
+	Quantity     int         `json:"quantity" validate:"required,min=1"`
+	SeatIDs      []uuid.UUID `json:"seat_ids"`
+	SectionID    *uuid.UUID  `json:"section_id"`
 }
 
 type CreateTransactionRequest struct {
-	UserID        uuid.UUID                  `json:"user_id" validate:"required"`
 	EventID       uuid.UUID                  `json:"event_id" validate:"required"`
-	PaymentMethod string                     `json:"payment_method" validate:"required"`
-	PaymentUrl    string                     `json:"payment_url" validate:"required"`
+	PaymentMethod models.PaymentMethod       `json:"payment_method" validate:"required"`
 	Details       []TransactionDetailRequest `json:"details" validate:"required,min=1"`
+	PromoCode     string                     `json:"promo_code"`
 }
 
 type UpdateTransactionStatusRequest struct {
-	Status string `json:"status" validate:"required"`
+	Status models.TransactionStatus `json:"status" validate:"required"`
+	Reason string                   `json:"reason"`
 }
 
 type UpdatePaymentStatusRequest struct {
-	Status string `json:"status" validate:"required"`
+	Status models.PaymentStatus `json:"status" validate:"required"`
+	Reason string               `json:"reason"`
 }
 
-func (s *TransactionService) GetAllTransactions() ([]models.Transaction, error) {
-	return s.repo.FindAll()
+func (s *TransactionService) GetAllTransactions(p *Principal, q *repository.ListQuery) (*repository.Page[models.Transaction], error) {
+	err := p.require(PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.List(q)
 }
 
-func (s *TransactionService) GetTransactionById(id uuid.UUID) (*models.Transaction, error) {
-	return s.repo.FindWithDetails(id)
+func (s *TransactionService) GetTransactionById(p *Principal, id uuid.UUID) (*models.Transaction, error) {
+	transaction, err := s.repo.FindWithDetails(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.requireSelfOr(transaction.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
 }
 
-func (s *TransactionService) GetTransactionsByUserId(userId uuid.UUID) ([]models.Transaction, error) {
-	return s.repo.FindByUserId(userId)
+func (s *TransactionService) GetTransactionsByUserId(p *Principal, userId uuid.UUID, q *repository.ListQuery) (*repository.Page[models.Transaction], error) {
+	err := p.requireSelfOr(userId, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.ListByUserId(userId, q)
 }
 
-func (s *TransactionService) CreateTransaction(req *CreateTransactionRequest) (*models.Transaction, error) {
-	// Calculate total amount and validate ticket availability
-	var totalAmount float64
-	var details []models.TransactionDetail
+func (s *TransactionService) GetTransactionHistory(p *Principal, id uuid.UUID) ([]models.TransactionStatusHistory, error) {
+	transaction, err := s.repo.FindById(id)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, detail := range req.Details {
-		ticketType, err := s.ticketTypeRepo.FindById(detail.TicketTypeID)
+	err = p.requireSelfOr(transaction.UserID, PermissionTransactionsReadAny)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.historyRepo.FindByTransactionId(id)
+}
+
+// CreateTransaction buys tickets for the principal. The event must be on
+// sale and every ticket type within its sales window. Tickets are priced
+// at the price phase their ticket type is in when they are held. The
+// seats picked for seated ticket types, or allocated when none were
+// picked, are held along with the quota. A promo code discounts the
+// tickets it applies to and is redeemed in the same database transaction.
+// The purchase limits of the event and its ticket types are checked
+// against the tickets the buyer already holds. The response for claim,
+// when there is one, is recorded in the same database transaction too.
+func (s *TransactionService) CreateTransaction(p *Principal, req *CreateTransactionRequest, claim *IdempotencyClaim) (*models.Transaction, error) {
+	err := p.require(PermissionTransactionsCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Details) == 0 {
+		return nil, errors.New("transaction must contain at least one ticket")
+	}
+
+	if !s.gateway.SupportsMethod(req.PaymentMethod) {
+		return nil, payment.ErrUnsupportedMethod
+	}
+
+	var transaction *models.Transaction
+	err = s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+		detailRepo := s.detailRepo.WithTx(tx)
+		ticketTypeRepo := s.ticketTypeRepo.WithTx(tx)
+		historyRepo := s.historyRepo.WithTx(tx)
+
+		event, err := s.eventRepo.WithTx(tx).FindByIdForShare(req.EventID)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if event.Status != models.EventStatusOnSale {
+			return fmt.Errorf("%w: event is %s", ErrNotOnSale, event.Status)
+		}
+
+		ticketTypes, err := lockTicketTypes(ticketTypeRepo, req.Details)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, ticketType := range ticketTypes {
+			if ticketType.EventID != event.ID {
+				return fmt.Errorf("%w: ticket type %s is not sold for this event", ErrNotOnSale, ticketType.ID)
+			}
+			if !ticketType.OnSaleAt(now) {
+				return fmt.Errorf("%w: ticket type %s is outside its sales window", ErrNotOnSale, ticketType.Name)
+			}
+		}
+
+		err = s.checkPurchaseLimits(tx, p, event, ticketTypes, req.Details)
+		if err != nil {
+			return err
+		}
+
+		seated, err := validateSeatSelection(s.eventSeatRepo.WithTx(tx), req.Details)
+		if err != nil {
+			return err
+		}
+
+		err = s.seats.allocate(tx, event.ID, req.Details, seated)
+		if err != nil {
+			return err
+		}
+
+		ticketTypeIds := make([]uuid.UUID, 0, len(ticketTypes))
+		for id := range ticketTypes {
+			ticketTypeIds = append(ticketTypeIds, id)
+		}
+		phases, err := s.pricePhaseRepo.WithTx(tx).FindByTicketTypeIds(ticketTypeIds)
+		if err != nil {
+			return err
+		}
+
+		// Validate ticket availability and price the tickets at the phase
+		// they are held in
+		var details []models.TransactionDetail
+
+		for _, detail := range req.Details {
+			ticketType := ticketTypes[detail.TicketTypeID]
+
+			if ticketType.RemainingQuota < detail.Quantity {
+				return repository.ErrInsufficientQuota
+			}
+			price := ticketType.PriceAt(phases[ticketType.ID], now)
+			if len(details) > 0 && price.Currency != details[0].PricePerTicket.Currency {
+				return fmt.Errorf("%w: tickets of one transaction must share a currency", models.ErrCurrencyMismatch)
+			}
+			ticketType.RemainingQuota -= detail.Quantity
+
+			subtotal, err := price.Mul(detail.Quantity)
+			if err != nil {
+				return err
+			}
+
+			details = append(details, models.TransactionDetail{
+				BaseModel: models.BaseModel{
+					ID:        uuid.New(),
+					CreatedAt: time.Now(),
+					UpdatedAt: time.Now(),
+				},
+				TicketTypeID:   detail.TicketTypeID,
+				Quantity:       detail.Quantity,
+				PricePerTicket: price,
+				Discount:       models.NewMoney(0, price.Currency),
+				Subtotal:       subtotal,
+			})
 		}
 
-		if ticketType.RemainingQuota < detail.Quantity {
-			return nil, errors.New("insufficient ticket quota")
+		var promoCode *models.PromoCode
+		if req.PromoCode != "" {
+			promoCode, err = s.promoCodes.apply(tx, event, req.PromoCode, details, now)
+			if err != nil {
+				return err
+			}
 		}
 
-		subTotal := ticketType.Price * float64(detail.Quantity)
-		totalAmount += subTotal
+		var totalAmount models.Money
+		for _, detail := range details {
+			totalAmount, err = totalAmount.Add(detail.Subtotal)
+			if err != nil {
+				return err
+			}
+		}
 
-		details = append(details, models.TransactionDetail{
+		// Create transaction
+		transaction = &models.Transaction{
 			BaseModel: models.BaseModel{
 				ID:        uuid.New(),
 				CreatedAt: time.Now(),
 				UpdatedAt: time.Now(),
 			},
-			TicketTypeID:   detail.TicketTypeID,
-			Quantity:       detail.Quantity,
-			PricePerTicket: ticketType.Price,
-			Subtotal:       subTotal,
+			UserID:        p.User.ID,
+			EventID:       req.EventID,
+			Status:        models.TransactionStatusPending,
+			TotalAmount:   totalAmount,
+			Currency:      totalAmount.Currency,
+			PaymentMethod: req.PaymentMethod,
+			PaymentStatus: models.PaymentStatusPending,
+		}
+		if promoCode != nil {
+			transaction.PromoCodeID = &promoCode.ID
+		}
+		s.reservations.Hold(transaction)
+
+		err = repo.Create(transaction)
+		if err != nil {
+			return err
+		}
+
+		if promoCode != nil {
+			err = s.promoCodes.redeem(tx, p, promoCode, transaction.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = recordStatusChange(historyRepo, transaction.ID, historyFieldStatus, nil, string(transaction.Status), systemActor, "transaction created")
+		if err != nil {
+			return err
+		}
+
+		err = recordStatusChange(historyRepo, transaction.ID, historyFieldPaymentStatus, nil, string(transaction.PaymentStatus), systemActor, "transaction created")
+		if err != nil {
+			return err
+		}
+
+		// Create transaction details and update ticket quotas
+		for i := range details {
+			details[i].TransactionID = transaction.ID
+			err = ticketTypeRepo.UpdateQuota(details[i].TicketTypeID, details[i].Quantity)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = detailRepo.BulkCreate(details)
+		if err != nil {
+			return err
+		}
+
+		err = holdSeats(s.eventSeatRepo.WithTx(tx), req.Details, details)
+		if err != nil {
+			return err
+		}
+
+		transaction.Details = details
+		return claim.record(tx, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	claim.commit()
+
+	err = s.attachCharge(transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// attachCharge charges a committed transaction through the payment provider
+// and records the charge. The charge is only created once the transaction
+// exists, so a checkout that fails never leaves a charge behind. When the
+// charge cannot be created the transaction is cancelled rather than hold
+// its tickets until it expires. Should recording the charge fail, syncing
+// the payment status recovers it, as charges are keyed by transaction.
+func (s *TransactionService) attachCharge(transaction *models.Transaction) error {
+	charge, err := s.gateway.CreateCharge(chargeRequest(transaction))
+	if err != nil {
+		releaseErr := s.uow.Do(func(tx *sqlx.Tx) error {
+			locked, err := s.repo.WithTx(tx).FindByIdForUpdate(transaction.ID)
+			if err != nil {
+				return err
+			}
+			if locked.Status != models.TransactionStatusPending {
+				return nil
+			}
+			return s.reservations.release(tx, locked, systemActor, "payment charge could not be created")
 		})
+		return errors.Join(err, releaseErr)
 	}
 
-	// Create transaction
-	transaction := &models.Transaction{
-		BaseModel: models.BaseModel{
-			ID:        uuid.New(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		},
-		UserID:        req.UserID,
-		EventID:       req.EventID,
-		Status:        "pending",
-		TotalAmount:   totalAmount,
-		PaymentMethod: req.PaymentMethod,
-		PaymentStatus: "pending",
-		PaymentUrl:    req.PaymentUrl,
+	provider := s.gateway.Name()
+	err = s.repo.UpdatePaymentCharge(transaction.ID, provider, charge.Reference, charge.PaymentURL)
+	if err != nil {
+		return err
+	}
+
+	transaction.PaymentUrl = charge.PaymentURL
+	transaction.PaymentProvider = &provider
+	transaction.PaymentReference = &charge.Reference
+	return nil
+}
+
+func chargeRequest(transaction *models.Transaction) payment.ChargeRequest {
+	return payment.ChargeRequest{
+		TransactionID: transaction.ID,
+		Amount:        transaction.Total(),
+		Method:        transaction.PaymentMethod,
+		Description:   "Tickets for transaction " + transaction.ID.String(),
+	}
+}
+
+// checkPurchaseLimits caps the tickets of a checkout per transaction and,
+// counting the tickets the buyer already holds for the event, per buyer.
+// When the event limits by contact, the buyer needs a verified email or
+// phone, and the tickets of the accounts sharing a verified contact with
+// the buyer count as the buyer's. The accounts are locked
+// before their tickets are counted, so concurrent checkouts of a buyer are
+// checked one after the other.
+func (s *TransactionService) checkPurchaseLimits(
+	tx *sqlx.Tx,
+	p *Principal,
+	event *models.Event,
+	ticketTypes map[uuid.UUID]*models.TicketType,
+	details []TransactionDetailRequest,
+) error {
+	var ids []uuid.UUID
+	quantities := make(map[uuid.UUID]int, len(ticketTypes))
+	total := 0
+	for _, detail := range details {
+		if _, ok := quantities[detail.TicketTypeID]; !ok {
+			ids = append(ids, detail.TicketTypeID)
+		}
+		quantities[detail.TicketTypeID] += detail.Quantity
+		total += detail.Quantity
+	}
+
+	if event.MaxPerTransaction != nil && total > *event.MaxPerTransaction {
+		return fmt.Errorf("%w: at most %d tickets per transaction for this event", ErrPurchaseLimit, *event.MaxPerTransaction)
+	}
+
+	perUser := event.MaxPerUser != nil
+	for _, id := range ids {
+		ticketType := ticketTypes[id]
+		if ticketType.MaxPerTransaction != nil && quantities[id] > *ticketType.MaxPerTransaction {
+			return fmt.Errorf("%w: at most %d %s tickets per transaction", ErrPurchaseLimit, *ticketType.MaxPerTransaction, ticketType.Name)
+		}
+		perUser = perUser || ticketType.MaxPerUser != nil
+	}
+
+	if !perUser {
+		return nil
+	}
+
+	// Without a verified contact a buyer could open accounts at will to get
+	// around a limit by contact
+	if event.LimitByContact && !p.User.HasVerifiedContact() {
+		return fmt.Errorf("%w: this event limits tickets per verified email or phone, verify yours to buy", ErrPurchaseLimit)
 	}
 
-	err := s.repo.Create(transaction)
+	buyerIds, err := s.userRepo.WithTx(tx).LockBuyers(p.User.ID, event.LimitByContact)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	held, err := s.detailRepo.WithTx(tx).CountHeldByUsers(event.ID, buyerIds)
+	if err != nil {
+		return err
+	}
+
+	buyer := "buyer"
+	if event.LimitByContact {
+		buyer = "buyer email or phone"
 	}
 
-	// Create transaction details and update ticket quotas
-	for i := range details {
-		details[i].TransactionID = transaction.ID
-		err = s.ticketTypeRepo.UpdateQuota(details[i].TicketTypeID, details[i].Quantity)
+	heldTotal := 0
+	for _, quantity := range held {
+		heldTotal += quantity
+	}
+	if event.MaxPerUser != nil && heldTotal+total > *event.MaxPerUser {
+		return fmt.Errorf("%w: at most %d tickets per %s for this event, %d already held", ErrPurchaseLimit, *event.MaxPerUser, buyer, heldTotal)
+	}
+
+	for _, id := range ids {
+		ticketType := ticketTypes[id]
+		if ticketType.MaxPerUser != nil && held[id]+quantities[id] > *ticketType.MaxPerUser {
+			return fmt.Errorf("%w: at most %d %s tickets per %s, %d already held", ErrPurchaseLimit, *ticketType.MaxPerUser, ticketType.Name, buyer, held[id])
+		}
+	}
+
+	return nil
+}
+
+// lockTicketTypes locks every ticket type referenced by the request with
+// SELECT ... FOR UPDATE. Rows are locked in ID order so that two checkouts
+// for overlapping ticket types always acquire their locks in the same
+// order and cannot deadlock.
+func lockTicketTypes(
+	ticketTypeRepo *repository.TicketTypeRepository,
+	details []TransactionDetailRequest,
+) (map[uuid.UUID]*models.TicketType, error) {
+	ids := make([]uuid.UUID, 0, len(details))
+	seen := make(map[uuid.UUID]bool)
+	for _, detail := range details {
+		if !seen[detail.TicketTypeID] {
+			seen[detail.TicketTypeID] = true
+			ids = append(ids, detail.TicketTypeID)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	ticketTypes := make(map[uuid.UUID]*models.TicketType, len(ids))
+	for _, id := range ids {
+		ticketType, err := ticketTypeRepo.FindByIdForUpdate(id)
 		if err != nil {
-			// TODO: Implement rollback
 			return nil, err
 		}
+		ticketTypes[id] = ticketType
 	}
 
-	err = s.detailRepo.BulkCreate(details)
-	if err != nil {
-		// TODO: Implement rollback
-		return nil, err
+	return ticketTypes, nil
+}
+
+// validateSeatSelection checks that every detail of a seated ticket type
+// picks either one seat per ticket or none, that no other detail picks
+// seats or a section, and that no seat is picked twice. It returns which
+// ticket types are seated.
+func validateSeatSelection(eventSeatRepo *repository.EventSeatRepository, details []TransactionDetailRequest) (map[uuid.UUID]bool, error) {
+	seated := make(map[uuid.UUID]bool)
+	picked := make(map[uuid.UUID]bool)
+	for _, detail := range details {
+		isSeated, ok := seated[detail.TicketTypeID]
+		if !ok {
+			seats, err := eventSeatRepo.CountByTicketTypeId(detail.TicketTypeID)
+			if err != nil {
+				return nil, err
+			}
+			isSeated = seats > 0
+			seated[detail.TicketTypeID] = isSeated
+		}
+
+		if !isSeated {
+			if len(detail.SeatIDs) > 0 || detail.SectionID != nil {
+				return nil, fmt.Errorf("%w: ticket type %s is not seated", ErrInvalidSeats, detail.TicketTypeID)
+			}
+			continue
+		}
+
+		if len(detail.SeatIDs) == 0 {
+			continue
+		}
+		if detail.SectionID != nil {
+			return nil, fmt.Errorf("%w: pick either seats or a section for ticket type %s", ErrInvalidSeats, detail.TicketTypeID)
+		}
+		if len(detail.SeatIDs) != detail.Quantity {
+			return nil, fmt.Errorf("%w: pick %d seats for ticket type %s", ErrInvalidSeats, detail.Quantity, detail.TicketTypeID)
+		}
+		for _, seatId := range detail.SeatIDs {
+			if picked[seatId] {
+				return nil, fmt.Errorf("%w: seat %s is picked twice", ErrInvalidSeats, seatId)
+			}
+			picked[seatId] = true
+		}
 	}
 
-	transaction.Details = details
-	return transaction, nil
+	return seated, nil
 }
 
-func (s *TransactionService) UpdateTransactionStatus(id uuid.UUID, req *UpdateTransactionStatusRequest) error {
-	_, err := s.repo.FindById(id)
+// holdSeats holds the seats picked in requests for the matching created
+// details. The ticket types are locked, so the only way to miss a seat is
+// that it is held or sold already.
+func holdSeats(eventSeatRepo *repository.EventSeatRepository, requests []TransactionDetailRequest, details []models.TransactionDetail) error {
+	for i, detail := range requests {
+		if len(detail.SeatIDs) == 0 {
+			continue
+		}
+
+		held, err := eventSeatRepo.Hold(detail.TicketTypeID, details[i].ID, detail.SeatIDs)
+		if err != nil {
+			return err
+		}
+		if held < len(detail.SeatIDs) {
+			return fmt.Errorf("%w: %d of the picked seats are taken or not sold under ticket type %s", ErrSeatUnavailable, len(detail.SeatIDs)-held, detail.TicketTypeID)
+		}
+	}
+
+	return nil
+}
+
+func (s *TransactionService) UpdateTransactionStatus(p *Principal, id uuid.UUID, req *UpdateTransactionStatusRequest) error {
+	err := p.require(PermissionTransactionsManage)
 	if err != nil {
 		return err
 	}
 
-	validStatuses := map[string]bool{
-		"pending":   true,
-		"confirmed": true,
-		"cancelled": true,
-		"completed": true,
-	}
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		transaction, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if req.Status == models.TransactionStatusCancelled {
+			// Money has been taken, so the tickets have to go back through a refund
+			if isPaid(transaction.PaymentStatus) {
+				return fmt.Errorf("%w: paid transactions are cancelled by refunding them", ErrInvalidStatusTransition)
+			}
+			if !canTransition(transactionTransitions, transaction.Status, req.Status) {
+				return fmt.Errorf("%w: transaction cannot move from %s to %s", ErrInvalidStatusTransition, transaction.Status, req.Status)
+			}
+
+			return s.reservations.release(tx, transaction, p.actor(), req.Reason)
+		}
 
-	if !validStatuses[req.Status] {
-		return errors.New("invalid status")
+		return changeTransactionStatus(repo, s.historyRepo.WithTx(tx), transaction, req.Status, p.actor(), req.Reason)
+	})
+}
+
+func (s *TransactionService) UpdatePaymentStatus(p *Principal, id uuid.UUID, req *UpdatePaymentStatusRequest) error {
+	err := p.require(PermissionTransactionsManage)
+	if err != nil {
+		return err
 	}
 
-	return s.repo.UpdateStatus(id, req.Status)
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		repo := s.repo.WithTx(tx)
+
+		if req.Status == models.PaymentStatusRefunded || req.Status == models.PaymentStatusPartiallyRefunded {
+			return fmt.Errorf("%w: refunds are made through the refund endpoint", ErrInvalidStatusTransition)
+		}
+
+		transaction, err := repo.FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		if transaction.Status == models.TransactionStatusCancelled && req.Status == models.PaymentStatusPaid {
+			return fmt.Errorf("%w: transaction is cancelled and its tickets were released", ErrInvalidStatusTransition)
+		}
+
+		historyRepo := s.historyRepo.WithTx(tx)
+
+		err = changePaymentStatus(repo, historyRepo, transaction, req.Status, p.actor(), req.Reason)
+		if err != nil {
+			return err
+		}
+
+		if transaction.PaymentStatus != models.PaymentStatusPaid {
+			return nil
+		}
+
+		if transaction.Status == models.TransactionStatusPending {
+			err = changeTransactionStatus(repo, historyRepo, transaction, models.TransactionStatusConfirmed, p.actor(), req.Reason)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.tickets.issue(tx, transaction)
+	})
+}
+
+// HandlePaymentEvent applies a charge status reported by a payment provider
+// to the transaction that owns the charge.
+func (s *TransactionService) HandlePaymentEvent(event payment.Event) error {
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		return s.applyPaymentEvent(tx, event)
+	})
 }
 
-func (s *TransactionService) UpdatePaymentStatus(id uuid.UUID, req *UpdatePaymentStatusRequest) error {
-	_, err := s.repo.FindById(id)
+func (s *TransactionService) applyPaymentEvent(tx *sqlx.Tx, event payment.Event) error {
+	repo := s.repo.WithTx(tx)
+
+	transaction, err := repo.FindByPaymentReferenceForUpdate(event.Provider, event.Reference)
 	if err != nil {
 		return err
 	}
 
-	validStatuses := map[string]bool{
-		"pending":  true,
-		"paid":     true,
-		"failed":   true,
-		"refunded": true,
+	if event.Payload != nil {
+		err = repo.UpdatePaymentCallback(transaction.ID, string(event.Payload))
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.applyChargeStatus(tx, transaction, event.Status, "payment:"+event.Provider)
+}
+
+// SyncPaymentStatus asks the payment provider for the current status of the
+// transaction's charge and applies it.
+func (s *TransactionService) SyncPaymentStatus(p *Principal, id uuid.UUID) error {
+	return s.uow.Do(func(tx *sqlx.Tx) error {
+		transaction, err := s.repo.WithTx(tx).FindByIdForUpdate(id)
+		if err != nil {
+			return err
+		}
+
+		err = p.requireSelfOr(transaction.UserID, PermissionTransactionsManage)
+		if err != nil {
+			return err
+		}
+
+		// A pending transaction whose charge was created but not recorded
+		// gets it back from the provider, which keys charges by transaction
+		if transaction.PaymentReference == nil && transaction.Status == models.TransactionStatusPending {
+			charge, err := s.gateway.CreateCharge(chargeRequest(transaction))
+			if err != nil {
+				return err
+			}
+
+			provider := s.gateway.Name()
+			err = s.repo.WithTx(tx).UpdatePaymentCharge(transaction.ID, provider, charge.Reference, charge.PaymentURL)
+			if err != nil {
+				return err
+			}
+			transaction.PaymentProvider = &provider
+			transaction.PaymentReference = &charge.Reference
+		}
+
+		if transaction.PaymentReference == nil || transaction.PaymentProvider == nil {
+			return errors.New("transaction has no payment charge")
+		}
+		if *transaction.PaymentProvider != s.gateway.Name() {
+			return errors.New("transaction was charged through another payment provider")
+		}
+
+		status, err := s.gateway.GetChargeStatus(*transaction.PaymentReference)
+		if err != nil {
+			return err
+		}
+
+		return s.applyChargeStatus(tx, transaction, status, "payment:"+s.gateway.Name())
+	})
+}
+
+// applyChargeStatus moves the payment status, and the transaction status
+// when a charge is paid, to match status. Reporting a status the
+// transaction already has is a no-op so repeated provider events are safe.
+// A charge paid after its transaction was cancelled, typically by the hold
+// sweeper, is refunded instead, as its tickets may be sold to someone else.
+func (s *TransactionService) applyChargeStatus(tx *sqlx.Tx, transaction *models.Transaction, status payment.ChargeStatus, actor string) error {
+	repo := s.repo.WithTx(tx)
+	historyRepo := s.historyRepo.WithTx(tx)
+	reason := "payment provider reported " + string(status)
+
+	switch status {
+	case payment.ChargeStatusPaid:
+		if isPaid(transaction.PaymentStatus) || transaction.PaymentStatus == models.PaymentStatusRefunded {
+			return nil
+		}
+
+		if transaction.Status == models.TransactionStatusCancelled {
+			return s.refundLateCharge(tx, transaction, actor)
+		}
+
+		err := changePaymentStatus(repo, historyRepo, transaction, models.PaymentStatusPaid, actor, reason)
+		if err != nil {
+			return err
+		}
+
+		if transaction.Status == models.TransactionStatusPending {
+			err = changeTransactionStatus(repo, historyRepo, transaction, models.TransactionStatusConfirmed, actor, reason)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.tickets.issue(tx, transaction)
+	case payment.ChargeStatusFailed:
+		if transaction.PaymentStatus == models.PaymentStatusFailed {
+			return nil
+		}
+
+		return changePaymentStatus(repo, historyRepo, transaction, models.PaymentStatusFailed, actor, reason)
+	default:
+		return nil
+	}
+}
+
+// refundLateCharge gives back the whole charge of a cancelled transaction
+// that the provider reported paid, and records the refund and the payment
+// status it ends in.
+func (s *TransactionService) refundLateCharge(tx *sqlx.Tx, transaction *models.Transaction, actor string) error {
+	reason := "payment arrived after the transaction was cancelled"
+
+	providerRefund, err := s.gateway.Refund(payment.RefundRequest{
+		Reference: *transaction.PaymentReference,
+		Amount:    transaction.Total(),
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
 	}
 
-	if !validStatuses[req.Status] {
-		return errors.New("invalid payment status")
+	err = s.refundRepo.WithTx(tx).Create(&models.Refund{
+		BaseModel: models.BaseModel{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		},
+		TransactionID:     transaction.ID,
+		Amount:            transaction.Total(),
+		Reason:            reason,
+		Actor:             actor,
+		Status:            models.RefundStatusSucceeded,
+		ProviderReference: &providerRefund.Reference,
+	})
+	if err != nil {
+		return err
 	}
 
-	return s.repo.UpdatePaymentStatus(id, req.Status)
+	return changePaymentStatus(s.repo.WithTx(tx), s.historyRepo.WithTx(tx), transaction, models.PaymentStatusRefunded, actor, reason)
 }
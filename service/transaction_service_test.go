@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// seedContactBuyer creates a buyer with email and phone, both verified or
+// neither.
+func seedContactBuyer(t *testing.T, db *sqlx.DB, email, phone string, verified bool) *Principal {
+	t.Helper()
+
+	user := &models.User{
+		BaseModel: models.BaseModel{ID: uuid.New()},
+		Fullname:  "Buyer",
+		Email:     email,
+		Phone:     phone,
+	}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		user.PhoneVerifiedAt = &now
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO users (id, fullname, email, password, phone, email_verified_at, phone_verified_at)
+		VALUES ($1, $2, $3, 'x', $4, $5, $6)
+	`, user.ID, user.Fullname, user.Email, user.Phone, user.EmailVerifiedAt, user.PhoneVerifiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewPrincipal(user, []string{"user"}, []string{PermissionTransactionsCreate}, nil)
+}
+
+func TestPurchaseLimitByContact(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+
+	buy := func(sale saleFixture, p *Principal, quantity int) error {
+		_, err := services.transactions.CreateTransaction(p, &CreateTransactionRequest{
+			EventID:       sale.eventID,
+			PaymentMethod: models.PaymentMethodBankTransfer,
+			Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: quantity}},
+		}, nil)
+		return err
+	}
+	limitByContact := func(sale saleFixture) {
+		_, err := db.Exec(`UPDATE events SET max_per_user = 2, limit_by_contact = true WHERE id = $1`, sale.eventID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("accounts sharing a verified contact share the limit", func(t *testing.T) {
+		sale := seedSale(t, db, 100)
+		limitByContact(sale)
+		first := seedContactBuyer(t, db, "scalper@example.com", "+628111000001", true)
+		second := seedContactBuyer(t, db, "Scalper@Example.com", "+628111000002", true)
+
+		if err := buy(sale, first, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := buy(sale, second, 1); !errors.Is(err, ErrPurchaseLimit) {
+			t.Errorf("second account bought past the limit of the first, error = %v, want %v", err, ErrPurchaseLimit)
+		}
+	})
+
+	t.Run("a buyer without a verified contact cannot get around the limit", func(t *testing.T) {
+		sale := seedSale(t, db, 100)
+		limitByContact(sale)
+		unverified := seedContactBuyer(t, db, "fresh@example.com", "+628111000003", false)
+
+		if err := buy(sale, unverified, 1); !errors.Is(err, ErrPurchaseLimit) {
+			t.Errorf("unverified buyer error = %v, want %v", err, ErrPurchaseLimit)
+		}
+	})
+
+	t.Run("an unverified copy of a contact does not use up its limit", func(t *testing.T) {
+		sale := seedSale(t, db, 100)
+		limitByContact(sale)
+		victim := seedContactBuyer(t, db, "victim@example.com", "+628111000004", true)
+		griefer := seedContactBuyer(t, db, "griefer@example.com", "+628111000005", true)
+
+		// The griefer claims the victim's phone without verifying it
+		_, err := db.Exec(`UPDATE users SET phone = '+628111000004', phone_verified_at = NULL WHERE id = $1`, griefer.User.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := buy(sale, griefer, 2); err != nil {
+			t.Fatal(err)
+		}
+		if err := buy(sale, victim, 2); err != nil {
+			t.Errorf("victim could not buy up to their own limit: %v", err)
+		}
+	})
+}
+
+func TestCheckoutPastQuota(t *testing.T) {
+	db := openTestDB(t)
+	services := newTestServices(t, db)
+	sale := seedSale(t, db, 2)
+
+	_, err := services.transactions.CreateTransaction(seedBuyer(t, db), &CreateTransactionRequest{
+		EventID:       sale.eventID,
+		PaymentMethod: models.PaymentMethodBankTransfer,
+		Details:       []TransactionDetailRequest{{TicketTypeID: sale.ticketTypeID, Quantity: 3}},
+	}, nil)
+	if !errors.Is(err, repository.ErrInsufficientQuota) {
+		t.Errorf("CreateTransaction() error = %v, want %v", err, repository.ErrInsufficientQuota)
+	}
+}
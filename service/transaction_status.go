@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidStatus           = errors.New("invalid status")
+	ErrInvalidStatusTransition = errors.New("invalid status transition")
+)
+
+const (
+	historyFieldStatus        = "status"
+	historyFieldPaymentStatus = "payment_status"
+
+	// systemActor is recorded for status changes made by the server itself,
+	// such as the hold sweeper.
+	systemActor = "system"
+)
+
+// transactionTransitions lists the statuses a transaction may move to from
+// each status. Statuses without an entry are terminal.
+var transactionTransitions = map[models.TransactionStatus][]models.TransactionStatus{
+	models.TransactionStatusPending:   {models.TransactionStatusConfirmed, models.TransactionStatusCancelled},
+	models.TransactionStatusConfirmed: {models.TransactionStatusCompleted, models.TransactionStatusCancelled},
+	models.TransactionStatusCompleted: {models.TransactionStatusCancelled},
+}
+
+// paymentTransitions lists the payment statuses a transaction may move to
+// from each payment status. A failed payment may be retried. A charge paid
+// after its transaction was cancelled is refunded without ever counting as
+// paid.
+var paymentTransitions = map[models.PaymentStatus][]models.PaymentStatus{
+	models.PaymentStatusPending:           {models.PaymentStatusPaid, models.PaymentStatusFailed, models.PaymentStatusRefunded},
+	models.PaymentStatusFailed:            {models.PaymentStatusPending, models.PaymentStatusPaid, models.PaymentStatusRefunded},
+	models.PaymentStatusPaid:              {models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded},
+	models.PaymentStatusPartiallyRefunded: {models.PaymentStatusRefunded},
+}
+
+// isPaid reports whether money was captured for the transaction and not
+// fully refunded yet.
+func isPaid(status models.PaymentStatus) bool {
+	return status == models.PaymentStatusPaid || status == models.PaymentStatusPartiallyRefunded
+}
+
+func canTransition[S comparable](graph map[S][]S, from, to S) bool {
+	for _, next := range graph[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// changeTransactionStatus moves transaction to status and records the change.
+// The caller must hold the transaction's row lock.
+func changeTransactionStatus(
+	repo *repository.TransactionRepository,
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	transaction *models.Transaction,
+	status models.TransactionStatus,
+	actor, reason string,
+) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: unknown transaction status %s", ErrInvalidStatus, status)
+	}
+
+	from := transaction.Status
+	if !canTransition(transactionTransitions, from, status) {
+		return fmt.Errorf("%w: transaction cannot move from %s to %s", ErrInvalidStatusTransition, from, status)
+	}
+
+	err := repo.UpdateStatus(transaction.ID, status)
+	if err != nil {
+		return err
+	}
+	transaction.Status = status
+
+	fromStatus := string(from)
+	return recordStatusChange(historyRepo, transaction.ID, historyFieldStatus, &fromStatus, string(status), actor, reason)
+}
+
+// changePaymentStatus moves transaction to the payment status and records
+// the change. The caller must hold the transaction's row lock.
+func changePaymentStatus(
+	repo *repository.TransactionRepository,
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	transaction *models.Transaction,
+	status models.PaymentStatus,
+	actor, reason string,
+) error {
+	if !status.Valid() {
+		return fmt.Errorf("%w: unknown payment status %s", ErrInvalidStatus, status)
+	}
+
+	from := transaction.PaymentStatus
+	if !canTransition(paymentTransitions, from, status) {
+		return fmt.Errorf("%w: payment cannot move from %s to %s", ErrInvalidStatusTransition, from, status)
+	}
+
+	err := repo.UpdatePaymentStatus(transaction.ID, status)
+	if err != nil {
+		return err
+	}
+	transaction.PaymentStatus = status
+
+	fromStatus := string(from)
+	return recordStatusChange(historyRepo, transaction.ID, historyFieldPaymentStatus, &fromStatus, string(status), actor, reason)
+}
+
+func recordStatusChange(
+	historyRepo *repository.TransactionStatusHistoryRepository,
+	transactionId uuid.UUID,
+	field string,
+	from *string,
+	to string,
+	actor, reason string,
+) error {
+	return historyRepo.Create(&models.TransactionStatusHistory{
+		ID:            uuid.New(),
+		TransactionID: transactionId,
+		Field:         field,
+		FromStatus:    from,
+		ToStatus:      to,
+		Actor:         actor,
+		Reason:        reason,
+		CreatedAt:     time.Now(),
+	})
+}
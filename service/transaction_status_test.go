@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"go-ticket/models"
+	"go-ticket/repository"
+	"testing"
+)
+
+var (
+	transactionStatuses = []models.TransactionStatus{
+		models.TransactionStatusPending,
+		models.TransactionStatusConfirmed,
+		models.TransactionStatusCompleted,
+		models.TransactionStatusCancelled,
+	}
+	paymentStatuses = []models.PaymentStatus{
+		models.PaymentStatusPending,
+		models.PaymentStatusPaid,
+		models.PaymentStatusFailed,
+		models.PaymentStatusPartiallyRefunded,
+		models.PaymentStatusRefunded,
+	}
+)
+
+func TestTransactionTransitions(t *testing.T) {
+	allowed := map[[2]models.TransactionStatus]bool{
+		{models.TransactionStatusPending, models.TransactionStatusConfirmed}:   true,
+		{models.TransactionStatusPending, models.TransactionStatusCancelled}:   true,
+		{models.TransactionStatusConfirmed, models.TransactionStatusCompleted}: true,
+		{models.TransactionStatusConfirmed, models.TransactionStatusCancelled}: true,
+		{models.TransactionStatusCompleted, models.TransactionStatusCancelled}: true,
+	}
+
+	for _, from := range transactionStatuses {
+		for _, to := range transactionStatuses {
+			want := allowed[[2]models.TransactionStatus{from, to}]
+			if got := canTransition(transactionTransitions, from, to); got != want {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestPaymentTransitions(t *testing.T) {
+	allowed := map[[2]models.PaymentStatus]bool{
+		{models.PaymentStatusPending, models.PaymentStatusPaid}:               true,
+		{models.PaymentStatusPending, models.PaymentStatusFailed}:             true,
+		{models.PaymentStatusPending, models.PaymentStatusRefunded}:           true,
+		{models.PaymentStatusFailed, models.PaymentStatusPending}:             true,
+		{models.PaymentStatusFailed, models.PaymentStatusPaid}:                true,
+		{models.PaymentStatusFailed, models.PaymentStatusRefunded}:            true,
+		{models.PaymentStatusPaid, models.PaymentStatusPartiallyRefunded}:     true,
+		{models.PaymentStatusPaid, models.PaymentStatusRefunded}:              true,
+		{models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded}: true,
+	}
+
+	for _, from := range paymentStatuses {
+		for _, to := range paymentStatuses {
+			want := allowed[[2]models.PaymentStatus{from, to}]
+			if got := canTransition(paymentTransitions, from, to); got != want {
+				t.Errorf("canTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestIsPaid(t *testing.T) {
+	for _, status := range paymentStatuses {
+		want := status == models.PaymentStatusPaid || status == models.PaymentStatusPartiallyRefunded
+		if got := isPaid(status); got != want {
+			t.Errorf("isPaid(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// Rejected changes return before touching the database, so the
+// repositories are never used.
+func TestChangeTransactionStatusRejected(t *testing.T) {
+	repo := repository.NewTransactionRepository(nil)
+	historyRepo := repository.NewTransactionStatusHistoryRepository(nil)
+
+	tests := []struct {
+		name    string
+		from    models.TransactionStatus
+		to      models.TransactionStatus
+		wantErr error
+	}{
+		{"unknown status", models.TransactionStatusPending, "refunded", ErrInvalidStatus},
+		{"terminal status", models.TransactionStatusCancelled, models.TransactionStatusPending, ErrInvalidStatusTransition},
+		{"skipped status", models.TransactionStatusPending, models.TransactionStatusCompleted, ErrInvalidStatusTransition},
+		{"same status", models.TransactionStatusConfirmed, models.TransactionStatusConfirmed, ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := &models.Transaction{Status: tt.from}
+			err := changeTransactionStatus(repo, historyRepo, transaction, tt.to, systemActor, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("changeTransactionStatus(%s, %s) = %v, want %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			if transaction.Status != tt.from {
+				t.Errorf("status = %s after a rejected change, want %s", transaction.Status, tt.from)
+			}
+		})
+	}
+}
+
+func TestChangePaymentStatusRejected(t *testing.T) {
+	repo := repository.NewTransactionRepository(nil)
+	historyRepo := repository.NewTransactionStatusHistoryRepository(nil)
+
+	tests := []struct {
+		name    string
+		from    models.PaymentStatus
+		to      models.PaymentStatus
+		wantErr error
+	}{
+		{"unknown status", models.PaymentStatusPending, "cancelled", ErrInvalidStatus},
+		{"refunded is terminal", models.PaymentStatusRefunded, models.PaymentStatusPaid, ErrInvalidStatusTransition},
+		{"paid cannot fail", models.PaymentStatusPaid, models.PaymentStatusFailed, ErrInvalidStatusTransition},
+		{"partial refund needs a payment", models.PaymentStatusPending, models.PaymentStatusPartiallyRefunded, ErrInvalidStatusTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := &models.Transaction{PaymentStatus: tt.from}
+			err := changePaymentStatus(repo, historyRepo, transaction, tt.to, systemActor, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("changePaymentStatus(%s, %s) = %v, want %v", tt.from, tt.to, err, tt.wantErr)
+			}
+			if transaction.PaymentStatus != tt.from {
+				t.Errorf("payment status = %s after a rejected change, want %s", transaction.PaymentStatus, tt.from)
+			}
+		})
+	}
+}
@@ -7,49 +7,113 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrEmailRegistered = errors.New("email already registered")
+	ErrPhoneRegistered = errors.New("phone number already registered")
 )
 
 type UserService struct {
-	repo *repository.UserRepository
+	uow      *repository.UnitOfWork
+	repo     *repository.UserRepository
+	roleRepo *repository.RoleRepository
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
+func NewUserService(uow *repository.UnitOfWork, repo *repository.UserRepository, roleRepo *repository.RoleRepository) *UserService {
 	return &UserService{
-		repo: repo,
+		uow:      uow,
+		repo:     repo,
+		roleRepo: roleRepo,
 	}
 }
 
+// CreateUserRequest creates a user who cannot log in until a password is
+// set, unless Password is given.
 type CreateUserRequest struct {
-	Name  string `json:"name" validate:"required"`
-	Email string `json:"email" validate:"required,email"`
-	Phone string `json:"phone" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Phone    string `json:"phone" validate:"required"`
+	Password string `json:"password" validate:"omitempty,min=8,max=72"`
 }
 
+// UpdateUserRequest leaves out what it does not change. Changing the email
+// or phone clears its verification. Only user managers may mark a contact
+// verified, or not, with EmailVerified and PhoneVerified.
 type UpdateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email" validate:"email"`
-	Phone string `json:"phone"`
+	Name          string `json:"name"`
+	Email         string `json:"email" validate:"email"`
+	Phone         string `json:"phone"`
+	EmailVerified *bool  `json:"email_verified"`
+	PhoneVerified *bool  `json:"phone_verified"`
 }
 
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.repo.FindAll()
+func (s *UserService) GetAllUsers(p *Principal, q *repository.ListQuery) (*repository.Page[models.User], error) {
+	err := p.require(PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.List(q)
 }
 
-func (s *UserService) GetUserById(id uuid.UUID) (*models.User, error) {
+func (s *UserService) GetUserById(p *Principal, id uuid.UUID) (*models.User, error) {
+	err := p.requireSelfOr(id, PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
 	return s.repo.FindById(id)
 }
 
-func (s *UserService) CreateUser(req *CreateUserRequest) (*models.User, error) {
+func (s *UserService) CreateUser(p *Principal, req *CreateUserRequest) (*models.User, error) {
+	err := p.require(PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createUser(req)
+}
+
+// createUser creates a customer. It backs CreateUser.
+func (s *UserService) createUser(req *CreateUserRequest) (*models.User, error) {
+	var user *models.User
+	err := s.uow.Do(func(tx *sqlx.Tx) error {
+		var err error
+		user, err = s.insertUser(tx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// insertUser creates a customer inside tx, for callers that store more
+// alongside the user, as self-registration does.
+func (s *UserService) insertUser(tx *sqlx.Tx, req *CreateUserRequest) (*models.User, error) {
+	repo := s.repo.WithTx(tx)
+
 	// Check if email already exists
-	existingUser, err := s.repo.FindByEmail(req.Email)
+	existingUser, err := repo.FindByEmail(req.Email)
 	if err == nil && existingUser != nil {
-		return nil, errors.New("email already registered")
+		return nil, ErrEmailRegistered
 	}
 
 	// Check if phone already exists
-	existingUser, err = s.repo.FindByPhone(req.Phone)
+	existingUser, err = repo.FindByPhone(req.Phone)
 	if err == nil && existingUser != nil {
-		return nil, errors.New("phone number already registered")
+		return nil, ErrPhoneRegistered
+	}
+
+	password := unusablePassword
+	if req.Password != "" {
+		password, err = hashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	user := &models.User{
@@ -61,9 +125,15 @@ func (s *UserService) CreateUser(req *CreateUserRequest) (*models.User, error) {
 		Fullname: req.Name,
 		Email:    req.Email,
 		Phone:    req.Phone,
+		Password: password,
+	}
+
+	err = repo.Create(user)
+	if err != nil {
+		return nil, err
 	}
 
-	err = s.repo.Create(user)
+	_, err = s.roleRepo.WithTx(tx).AddUserRole(user.ID, RoleCustomer)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +141,16 @@ func (s *UserService) CreateUser(req *CreateUserRequest) (*models.User, error) {
 	return user, nil
 }
 
-func (s *UserService) UpdateUser(id uuid.UUID, req *UpdateUserRequest) (*models.User, error) {
+func (s *UserService) UpdateUser(p *Principal, id uuid.UUID, req *UpdateUserRequest) (*models.User, error) {
+	err := p.requireSelfOr(id, PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if (req.EmailVerified != nil || req.PhoneVerified != nil) && !p.Can(PermissionUsersManage) {
+		return nil, ErrForbidden
+	}
+
 	user, err := s.repo.FindById(id)
 	if err != nil {
 		return nil, err
@@ -80,24 +159,40 @@ func (s *UserService) UpdateUser(id uuid.UUID, req *UpdateUserRequest) (*models.
 	if req.Email != "" && req.Email != user.Email {
 		existingUser, err := s.repo.FindByEmail(req.Email)
 		if err == nil && existingUser != nil {
-			return nil, errors.New("email already registered")
+			return nil, ErrEmailRegistered
 		}
 		user.Email = req.Email
+		user.EmailVerifiedAt = nil
 	}
 
 	if req.Phone != "" && req.Phone != user.Phone {
 		existingUser, err := s.repo.FindByPhone(req.Phone)
 		if err == nil && existingUser != nil {
-			return nil, errors.New("phone number already registered")
+			return nil, ErrPhoneRegistered
 		}
 		user.Phone = req.Phone
+		user.PhoneVerifiedAt = nil
+	}
+
+	now := time.Now()
+	if req.EmailVerified != nil {
+		user.EmailVerifiedAt = nil
+		if *req.EmailVerified {
+			user.EmailVerifiedAt = &now
+		}
+	}
+	if req.PhoneVerified != nil {
+		user.PhoneVerifiedAt = nil
+		if *req.PhoneVerified {
+			user.PhoneVerifiedAt = &now
+		}
 	}
 
 	if req.Name != "" {
 		user.Fullname = req.Name
 	}
 
-	user.UpdatedAt = time.Now()
+	user.UpdatedAt = now
 
 	err = s.repo.Update(user)
 	if err != nil {
@@ -107,7 +202,12 @@ func (s *UserService) UpdateUser(id uuid.UUID, req *UpdateUserRequest) (*models.
 	return user, nil
 }
 
-func (s *UserService) DeleteUser(id uuid.UUID) error {
+func (s *UserService) DeleteUser(p *Principal, id uuid.UUID) error {
+	err := p.require(PermissionUsersManage)
+	if err != nil {
+		return err
+	}
+
 	user, err := s.repo.FindById(id)
 	if err != nil {
 		return err
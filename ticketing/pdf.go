@@ -0,0 +1,88 @@
+package ticketing
+
+import (
+	"bytes"
+	"fmt"
+	"go-ticket/models"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/google/uuid"
+)
+
+const scheduleDateFormat = "Mon, 02 Jan 2006 15:04 MST"
+
+// RenderPDF renders one A4 page per ticket showing the event, its location
+// and schedule, the ticket type and holder, and a QR code of the ticket's
+// token from tokens.
+func RenderPDF(event *models.Event, ticketTypeNames map[uuid.UUID]string, tickets []models.Ticket, tokens map[uuid.UUID]string) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(event.Name, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(false, 20)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	for _, ticket := range tickets {
+		png, err := QRCode(tokens[ticket.ID])
+		if err != nil {
+			return nil, err
+		}
+
+		image := "qr-" + ticket.Code
+		pdf.RegisterImageOptionsReader(image, fpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+
+		pdf.AddPage()
+
+		pdf.SetFont("Helvetica", "B", 22)
+		pdf.MultiCell(0, 10, tr(event.Name), "", "L", false)
+		pdf.Ln(4)
+
+		pdf.SetFont("Helvetica", "", 12)
+		if event.Location != nil {
+			pdf.MultiCell(0, 6, tr(event.Location.Name), "", "L", false)
+			pdf.MultiCell(0, 6, tr(formatAddress(event.Location)), "", "L", false)
+			pdf.Ln(2)
+		}
+		if event.Schedule != nil {
+			pdf.MultiCell(0, 6, tr(fmt.Sprintf("%s - %s",
+				event.Schedule.StartDate.Format(scheduleDateFormat),
+				event.Schedule.EndDate.Format(scheduleDateFormat),
+			)), "", "L", false)
+		}
+		pdf.Ln(8)
+
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.MultiCell(0, 8, tr(ticketTypeNames[ticket.TicketTypeID]), "", "L", false)
+		if ticket.HolderName != nil {
+			pdf.SetFont("Helvetica", "", 12)
+			pdf.MultiCell(0, 6, tr(*ticket.HolderName), "", "L", false)
+		}
+
+		pdf.ImageOptions(image, 55, 110, 100, 100, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		pdf.SetY(215)
+		pdf.SetFont("Courier", "B", 16)
+		pdf.CellFormat(0, 10, ticket.Code, "", 1, "C", false, 0, "")
+	}
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func formatAddress(location *models.Location) string {
+	var parts []string
+	for _, part := range []string{location.Address, location.City, location.State, location.PostalCode, location.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
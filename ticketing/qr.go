@@ -0,0 +1,14 @@
+package ticketing
+
+import (
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRCodeSize is the width and height in pixels of rendered QR codes.
+const QRCodeSize = 512
+
+// QRCode renders content as a PNG QR code. Medium error correction keeps
+// codes readable when a printed ticket is creased or a screen is cracked.
+func QRCode(content string) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, QRCodeSize)
+}
@@ -0,0 +1,167 @@
+package ticketing
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyAlgorithm names the signature scheme published to scanners.
+const KeyAlgorithm = "Ed25519"
+
+// tokenVersion is the first byte of every token payload.
+const tokenVersion = 1
+
+// payloadSize is the version byte, three UUIDs and two Unix timestamps.
+const payloadSize = 1 + 3*16 + 2*8
+
+// MinSecretSize is the shortest master secret a Signer accepts. Every
+// event key is derived from it, so a guessable secret lets anyone forge
+// tickets.
+const MinSecretSize = 32
+
+var (
+	ErrInvalidToken  = errors.New("invalid ticket token")
+	ErrTokenNotValid = errors.New("ticket token outside its validity window")
+	ErrWeakSecret    = errors.New("ticket signing secret is too short")
+)
+
+// Claims are the fields a ticket token vouches for. A zero NotBefore or
+// NotAfter leaves that side of the validity window open.
+type Claims struct {
+	TicketID     uuid.UUID
+	EventID      uuid.UUID
+	TicketTypeID uuid.UUID
+	NotBefore    time.Time
+	NotAfter     time.Time
+}
+
+// ScannerKey is the public half of an event's signing key.
+type ScannerKey struct {
+	EventID   uuid.UUID `json:"event_id"`
+	KeyID     string    `json:"key_id"`
+	Algorithm string    `json:"algorithm"`
+	PublicKey string    `json:"public_key"`
+}
+
+// Signer issues the compact tokens printed in ticket QR codes. A token is
+// "<payload>.<signature>", both unpadded base64url, where the signature is
+// Ed25519 over the raw payload. Every event signs with its own key, derived
+// from the master secret, so a scanner only needs that event's public key
+// to verify tickets offline.
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner returns a signer deriving its keys from secret, which must be
+// at least MinSecretSize bytes long.
+func NewSigner(secret string) (*Signer, error) {
+	if len(secret) < MinSecretSize {
+		return nil, fmt.Errorf("%w: need at least %d bytes, got %d", ErrWeakSecret, MinSecretSize, len(secret))
+	}
+
+	return &Signer{
+		secret: []byte(secret),
+	}, nil
+}
+
+func (s *Signer) Sign(claims Claims) string {
+	payload := make([]byte, payloadSize)
+	payload[0] = tokenVersion
+	copy(payload[1:17], claims.TicketID[:])
+	copy(payload[17:33], claims.EventID[:])
+	copy(payload[33:49], claims.TicketTypeID[:])
+	binary.BigEndian.PutUint64(payload[49:57], encodeTime(claims.NotBefore))
+	binary.BigEndian.PutUint64(payload[57:65], encodeTime(claims.NotAfter))
+
+	signature := ed25519.Sign(s.privateKey(claims.EventID), payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Verify checks the signature of token against the key of the event it
+// names and that now falls inside its validity window. The claims are
+// returned with ErrTokenNotValid so callers can still tell which ticket
+// was presented.
+func (s *Signer) Verify(token string, now time.Time) (*Claims, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok || len(s.secret) == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil || len(payload) != payloadSize || payload[0] != tokenVersion {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	copy(claims.TicketID[:], payload[1:17])
+	copy(claims.EventID[:], payload[17:33])
+	copy(claims.TicketTypeID[:], payload[33:49])
+	claims.NotBefore = decodeTime(binary.BigEndian.Uint64(payload[49:57]))
+	claims.NotAfter = decodeTime(binary.BigEndian.Uint64(payload[57:65]))
+
+	if !ed25519.Verify(s.publicKey(claims.EventID), payload, signature) {
+		return nil, ErrInvalidToken
+	}
+
+	if (!claims.NotBefore.IsZero() && now.Before(claims.NotBefore)) ||
+		(!claims.NotAfter.IsZero() && now.After(claims.NotAfter)) {
+		return &claims, ErrTokenNotValid
+	}
+
+	return &claims, nil
+}
+
+// encodeTime writes t as Unix seconds, and the zero time as 0 so an open
+// bound reads back as open.
+func encodeTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.Unix())
+}
+
+func decodeTime(seconds uint64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(seconds), 0)
+}
+
+// ScannerKey returns the public key scanners use to verify the tickets of
+// an event.
+func (s *Signer) ScannerKey(eventId uuid.UUID) ScannerKey {
+	publicKey := s.publicKey(eventId)
+	fingerprint := sha256.Sum256(publicKey)
+
+	return ScannerKey{
+		EventID:   eventId,
+		KeyID:     base64.RawURLEncoding.EncodeToString(fingerprint[:8]),
+		Algorithm: KeyAlgorithm,
+		PublicKey: base64.RawURLEncoding.EncodeToString(publicKey),
+	}
+}
+
+func (s *Signer) privateKey(eventId uuid.UUID) ed25519.PrivateKey {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("ticket-signing-key:"))
+	mac.Write(eventId[:])
+	return ed25519.NewKeyFromSeed(mac.Sum(nil))
+}
+
+func (s *Signer) publicKey(eventId uuid.UUID) ed25519.PublicKey {
+	return s.privateKey(eventId).Public().(ed25519.PublicKey)
+}
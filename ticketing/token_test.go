@@ -0,0 +1,137 @@
+package ticketing
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestSigner(t *testing.T, secret string) *Signer {
+	t.Helper()
+
+	signer, err := NewSigner(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestNewSignerRejectsWeakSecret(t *testing.T) {
+	_, err := NewSigner(strings.Repeat("s", MinSecretSize-1))
+	if !errors.Is(err, ErrWeakSecret) {
+		t.Errorf("NewSigner() error = %v, want %v", err, ErrWeakSecret)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := newTestSigner(t, strings.Repeat("s", MinSecretSize))
+	notBefore := time.Unix(1_700_000_000, 0)
+	notAfter := notBefore.Add(6 * time.Hour)
+
+	tests := []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		now       time.Time
+		wantErr   error
+	}{
+		{"inside the window", notBefore, notAfter, notBefore.Add(time.Hour), nil},
+		{"at the start", notBefore, notAfter, notBefore, nil},
+		{"at the end", notBefore, notAfter, notAfter, nil},
+		{"before the start", notBefore, notAfter, notBefore.Add(-time.Second), ErrTokenNotValid},
+		{"after the end", notBefore, notAfter, notAfter.Add(time.Second), ErrTokenNotValid},
+		{"open start", time.Time{}, notAfter, time.Unix(0, 0), nil},
+		{"open start after the end", time.Time{}, notAfter, notAfter.Add(time.Second), ErrTokenNotValid},
+		{"open end", notBefore, time.Time{}, notBefore.AddDate(10, 0, 0), nil},
+		{"open end before the start", notBefore, time.Time{}, notBefore.Add(-time.Second), ErrTokenNotValid},
+		{"open both", time.Time{}, time.Time{}, time.Unix(0, 0), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := Claims{
+				TicketID:     uuid.New(),
+				EventID:      uuid.New(),
+				TicketTypeID: uuid.New(),
+				NotBefore:    tt.notBefore,
+				NotAfter:     tt.notAfter,
+			}
+
+			got, err := signer.Verify(signer.Sign(want), tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			// The claims come back with ErrTokenNotValid too
+			if got == nil || *got != want {
+				t.Errorf("Verify() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsForgedTokens(t *testing.T) {
+	signer := newTestSigner(t, strings.Repeat("s", MinSecretSize))
+	now := time.Unix(1_700_000_000, 0)
+	token := signer.Sign(Claims{TicketID: uuid.New(), EventID: uuid.New(), TicketTypeID: uuid.New()})
+	encodedPayload, encodedSignature, _ := strings.Cut(token, ".")
+
+	tamper := func(index int) string {
+		payload, _ := base64.RawURLEncoding.DecodeString(encodedPayload)
+		payload[index] ^= 1
+		return base64.RawURLEncoding.EncodeToString(payload) + "." + encodedSignature
+	}
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		token  string
+	}{
+		{"wrong key", newTestSigner(t, strings.Repeat("o", MinSecretSize)), token},
+		{"tampered ticket", signer, tamper(1)},
+		{"tampered event", signer, tamper(17)},
+		{"tampered window", signer, tamper(64)},
+		{"wrong version", signer, tamper(0)},
+		{"no signature", signer, encodedPayload},
+		{"truncated payload", signer, encodedPayload[:len(encodedPayload)-4] + "." + encodedSignature},
+		{"signature not base64url", signer, encodedPayload + ".!!"},
+		{"empty", signer, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.signer.Verify(tt.token, now)
+			if !errors.Is(err, ErrInvalidToken) || claims != nil {
+				t.Errorf("Verify() = %+v, %v, want %v", claims, err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestScannerKeyVerifiesTokens(t *testing.T) {
+	signer := newTestSigner(t, strings.Repeat("s", MinSecretSize))
+	eventId := uuid.New()
+	token := signer.Sign(Claims{TicketID: uuid.New(), EventID: eventId, TicketTypeID: uuid.New()})
+	encodedPayload, encodedSignature, _ := strings.Cut(token, ".")
+
+	publicKey := func(eventId uuid.UUID) ed25519.PublicKey {
+		key, err := base64.RawURLEncoding.DecodeString(signer.ScannerKey(eventId).PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(encodedPayload)
+	signature, _ := base64.RawURLEncoding.DecodeString(encodedSignature)
+
+	if !ed25519.Verify(publicKey(eventId), payload, signature) {
+		t.Error("the event's scanner key does not verify its tickets")
+	}
+	if ed25519.Verify(publicKey(uuid.New()), payload, signature) {
+		t.Error("another event's scanner key verifies the tickets")
+	}
+}
@@ -1,11 +1,25 @@
 package utils
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 type Response struct {
 	StatusCode int         `json:"statusCode"`
 	Message    string      `json:"message"`
 	Data       interface{} `json:"data,omitempty"`
+	Meta       *Pagination `json:"meta,omitempty"`
+}
+
+// Pagination describes the page of a list response. NextCursor is passed
+// back as the cursor query parameter to fetch the following page.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	Total      *int   `json:"total,omitempty"`
 }
 
 func SendResponse(c *fiber.Ctx, statusCode int, message string, data interface{}) error {
@@ -16,10 +30,29 @@ func SendResponse(c *fiber.Ctx, statusCode int, message string, data interface{}
 	})
 }
 
+// ResponseBody renders the body SendResponse sends, for a response stored
+// to be sent later.
+func ResponseBody(statusCode int, message string, data interface{}) ([]byte, error) {
+	return json.Marshal(Response{
+		StatusCode: statusCode,
+		Message:    message,
+		Data:       data,
+	})
+}
+
 func SendSuccessResponse(c *fiber.Ctx, message string, data interface{}) error {
 	return SendResponse(c, fiber.StatusOK, message, data)
 }
 
+func SendPageResponse(c *fiber.Ctx, message string, data interface{}, meta *Pagination) error {
+	return c.Status(fiber.StatusOK).JSON(Response{
+		StatusCode: fiber.StatusOK,
+		Message:    message,
+		Data:       data,
+		Meta:       meta,
+	})
+}
+
 func SendCreatedResponse(c *fiber.Ctx, message string, data interface{}) error {
 	return SendResponse(c, fiber.StatusCreated, message, data)
 }
@@ -32,10 +65,22 @@ func SendBadRequestResponse(c *fiber.Ctx, message string) error {
 	return SendErrorResponse(c, fiber.StatusBadRequest, message)
 }
 
+func SendUnauthorizedResponse(c *fiber.Ctx, message string) error {
+	return SendErrorResponse(c, fiber.StatusUnauthorized, message)
+}
+
+func SendForbiddenResponse(c *fiber.Ctx, message string) error {
+	return SendErrorResponse(c, fiber.StatusForbidden, message)
+}
+
 func SendNotFoundResponse(c *fiber.Ctx, message string) error {
 	return SendErrorResponse(c, fiber.StatusNotFound, message)
 }
 
+func SendConflictResponse(c *fiber.Ctx, message string) error {
+	return SendErrorResponse(c, fiber.StatusConflict, message)
+}
+
 func SendInternalServerErrorResponse(c *fiber.Ctx, err error) error {
 	return SendErrorResponse(c, fiber.StatusInternalServerError, err.Error())
 }